@@ -0,0 +1,215 @@
+// Command mock_idp is a minimal OpenID Connect provider for trying the OIDC login locally.
+// It supports the authorization code flow with PKCE and signs ID tokens with a key generated at startup.
+//
+//	go run ./cmd/mock_idp -addr :9000 -issuer http://localhost:9000 -client-id bookstore
+//
+// and configure the backend with OIDC_ISSUER=http://localhost:9000 and OIDC_CLIENT_ID=bookstore.
+package main
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"flag"
+	"github.com/golang-jwt/jwt/v5"
+	"html/template"
+	"log"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const keyId = "mock-idp"
+
+type authorization struct {
+	clientId      string
+	redirectUri   string
+	codeChallenge string
+	nonce         string
+	claims        jwt.MapClaims
+	expiresAt     time.Time
+}
+
+type idp struct {
+	issuer   string
+	clientId string
+	key      *rsa.PrivateKey
+
+	mu    sync.Mutex
+	codes map[string]authorization
+}
+
+var loginPage = template.Must(template.New("login").Parse(`<!doctype html>
+<title>Mock IdP</title>
+<h1>Mock IdP</h1>
+<form method="post">
+  {{range $name, $value := .Query}}<input type="hidden" name="{{$name}}" value="{{index $value 0}}">{{end}}
+  <p><label>Subject <input name="sub" value="mock-user-1"></label></p>
+  <p><label>Username <input name="preferred_username" value="mock.user"></label></p>
+  <p><label>Email <input name="email" value="mock.user@example.com"></label></p>
+  <p><label>Groups (comma separated) <input name="groups" value="store-admins"></label></p>
+  <p><button type="submit">Sign in</button></p>
+</form>`))
+
+func main() {
+	addr := flag.String("addr", ":9000", "listen address")
+	issuer := flag.String("issuer", "http://localhost:9000", "issuer URL as seen by the backend")
+	clientId := flag.String("client-id", "bookstore", "accepted client id")
+	flag.Parse()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	server := &idp{issuer: strings.TrimSuffix(*issuer, "/"), clientId: *clientId, key: key,
+		codes: make(map[string]authorization)}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", server.discovery)
+	mux.HandleFunc("/authorize", server.authorize)
+	mux.HandleFunc("/token", server.token)
+	mux.HandleFunc("/jwks", server.jwks)
+
+	log.Printf("mock idp listening on %s with issuer %s", *addr, server.issuer)
+	log.Fatal(http.ListenAndServe(*addr, mux))
+}
+
+func (r *idp) discovery(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"issuer":                                r.issuer,
+		"authorization_endpoint":                r.issuer + "/authorize",
+		"token_endpoint":                        r.issuer + "/token",
+		"jwks_uri":                              r.issuer + "/jwks",
+		"response_types_supported":              []string{"code"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+		"code_challenge_methods_supported":      []string{"S256"},
+	})
+}
+
+// authorize shows a form to pick the signed-in user, then redirects back with a code.
+func (r *idp) authorize(w http.ResponseWriter, req *http.Request) {
+	query := req.URL.Query()
+	if req.Method == http.MethodGet {
+		if query.Get("client_id") != r.clientId || query.Get("code_challenge_method") != "S256" {
+			http.Error(w, "unknown client or missing PKCE", http.StatusBadRequest)
+			return
+		}
+
+		_ = loginPage.Execute(w, map[string]interface{}{"Query": query})
+		return
+	}
+
+	err := req.ParseForm()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	groups := make([]string, 0)
+	for _, group := range strings.Split(req.PostForm.Get("groups"), ",") {
+		if group = strings.TrimSpace(group); group != "" {
+			groups = append(groups, group)
+		}
+	}
+
+	code := rand.Text()
+	r.mu.Lock()
+	r.codes[code] = authorization{
+		clientId:      req.PostForm.Get("client_id"),
+		redirectUri:   req.PostForm.Get("redirect_uri"),
+		codeChallenge: req.PostForm.Get("code_challenge"),
+		nonce:         req.PostForm.Get("nonce"),
+		claims: jwt.MapClaims{
+			"sub":                req.PostForm.Get("sub"),
+			"preferred_username": req.PostForm.Get("preferred_username"),
+			"email":              req.PostForm.Get("email"),
+			"email_verified":     true,
+			"groups":             groups,
+		},
+		expiresAt: time.Now().Add(time.Minute),
+	}
+	r.mu.Unlock()
+
+	redirect, err := url.Parse(req.PostForm.Get("redirect_uri"))
+	if err != nil {
+		http.Error(w, "invalid redirect_uri", http.StatusBadRequest)
+		return
+	}
+	values := redirect.Query()
+	values.Set("code", code)
+	values.Set("state", req.PostForm.Get("state"))
+	redirect.RawQuery = values.Encode()
+
+	http.Redirect(w, req, redirect.String(), http.StatusFound)
+}
+
+func (r *idp) token(w http.ResponseWriter, req *http.Request) {
+	err := req.ParseForm()
+	if err != nil || req.PostForm.Get("grant_type") != "authorization_code" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "unsupported_grant_type"})
+		return
+	}
+
+	r.mu.Lock()
+	auth, ok := r.codes[req.PostForm.Get("code")]
+	delete(r.codes, req.PostForm.Get("code"))
+	r.mu.Unlock()
+
+	challenge := sha256.Sum256([]byte(req.PostForm.Get("code_verifier")))
+	if !ok || time.Now().After(auth.expiresAt) ||
+		auth.clientId != req.PostForm.Get("client_id") ||
+		auth.redirectUri != req.PostForm.Get("redirect_uri") ||
+		auth.codeChallenge != base64.RawURLEncoding.EncodeToString(challenge[:]) {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	now := time.Now()
+	claims := auth.claims
+	claims["iss"] = r.issuer
+	claims["aud"] = auth.clientId
+	claims["iat"] = now.Unix()
+	claims["exp"] = now.Add(5 * time.Minute).Unix()
+	claims["nonce"] = auth.nonce
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = keyId
+	idToken, err := token.SignedString(r.key)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"access_token": rand.Text(),
+		"token_type":   "Bearer",
+		"expires_in":   300,
+		"id_token":     idToken,
+	})
+}
+
+func (r *idp) jwks(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"use": "sig",
+			"alg": "RS256",
+			"kid": keyId,
+			"n":   base64.RawURLEncoding.EncodeToString(r.key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(r.key.E)).Bytes()),
+		}},
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
@@ -1,23 +1,57 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
 	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
 	"gorm.io/gorm/schema"
 	"main.go/presentations/web"
+	"main.go/repositories/api_key_repository"
+	"main.go/repositories/audit_repository"
+	"main.go/repositories/author_repository"
 	"main.go/repositories/book_repository"
 	"main.go/repositories/cart_repository"
 	"main.go/repositories/category_repository"
+	"main.go/repositories/identity_repository"
+	"main.go/repositories/inventory_repository"
+	"main.go/repositories/invitation_repository"
+	"main.go/repositories/login_attempt_repository"
+	"main.go/repositories/mfa_repository"
+	"main.go/repositories/role_repository"
+	"main.go/repositories/search_repository"
+	"main.go/repositories/signing_key_repository"
+	"main.go/repositories/token_repository"
 	"main.go/repositories/user_repository"
 	"main.go/schemas"
+	"main.go/services/api_key_service"
+	"main.go/services/audit_service"
 	"main.go/services/authentification_service"
+	"main.go/services/author_service"
 	book_service "main.go/services/book_service"
 	"main.go/services/cart_service"
 	category_service "main.go/services/category_service"
+	"main.go/services/inventory_service"
+	"main.go/services/invitation_service"
+	"main.go/services/login_limiter_service"
+	"main.go/services/mfa_service"
+	"main.go/services/oidc_service"
+	"main.go/services/password_reset_service"
+	"main.go/services/role_service"
+	"main.go/services/search_service"
+	"main.go/services/session_service"
+	"main.go/services/signing_key_service"
+	"main.go/services/user_service"
+	"main.go/utils/mail_utils"
+	"main.go/utils/oidc_utils"
+	"main.go/utils/password_utils"
 	"main.go/utils/settings_utils"
+	"main.go/utils/signing_utils"
+	"os"
+	"time"
 )
 
 func main() {
@@ -32,7 +66,19 @@ func main() {
 		panic(errors.Wrap(err, "failed to connect database"))
 	}
 
-	err = db.AutoMigrate(&schemas.Book{}, &schemas.Category{}, &schemas.User{}, &schemas.Cart{})
+	cartRepo := cart_repository.NewRepository(db)
+	err = cartRepo.MergeDuplicateCarts(context.Background())
+	if err != nil {
+		panic(errors.Wrap(err, "failed to merge duplicate carts"))
+	}
+
+	err = db.AutoMigrate(&schemas.Book{}, &schemas.Category{}, &schemas.User{}, &schemas.Cart{},
+		&schemas.RevokedToken{}, &schemas.RefreshToken{}, &schemas.Role{}, &schemas.UserRole{},
+		&schemas.Invitation{}, &schemas.AuditLog{}, &schemas.PasswordResetToken{}, &schemas.LoginAttempt{},
+		&schemas.RecoveryCode{}, &schemas.ApiKey{}, &schemas.UserIdentity{}, &schemas.OidcState{},
+		&schemas.OidcLoginCode{}, &schemas.SigningKey{}, &schemas.Session{}, &schemas.BookCategory{},
+		&schemas.Author{}, &schemas.BookAuthor{}, &schemas.SearchDocument{},
+		&schemas.StockAdjustment{}, &schemas.CartLine{})
 	if err != nil {
 		panic(errors.Wrap(err, "failed to merge database"))
 	}
@@ -40,14 +86,130 @@ func main() {
 	bookRepo := book_repository.NewRepository(db)
 	categoryRepo := category_repository.NewRepositpory(db)
 	userRepo := user_repository.NewRepository(db)
-	cartRepo := cart_repository.NewRepository(db)
+	tokenRepo := token_repository.NewRepository(db)
+	roleRepo := role_repository.NewRepository(db)
+	invitationRepo := invitation_repository.NewRepository(db)
+	auditRepo := audit_repository.NewRepository(db)
+	mfaRepo := mfa_repository.NewRepository(db)
+	apiKeyRepo := api_key_repository.NewRepository(db)
+	identityRepo := identity_repository.NewRepository(db)
+	signingKeyRepo := signing_key_repository.NewRepository(db)
+	authorRepo := author_repository.NewRepository(db)
+	inventoryRepo := inventory_repository.NewRepository(db)
+
+	hasher, err := password_utils.NewHasher(settings_utils.Settings.PasswordHasher)
+	if err != nil {
+		panic(errors.Wrap(err, "failed to create password hasher"))
+	}
+
+	mailer, err := mail_utils.NewMailer(settings_utils.Settings.MailTransport, settings_utils.Settings.MailFrom,
+		settings_utils.Settings.SmtpHost, settings_utils.Settings.SmtpPort,
+		settings_utils.Settings.SmtpUser, settings_utils.Settings.SmtpPass,
+		settings_utils.Settings.MailFile)
+	if err != nil {
+		panic(errors.Wrap(err, "failed to create mailer"))
+	}
+
+	var limiterStore login_limiter_service.Store
+	switch settings_utils.Settings.LoginLimiterStore {
+	case "", "memory":
+		limiterStore = login_limiter_service.NewMemoryStore()
+	case "database":
+		limiterStore = login_attempt_repository.NewRepository(db)
+	default:
+		panic(errors.Errorf("unknown login limiter store %q", settings_utils.Settings.LoginLimiterStore))
+	}
+
+	var searchIndex search_service.Index
+	switch settings_utils.Settings.SearchBackend {
+	case "", "mysql":
+		searchIndex = search_repository.NewRepository(db)
+	case "memory":
+		searchIndex = search_service.NewMemoryIndex()
+	default:
+		panic(errors.Errorf("unknown search backend %q", settings_utils.Settings.SearchBackend))
+	}
+
+	var oidcProvider *oidc_utils.Provider
+	if settings_utils.Settings.OidcIssuer != "" {
+		oidcProvider = oidc_utils.NewProvider(settings_utils.Settings.OidcIssuer,
+			settings_utils.Settings.OidcClientId, settings_utils.Settings.OidcClientSecret,
+			settings_utils.Settings.OidcRedirectUrl, settings_utils.Settings.OidcScopes,
+			settings_utils.Settings.OidcGroupsClaim)
+	}
+
+	keyring := signing_utils.NewKeyring(settings_utils.Settings.SigningKey, settings_utils.Settings.JwtTtl)
+	signingKeyService := signing_key_service.NewService(signingKeyRepo, auditRepo, keyring)
+	err = signingKeyService.Bootstrap(context.Background())
+	if err != nil {
+		panic(errors.Wrap(err, "failed to bootstrap signing keys"))
+	}
+	if settings_utils.Settings.SigningKey != "" {
+		// The secret can forge HS256 tokens until then, and is useless afterwards.
+		log.Warn().Time("acceptedUntil", keyring.LegacyUntil()).Msg("legacy.signing.key.set.remove.it")
+	}
 
-	bookService := book_service.NewService(bookRepo)
-	categoryService := category_service.NewService(categoryRepo)
-	authService := authentification_service.NewService(userRepo)
+	searchService := search_service.NewService(searchIndex, bookRepo, categoryRepo)
+	bookService := book_service.NewService(bookRepo, categoryRepo, authorRepo, searchService)
+	categoryService := category_service.NewService(categoryRepo, searchService)
+	authService := authentification_service.NewService(userRepo, tokenRepo, roleRepo, invitationRepo, auditRepo, hasher, keyring)
 	cartService := cart_service.NewService(cartRepo, bookRepo)
+	roleService := role_service.NewService(roleRepo)
+	invitationService := invitation_service.NewService(invitationRepo, roleRepo, auditRepo)
+	auditService := audit_service.NewService(auditRepo)
+	userService := user_service.NewService(userRepo, roleRepo, tokenRepo, auditRepo, hasher, cartRepo, mfaRepo, apiKeyRepo, identityRepo)
+	passwordResetService := password_reset_service.NewService(userRepo, tokenRepo, hasher, mailer)
+	mfaService := mfa_service.NewService(userRepo, mfaRepo, roleRepo, auditRepo, hasher)
+	apiKeyService := api_key_service.NewService(apiKeyRepo, userRepo, roleRepo, auditRepo)
+	oidcService := oidc_service.NewService(oidcProvider, identityRepo, userRepo, roleRepo, auditRepo)
+	sessionService := session_service.NewService(tokenRepo, userRepo, auditRepo)
+	authorService := author_service.NewService(authorRepo, bookRepo, searchService)
+	inventoryService := inventory_service.NewService(inventoryRepo, bookRepo)
+	userPolicy, ipPolicy := login_limiter_service.DefaultPolicies(settings_utils.Settings.LoginLockoutThreshold,
+		settings_utils.Settings.LoginLockoutDuration)
+	loginLimiterService := login_limiter_service.NewService(limiterStore, userPolicy, ipPolicy)
+
+	err = roleService.Bootstrap(context.Background())
+	if err != nil {
+		panic(errors.Wrap(err, "failed to bootstrap roles"))
+	}
+
+	err = bookService.Bootstrap(context.Background())
+	if err != nil {
+		panic(errors.Wrap(err, "failed to bootstrap books"))
+	}
+
+	err = cartService.Bootstrap(context.Background())
+	if err != nil {
+		panic(errors.Wrap(err, "failed to bootstrap carts"))
+	}
+
+	err = searchService.Bootstrap(context.Background())
+	if err != nil {
+		panic(errors.Wrap(err, "failed to bootstrap search index"))
+	}
+
+	code, invitation, err := invitationService.BootstrapAdmin(context.Background())
+	if err != nil {
+		panic(errors.Wrap(err, "failed to bootstrap admin"))
+	}
+	if code != "" {
+		// The code grants admin rights, so it goes to the terminal once and never into the logs.
+		fmt.Fprintf(os.Stderr, "No administrator yet. Register with invitation code %s before %s.\n",
+			code, invitation.ExpiresAt.Format(time.RFC3339))
+		log.Warn().Time("expiresAt", invitation.ExpiresAt).Msg("no.admin.yet.invitation.code.printed")
+	}
+
+	go authService.PruneRevokedTokens(context.Background(), settings_utils.Settings.RevocationPruneInterval)
+	go signingKeyService.Run(context.Background(), time.Minute)
+	go oidcService.PruneStates(context.Background(), settings_utils.Settings.RevocationPruneInterval)
+	go loginLimiterService.Prune(context.Background(), settings_utils.Settings.RevocationPruneInterval)
+	go searchService.Run(context.Background(), settings_utils.Settings.SearchRefreshInterval)
 
-	presentation := web.NewPresentation(bookService, categoryService, authService, cartService)
+	presentation := web.NewPresentation(bookService, categoryService, authService, cartService,
+		roleService, invitationService, auditService, userService, passwordResetService,
+		loginLimiterService, mfaService, apiKeyService, oidcService, signingKeyService, sessionService,
+		authorService, searchService, inventoryService)
 
 	app := presentation.BuildApp()
 
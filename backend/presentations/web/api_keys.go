@@ -0,0 +1,86 @@
+package web
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+	"main.go/schemas"
+	"main.go/services/api_key_service"
+	"main.go/utils/jwt_utils"
+	validators_utils "main.go/utils/validator_utils"
+)
+
+func (r *Presentation) createApiKey(c *fiber.Ctx) error {
+	var request schemas.ApiKeyRequest
+	err := c.BodyParser(&request)
+	if err != nil {
+		return &fiber.Error{Code: fiber.StatusUnprocessableEntity}
+	}
+
+	err = validators_utils.Validate.Struct(&request)
+	if err != nil {
+		return &fiber.Error{Code: fiber.StatusUnprocessableEntity, Message: err.Error()}
+	}
+
+	token := c.Locals("user").(*jwt.Token)
+	userId, err := jwt_utils.GetUserId(token)
+	if err != nil {
+		return &fiber.Error{Code: fiber.StatusUnauthorized}
+	}
+
+	key, apiKey, err := r.apiKeyService.CreateApiKey(c.UserContext(), userId, &request)
+	if err != nil {
+		switch {
+		case errors.Is(err, api_key_service.ErrInvalidTtl), errors.Is(err, api_key_service.ErrUnknownScope):
+			return &fiber.Error{Code: fiber.StatusUnprocessableEntity, Message: err.Error()}
+		case errors.Is(err, api_key_service.ErrScopeNotGranted):
+			return &fiber.Error{Code: fiber.StatusForbidden, Message: err.Error()}
+		case errors.Is(err, api_key_service.ErrTooManyApiKeys):
+			return &fiber.Error{Code: fiber.StatusConflict, Message: err.Error()}
+		}
+		return errors.Wrap(err, "failed to create api key")
+	}
+
+	c.Status(fiber.StatusCreated)
+	return c.JSON(fiber.Map{"key": key, "apiKey": apiKey})
+}
+
+func (r *Presentation) listApiKeys(c *fiber.Ctx) error {
+	token := c.Locals("user").(*jwt.Token)
+	userId, err := jwt_utils.GetUserId(token)
+	if err != nil {
+		return &fiber.Error{Code: fiber.StatusUnauthorized}
+	}
+
+	apiKeys, err := r.apiKeyService.ListApiKeys(c.UserContext(), userId)
+	if err != nil {
+		return errors.Wrap(err, "failed to list api keys")
+	}
+
+	return c.JSON(fiber.Map{"apiKeys": apiKeys})
+}
+
+func (r *Presentation) revokeApiKey(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return &fiber.Error{Code: fiber.StatusUnprocessableEntity, Message: "invalid api key id"}
+	}
+
+	token := c.Locals("user").(*jwt.Token)
+	userId, err := jwt_utils.GetUserId(token)
+	if err != nil {
+		return &fiber.Error{Code: fiber.StatusUnauthorized}
+	}
+
+	err = r.apiKeyService.RevokeApiKey(c.UserContext(), userId, id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return &fiber.Error{Code: fiber.StatusNotFound, Message: "api key not found"}
+		}
+		return errors.Wrap(err, "failed to revoke api key")
+	}
+
+	return nil
+}
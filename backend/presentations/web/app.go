@@ -9,73 +9,197 @@ import (
 	recover2 "github.com/gofiber/fiber/v2/middleware/recover"
 	"github.com/gofiber/fiber/v2/middleware/requestid"
 	"github.com/gofiber/fiber/v2/middleware/timeout"
+	"main.go/schemas"
+	"main.go/services/api_key_service"
+	"main.go/services/audit_service"
 	"main.go/services/authentification_service"
+	"main.go/services/author_service"
 	book_service "main.go/services/book_service"
 	"main.go/services/cart_service"
 	category_service "main.go/services/category_service"
+	"main.go/services/inventory_service"
+	"main.go/services/invitation_service"
+	"main.go/services/login_limiter_service"
+	"main.go/services/mfa_service"
+	"main.go/services/oidc_service"
+	"main.go/services/password_reset_service"
+	"main.go/services/role_service"
+	"main.go/services/search_service"
+	"main.go/services/session_service"
+	"main.go/services/signing_key_service"
+	"main.go/services/user_service"
 	"main.go/utils/settings_utils"
 )
 
 type Presentation struct {
-	bookService     *book_service.Service
-	categoryService *category_service.Service
-	authService     *authentification_service.Service
-	cartService     *cart_service.Service
+	bookService          *book_service.Service
+	categoryService      *category_service.Service
+	authService          *authentification_service.Service
+	cartService          *cart_service.Service
+	roleService          *role_service.Service
+	invitationService    *invitation_service.Service
+	auditService         *audit_service.Service
+	userService          *user_service.Service
+	passwordResetService *password_reset_service.Service
+	loginLimiterService  *login_limiter_service.Service
+	mfaService           *mfa_service.Service
+	apiKeyService        *api_key_service.Service
+	oidcService          *oidc_service.Service
+	signingKeyService    *signing_key_service.Service
+	sessionService       *session_service.Service
+	authorService        *author_service.Service
+	searchService        *search_service.Service
+	inventoryService     *inventory_service.Service
 }
 
 func NewPresentation(bookService *book_service.Service,
 	categoryService *category_service.Service,
 	authService *authentification_service.Service,
-	cartService *cart_service.Service) *Presentation {
+	cartService *cart_service.Service,
+	roleService *role_service.Service,
+	invitationService *invitation_service.Service,
+	auditService *audit_service.Service,
+	userService *user_service.Service,
+	passwordResetService *password_reset_service.Service,
+	loginLimiterService *login_limiter_service.Service,
+	mfaService *mfa_service.Service,
+	apiKeyService *api_key_service.Service,
+	oidcService *oidc_service.Service,
+	signingKeyService *signing_key_service.Service,
+	sessionService *session_service.Service,
+	authorService *author_service.Service,
+	searchService *search_service.Service,
+	inventoryService *inventory_service.Service) *Presentation {
 	return &Presentation{bookService: bookService,
 		categoryService: categoryService, authService: authService,
-		cartService: cartService}
+		cartService: cartService, roleService: roleService,
+		invitationService: invitationService, auditService: auditService,
+		userService: userService, passwordResetService: passwordResetService,
+		loginLimiterService: loginLimiterService, mfaService: mfaService,
+		apiKeyService: apiKeyService, oidcService: oidcService,
+		signingKeyService: signingKeyService, sessionService: sessionService,
+		authorService: authorService, searchService: searchService,
+		inventoryService: inventoryService}
 }
 
 func (r *Presentation) BuildApp() *fiber.App {
 	app := fiber.New(fiber.Config{
-		Immutable: true,
+		Immutable:   true,
+		ProxyHeader: settings_utils.Settings.ProxyHeader,
 	})
 	app.Use(cors.New(cors.Config{
 		AllowOrigins:     settings_utils.Settings.Cors,
 		AllowMethods:     "GET,POST,PATCH,PUT,DELETE,OPTIONS,HEAD",
-		AllowHeaders:     "Origin,Content-Type,Accept,Authorization",
+		AllowHeaders:     "Origin,Content-Type,Accept,Authorization," + HeaderApiKey,
 		AllowCredentials: false,
-		ExposeHeaders:    "Content-Length",
+		ExposeHeaders:    "Content-Length,Retry-After",
 		MaxAge:           3600,
 	}))
 	app.Use(recover2.New(recover2.Config{EnableStackTrace: true}))
 	app.Use(requestid.New())
+	app.Use(r.clientInfo)
 	app.Use(logger.New(logger.Config{
 		Format: "${pid} ${locals:requestid} ${status} - ${method} ${path}\n",
 	}))
 
 	apiGroup := app.Group("/api/restricted")
-	apiGroup.Use(jwtware.New(jwtware.Config{SigningKey: jwtware.SigningKey{Key: []byte(settings_utils.Settings.SigningKey)}}))
+	apiGroup.Use(r.authenticateApiKey)
+	apiGroup.Use(jwtware.New(jwtware.Config{
+		Filter: func(c *fiber.Ctx) bool {
+			return c.Locals("user") != nil
+		},
+		KeyFunc:        r.signingKeyService.Keyfunc,
+		SuccessHandler: r.checkToken,
+	}))
+	apiGroup.Use("/auth", r.requireSession)
+	apiGroup.Use("/me", r.requireSession)
 
+	app.Get("/.well-known/jwks.json", r.jwks)
 	app.Get("/api/metrics", monitor.New(monitor.Config{Title: "Metrics Page"}))
 
 	app.Post("/api/auth/register", timeout.NewWithContext(r.registerUser, settings_utils.Settings.Timeout))
 	app.Post("/api/auth/login", timeout.NewWithContext(r.loginUser, settings_utils.Settings.Timeout))
+	app.Post("/api/auth/mfa", timeout.NewWithContext(r.completeMfaLogin, settings_utils.Settings.Timeout))
+	app.Get("/api/auth/oidc/login", timeout.NewWithContext(r.startOidcLogin, settings_utils.Settings.Timeout))
+	app.Get("/api/auth/oidc/callback", timeout.NewWithContext(r.oidcCallback, settings_utils.Settings.Timeout))
+	app.Post("/api/auth/oidc/exchange", timeout.NewWithContext(r.exchangeOidcCode, settings_utils.Settings.Timeout))
+	app.Post("/api/auth/refresh", timeout.NewWithContext(r.refreshTokens, settings_utils.Settings.Timeout))
+	app.Post("/api/auth/password/forgot", timeout.NewWithContext(r.requestPasswordReset, settings_utils.Settings.Timeout))
+	app.Post("/api/auth/password/reset", timeout.NewWithContext(r.confirmPasswordReset, settings_utils.Settings.Timeout))
 	apiGroup.Post("/auth/logout", timeout.NewWithContext(r.logoutUser, settings_utils.Settings.Timeout))
+	apiGroup.Post("/auth/logout/all", timeout.NewWithContext(r.logoutEverywhere, settings_utils.Settings.Timeout))
+
+	apiGroup.Get("/me", timeout.NewWithContext(r.getProfile, settings_utils.Settings.Timeout))
+	apiGroup.Patch("/me", timeout.NewWithContext(r.updateProfile, settings_utils.Settings.Timeout))
+	apiGroup.Put("/me/password", timeout.NewWithContext(r.changePassword, settings_utils.Settings.Timeout))
+	apiGroup.Delete("/me", timeout.NewWithContext(r.deleteAccount, settings_utils.Settings.Timeout))
+	apiGroup.Post("/me/mfa/totp", timeout.NewWithContext(r.beginTotpEnrollment, settings_utils.Settings.Timeout))
+	apiGroup.Post("/me/mfa/totp/confirm", timeout.NewWithContext(r.confirmTotpEnrollment, settings_utils.Settings.Timeout))
+	apiGroup.Delete("/me/mfa/totp", timeout.NewWithContext(r.disableTotp, settings_utils.Settings.Timeout))
+	apiGroup.Post("/me/mfa/recovery-codes", timeout.NewWithContext(r.regenerateRecoveryCodes, settings_utils.Settings.Timeout))
+	apiGroup.Get("/me/identities", timeout.NewWithContext(r.listIdentities, settings_utils.Settings.Timeout))
+	apiGroup.Post("/me/identities/oidc", timeout.NewWithContext(r.startOidcLink, settings_utils.Settings.Timeout))
+	apiGroup.Delete("/me/identities/:id", timeout.NewWithContext(r.unlinkIdentity, settings_utils.Settings.Timeout))
+	apiGroup.Get("/me/api-keys", timeout.NewWithContext(r.listApiKeys, settings_utils.Settings.Timeout))
+	apiGroup.Post("/me/api-keys", timeout.NewWithContext(r.createApiKey, settings_utils.Settings.Timeout))
+	apiGroup.Delete("/me/api-keys/:id", timeout.NewWithContext(r.revokeApiKey, settings_utils.Settings.Timeout))
+	apiGroup.Get("/me/sessions", timeout.NewWithContext(r.listSessions, settings_utils.Settings.Timeout))
+	apiGroup.Delete("/me/sessions/:id", timeout.NewWithContext(r.revokeSession, settings_utils.Settings.Timeout))
 
 	app.Get("/api/books", timeout.NewWithContext(r.listBooks, settings_utils.Settings.Timeout))
 	app.Get("/api/books/:category", timeout.NewWithContext(r.listBooksByCategory, settings_utils.Settings.Timeout))
 	app.Get("/api/books/info/:id", timeout.NewWithContext(r.bookInfo, settings_utils.Settings.Timeout))
 	app.Get("/api/books/search/:phrase", timeout.NewWithContext(r.searchBooks, settings_utils.Settings.Timeout))
+	app.Get("/api/search/suggest", timeout.NewWithContext(r.suggest, settings_utils.Settings.Timeout))
+
+	apiGroup.Post("/books", r.requirePermission(schemas.PermCatalogWrite), timeout.NewWithContext(r.saveBook, settings_utils.Settings.Timeout))
+	apiGroup.Patch("/books/:id", r.requirePermission(schemas.PermCatalogWrite), timeout.NewWithContext(r.updateBook, settings_utils.Settings.Timeout))
+	apiGroup.Delete("/books/:id", r.requirePermission(schemas.PermCatalogWrite), timeout.NewWithContext(r.deleteBook, settings_utils.Settings.Timeout))
+	apiGroup.Get("/books/:id/stock", r.requirePermission(schemas.PermCatalogWrite), timeout.NewWithContext(r.listStockAdjustments, settings_utils.Settings.Timeout))
+	apiGroup.Post("/books/:id/stock", r.requirePermission(schemas.PermCatalogWrite), timeout.NewWithContext(r.adjustStock, settings_utils.Settings.Timeout))
 
-	apiGroup.Post("/books", timeout.NewWithContext(r.saveBook, settings_utils.Settings.Timeout))
-	apiGroup.Patch("/books/:id", timeout.NewWithContext(r.updateBook, settings_utils.Settings.Timeout))
-	apiGroup.Delete("/books/:id", timeout.NewWithContext(r.deleteBook, settings_utils.Settings.Timeout))
+	app.Get("/api/authors/:id", timeout.NewWithContext(r.authorInfo, settings_utils.Settings.Timeout))
+
+	apiGroup.Patch("/authors/:id", r.requirePermission(schemas.PermCatalogWrite), timeout.NewWithContext(r.updateAuthor, settings_utils.Settings.Timeout))
+	apiGroup.Post("/authors/:id/merge", r.requirePermission(schemas.PermCatalogWrite), timeout.NewWithContext(r.mergeAuthor, settings_utils.Settings.Timeout))
 
 	app.Get("/api/categories", timeout.NewWithContext(r.listCategories, settings_utils.Settings.Timeout))
 
-	apiGroup.Post("/categories", timeout.NewWithContext(r.saveCategory, settings_utils.Settings.Timeout))
-	apiGroup.Patch("/categories/:id", timeout.NewWithContext(r.updateCategory, settings_utils.Settings.Timeout))
-	apiGroup.Delete("/categories/:id", timeout.NewWithContext(r.deleteCategory, settings_utils.Settings.Timeout))
+	apiGroup.Post("/categories", r.requirePermission(schemas.PermCatalogWrite), timeout.NewWithContext(r.saveCategory, settings_utils.Settings.Timeout))
+	apiGroup.Patch("/categories/:id", r.requirePermission(schemas.PermCatalogWrite), timeout.NewWithContext(r.updateCategory, settings_utils.Settings.Timeout))
+	apiGroup.Put("/categories/:id/parent", r.requirePermission(schemas.PermCatalogWrite), timeout.NewWithContext(r.moveCategory, settings_utils.Settings.Timeout))
+	apiGroup.Delete("/categories/:id", r.requirePermission(schemas.PermCatalogWrite), timeout.NewWithContext(r.deleteCategory, settings_utils.Settings.Timeout))
+	apiGroup.Post("/categories/:id/merge", r.requirePermission(schemas.PermCatalogWrite), timeout.NewWithContext(r.mergeCategory, settings_utils.Settings.Timeout))
+
+	apiGroup.Get("/roles", r.requirePermission(schemas.PermRolesWrite), timeout.NewWithContext(r.listRoles, settings_utils.Settings.Timeout))
+	apiGroup.Post("/roles", r.requirePermission(schemas.PermRolesWrite), timeout.NewWithContext(r.saveRole, settings_utils.Settings.Timeout))
+	apiGroup.Patch("/roles/:name", r.requirePermission(schemas.PermRolesWrite), timeout.NewWithContext(r.updateRole, settings_utils.Settings.Timeout))
+
+	apiGroup.Get("/invitations", r.requirePermission(schemas.PermInvitesWrite), timeout.NewWithContext(r.listInvitations, settings_utils.Settings.Timeout))
+	apiGroup.Post("/invitations", r.requirePermission(schemas.PermInvitesWrite), timeout.NewWithContext(r.issueInvitation, settings_utils.Settings.Timeout))
+	apiGroup.Delete("/invitations/:id", r.requirePermission(schemas.PermInvitesWrite), timeout.NewWithContext(r.revokeInvitation, settings_utils.Settings.Timeout))
+
+	apiGroup.Get("/users", r.requirePermission(schemas.PermUsersRead), timeout.NewWithContext(r.listUsers, settings_utils.Settings.Timeout))
+	apiGroup.Get("/users/:id", r.requirePermission(schemas.PermUsersRead), timeout.NewWithContext(r.userInfo, settings_utils.Settings.Timeout))
+	apiGroup.Put("/users/:id/roles", r.requirePermission(schemas.PermRolesWrite), timeout.NewWithContext(r.setUserRoles, settings_utils.Settings.Timeout))
+	apiGroup.Patch("/users/:id/status", r.requirePermission(schemas.PermUsersWrite), timeout.NewWithContext(r.setUserStatus, settings_utils.Settings.Timeout))
+	apiGroup.Delete("/users/:id", r.requirePermission(schemas.PermUsersWrite), timeout.NewWithContext(r.deleteUser, settings_utils.Settings.Timeout))
+	apiGroup.Delete("/users/:id/mfa", r.requirePermission(schemas.PermUsersWrite), timeout.NewWithContext(r.resetUserMfa, settings_utils.Settings.Timeout))
+	apiGroup.Get("/users/:id/sessions", r.requirePermission(schemas.PermUsersRead), timeout.NewWithContext(r.listUserSessions, settings_utils.Settings.Timeout))
+	apiGroup.Delete("/users/:id/sessions/:sessionId", r.requirePermission(schemas.PermUsersWrite), timeout.NewWithContext(r.revokeUserSession, settings_utils.Settings.Timeout))
+
+	apiGroup.Get("/users/:id/cart", r.requirePermission(schemas.PermOrdersRead), timeout.NewWithContext(r.userCart, settings_utils.Settings.Timeout))
+	apiGroup.Put("/users/:id/cart/:bookId", r.requirePermission(schemas.PermOrdersWrite), timeout.NewWithContext(r.setUserCartQuantity, settings_utils.Settings.Timeout))
+	apiGroup.Delete("/users/:id/cart/:bookId", r.requirePermission(schemas.PermOrdersWrite), timeout.NewWithContext(r.deleteFromUserCart, settings_utils.Settings.Timeout))
+
+	apiGroup.Get("/signing-keys", r.requirePermission(schemas.PermKeysWrite), timeout.NewWithContext(r.listSigningKeys, settings_utils.Settings.Timeout))
+	apiGroup.Post("/signing-keys/rotate", r.requirePermission(schemas.PermKeysWrite), timeout.NewWithContext(r.rotateSigningKey, settings_utils.Settings.Timeout))
+
+	apiGroup.Get("/audit", r.requirePermission(schemas.PermAuditRead), timeout.NewWithContext(r.listAuditLogs, settings_utils.Settings.Timeout))
 
 	apiGroup.Get("/cart", timeout.NewWithContext(r.getCart, settings_utils.Settings.Timeout))
 	apiGroup.Post("/cart", timeout.NewWithContext(r.addToCart, settings_utils.Settings.Timeout))
+	apiGroup.Put("/cart/:id", timeout.NewWithContext(r.setCartQuantity, settings_utils.Settings.Timeout))
 	apiGroup.Delete("/cart/:id", timeout.NewWithContext(r.deleteFromCart, settings_utils.Settings.Timeout))
 
 	return app
@@ -0,0 +1,20 @@
+package web
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/pkg/errors"
+)
+
+func (r *Presentation) listAuditLogs(c *fiber.Ctx) error {
+	page, pageSize, err := pagination(c, 50)
+	if err != nil {
+		return err
+	}
+
+	logs, err := r.auditService.ListAuditLogs(c.UserContext(), page, pageSize, c.Query("action"))
+	if err != nil {
+		return errors.Wrap(err, "failed to list audit logs")
+	}
+
+	return c.JSON(fiber.Map{"logs": logs})
+}
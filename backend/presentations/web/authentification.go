@@ -3,10 +3,15 @@ package web
 import (
 	"github.com/gofiber/fiber/v2"
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 	"github.com/pkg/errors"
 	"main.go/schemas"
 	"main.go/services/authentification_service"
+	"main.go/utils/jwt_utils"
 	validators_utils "main.go/utils/validator_utils"
+	"math"
+	"strconv"
+	"time"
 )
 
 func (r *Presentation) registerUser(c *fiber.Ctx) error {
@@ -17,20 +22,24 @@ func (r *Presentation) registerUser(c *fiber.Ctx) error {
 		return &fiber.Error{Code: fiber.StatusUnprocessableEntity}
 	}
 
-	err = validators_utils.Validate.StructExcept(&registrationRequest, "Key")
+	err = validators_utils.Validate.StructExcept(&registrationRequest, "InviteCode")
 	if err != nil {
 		return &fiber.Error{Code: fiber.StatusUnprocessableEntity}
 	}
 
-	token, err := r.authService.RegisterUser(c.UserContext(), &registrationRequest)
+	tokens, err := r.authService.RegisterUser(c.UserContext(), &registrationRequest)
 	if err != nil {
-		if errors.Is(err, authentification_service.ErrAlreadyTaken) {
+		if errors.Is(err, authentification_service.ErrAlreadyTaken) ||
+			errors.Is(err, authentification_service.ErrEmailTaken) {
 			return &fiber.Error{Code: fiber.StatusConflict, Message: err.Error()}
 		}
+		if errors.Is(err, authentification_service.ErrInvalidInvitation) {
+			return &fiber.Error{Code: fiber.StatusForbidden, Message: authentification_service.ErrInvalidInvitation.Error()}
+		}
 		return errors.Wrap(err, "failed to register user")
 	}
 
-	return c.JSON(fiber.Map{"token": token})
+	return c.JSON(tokens)
 }
 
 func (r *Presentation) loginUser(c *fiber.Ctx) error {
@@ -46,33 +55,142 @@ func (r *Presentation) loginUser(c *fiber.Ctx) error {
 		return &fiber.Error{Code: fiber.StatusUnprocessableEntity}
 	}
 
+	retryAfter, err := r.loginLimiterService.Check(c.UserContext(), loginRequest.Username, c.IP())
+	if err != nil {
+		return errors.Wrap(err, "failed to log in")
+	}
+	if retryAfter > 0 {
+		return tooManyAttempts(c, retryAfter)
+	}
+
 	user, err := r.authService.LoginUser(c.UserContext(), &loginRequest)
 	if err != nil {
 		if errors.Is(err, authentification_service.ErrWrongPassword) {
+			err = r.loginLimiterService.Failure(c.UserContext(), loginRequest.Username, c.IP())
+			if err != nil {
+				return errors.Wrap(err, "failed to log in")
+			}
+
 			return &fiber.Error{
 				Code:    fiber.StatusUnauthorized,
-				Message: errors.Wrap(err, "failed to log in").Error(),
+				Message: errors.Wrap(authentification_service.ErrWrongPassword, "failed to log in").Error(),
 			}
 		}
+		if errors.Is(err, authentification_service.ErrUserDisabled) {
+			return &fiber.Error{Code: fiber.StatusForbidden, Message: err.Error()}
+		}
+
+		return errors.Wrap(err, "failed to log in")
+	}
+
+	if user.MfaEnabled() {
+		challenge, err := r.authService.IssueMfaChallenge(c.UserContext(), user)
+		if err != nil {
+			return errors.Wrap(err, "failed to log in")
+		}
 
+		return c.JSON(challenge)
+	}
+
+	err = r.loginLimiterService.Success(c.UserContext(), loginRequest.Username)
+	if err != nil {
 		return errors.Wrap(err, "failed to log in")
 	}
 
-	token, err := user.GenerateTokenJWT()
+	tokens, err := r.authService.IssueTokens(c.UserContext(), user, uuid.Nil)
 	if err != nil {
-		return errors.Wrap(err, "failed to generate JWT token")
+		return errors.Wrap(err, "failed to issue tokens")
 	}
 
-	return c.JSON(fiber.Map{"token": token})
+	return c.JSON(tokens)
+}
+
+func (r *Presentation) refreshTokens(c *fiber.Ctx) error {
+	var request schemas.RefreshRequest
+
+	err := c.BodyParser(&request)
+	if err != nil {
+		return &fiber.Error{Code: fiber.StatusUnprocessableEntity}
+	}
+
+	err = validators_utils.Validate.Struct(&request)
+	if err != nil {
+		return &fiber.Error{Code: fiber.StatusUnprocessableEntity}
+	}
+
+	tokens, err := r.authService.RefreshTokens(c.UserContext(), request.RefreshToken)
+	if err != nil {
+		if errors.Is(err, authentification_service.ErrInvalidRefreshToken) ||
+			errors.Is(err, authentification_service.ErrRefreshTokenReused) {
+			return &fiber.Error{Code: fiber.StatusUnauthorized, Message: err.Error()}
+		}
+		return errors.Wrap(err, "failed to refresh tokens")
+	}
+
+	return c.JSON(tokens)
 }
 
 func (r *Presentation) logoutUser(c *fiber.Ctx) error {
+	var request schemas.RefreshRequest
+	if len(c.Body()) != 0 {
+		err := c.BodyParser(&request)
+		if err != nil {
+			return &fiber.Error{Code: fiber.StatusUnprocessableEntity}
+		}
+	}
+
 	user := c.Locals("user").(*jwt.Token)
 
-	token, err := r.authService.LogoutUser(user)
+	err := r.authService.LogoutUser(c.UserContext(), user)
 	if err != nil {
 		return errors.Wrap(err, "logout failed")
 	}
 
-	return c.JSON(fiber.Map{"token": token})
+	if request.RefreshToken != "" {
+		userId, err := jwt_utils.GetUserId(user)
+		if err != nil {
+			return &fiber.Error{Code: fiber.StatusUnauthorized}
+		}
+
+		err = r.authService.RevokeRefreshToken(c.UserContext(), userId, request.RefreshToken)
+		if err != nil {
+			return errors.Wrap(err, "logout failed")
+		}
+	}
+
+	return nil
+}
+
+func (r *Presentation) logoutEverywhere(c *fiber.Ctx) error {
+	var request schemas.LogoutAllRequest
+	if len(c.Body()) != 0 {
+		err := c.BodyParser(&request)
+		if err != nil {
+			return &fiber.Error{Code: fiber.StatusUnprocessableEntity}
+		}
+	}
+
+	token := c.Locals("user").(*jwt.Token)
+	userId, err := jwt_utils.GetUserId(token)
+	if err != nil {
+		return &fiber.Error{Code: fiber.StatusUnauthorized}
+	}
+
+	var before time.Time
+	if request.Before != nil {
+		before = *request.Before
+	}
+
+	err = r.authService.LogoutEverywhere(c.UserContext(), userId, before)
+	if err != nil {
+		return errors.Wrap(err, "logout everywhere failed")
+	}
+
+	return nil
+}
+
+// tooManyAttempts tells throttled clients when to come back.
+func tooManyAttempts(c *fiber.Ctx, retryAfter time.Duration) error {
+	c.Set(fiber.HeaderRetryAfter, strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+	return &fiber.Error{Code: fiber.StatusTooManyRequests, Message: "too many login attempts"}
 }
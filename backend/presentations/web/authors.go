@@ -0,0 +1,94 @@
+package web
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+	"main.go/schemas"
+	"main.go/services/author_service"
+	validators_utils "main.go/utils/validator_utils"
+)
+
+// authorInfo pages the author's books like book listings do.
+func (r *Presentation) authorInfo(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return &fiber.Error{Code: fiber.StatusUnprocessableEntity, Message: "invalid author id"}
+	}
+
+	request, err := pageRequest(c, "name")
+	if err != nil {
+		return err
+	}
+
+	author, page, err := r.authorService.GetAuthor(c.UserContext(), id, request)
+	if err != nil {
+		return authorError(err, "failed to get author")
+	}
+
+	return c.JSON(fiber.Map{"author": author, "books": page.Books, "nextCursor": page.NextCursor,
+		"total": page.Total, "totalEstimated": page.TotalEstimated, "sort": page.Sort, "pageSize": page.PageSize})
+}
+
+func (r *Presentation) updateAuthor(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return &fiber.Error{Code: fiber.StatusUnprocessableEntity, Message: "invalid author id"}
+	}
+
+	var request schemas.AuthorRequest
+	err = c.BodyParser(&request)
+	if err != nil {
+		return &fiber.Error{Code: fiber.StatusUnprocessableEntity}
+	}
+
+	err = validators_utils.Validate.Struct(&request)
+	if err != nil {
+		return &fiber.Error{Code: fiber.StatusUnprocessableEntity, Message: err.Error()}
+	}
+
+	author, err := r.authorService.UpdateAuthor(c.UserContext(), id, &request)
+	if err != nil {
+		return authorError(err, "failed to update author")
+	}
+
+	return c.JSON(fiber.Map{"author": author})
+}
+
+func (r *Presentation) mergeAuthor(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return &fiber.Error{Code: fiber.StatusUnprocessableEntity, Message: "invalid author id"}
+	}
+
+	var request schemas.AuthorMergeRequest
+	err = c.BodyParser(&request)
+	if err != nil {
+		return &fiber.Error{Code: fiber.StatusUnprocessableEntity}
+	}
+
+	err = validators_utils.Validate.Struct(&request)
+	if err != nil {
+		return &fiber.Error{Code: fiber.StatusUnprocessableEntity, Message: err.Error()}
+	}
+
+	author, err := r.authorService.MergeAuthor(c.UserContext(), id, request.AuthorId)
+	if err != nil {
+		return authorError(err, "failed to merge author")
+	}
+
+	return c.JSON(fiber.Map{"author": author})
+}
+
+func authorError(err error, message string) error {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return &fiber.Error{Code: fiber.StatusNotFound, Message: "author not found"}
+	case errors.Is(err, author_service.ErrAuthorNameTaken):
+		return &fiber.Error{Code: fiber.StatusConflict, Message: err.Error()}
+	case errors.Is(err, author_service.ErrAuthorMergeSelf):
+		return &fiber.Error{Code: fiber.StatusUnprocessableEntity, Message: err.Error()}
+	}
+	return errors.Wrap(err, message)
+}
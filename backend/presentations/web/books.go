@@ -2,69 +2,68 @@ package web
 
 import (
 	"github.com/gofiber/fiber/v2"
-	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 	"github.com/pkg/errors"
+	"gorm.io/gorm"
 	"main.go/schemas"
-	"main.go/utils/jwt_utils"
+	book_service "main.go/services/book_service"
+	"main.go/services/search_service"
 	validators_utils "main.go/utils/validator_utils"
+	"strconv"
 	"strings"
+	"time"
 )
 
+// listBooks lists the catalog narrowed by the optional filters minPrice, maxPrice, categories and
+// authors (comma separated ids), categoryMatch (any or all), createdFrom, createdTo and q.
+// facets=true adds category, author and price counts.
 func (r *Presentation) listBooks(c *fiber.Ctx) error {
-	page := c.QueryInt("page")
-	pageSize := c.QueryInt("pageSize")
-	if page < 0 || pageSize < 1 {
-		return &fiber.Error{Code: fiber.StatusUnprocessableEntity}
+	filter, err := bookFilter(c)
+	if err != nil {
+		return &fiber.Error{Code: fiber.StatusUnprocessableEntity, Message: err.Error()}
 	}
-	sortBy := c.Query("sortBy", "name")
-	orderBy := c.Query("orderBy", "DESC")
-	orderBy = strings.ToUpper(orderBy)
 
-	err := VerifySort(sortBy)
+	defaultSort := "name"
+	if filter.Phrase != "" {
+		defaultSort = "relevance"
+	}
+	request, err := pageRequest(c, defaultSort)
 	if err != nil {
-		sortBy = "name"
+		return err
 	}
 
-	err = VerifyOrder(orderBy)
+	page, err := r.searchService.FilterBooks(c.UserContext(), filter, request)
 	if err != nil {
-		orderBy = "DESC"
+		return bookError(err, "list books")
 	}
 
-	books, err := r.bookService.GetBooks(c.UserContext(), page, pageSize, sortBy, orderBy)
-	if err != nil {
-		return errors.Wrap(err, "list books")
+	if c.QueryBool("facets") {
+		page.Facets, err = r.searchService.Facets(c.UserContext(), filter)
+		if err != nil {
+			return errors.Wrap(err, "list books")
+		}
 	}
 
-	return c.JSON(fiber.Map{"books": books})
+	return c.JSON(page)
 }
 
 func (r *Presentation) listBooksByCategory(c *fiber.Ctx) error {
-	page := c.QueryInt("page")
-	pageSize := c.QueryInt("pageSize")
 	categoryName := c.Params("category")
-	if categoryName == "" || page < 0 || pageSize < 1 {
+	if categoryName == "" {
 		return &fiber.Error{Code: fiber.StatusUnprocessableEntity}
 	}
-	sortBy := c.Query("sortBy", "name")
-	orderBy := c.Query("orderBy", "DESC")
-	orderBy = strings.ToUpper(orderBy)
 
-	err := VerifySort(sortBy)
+	request, err := pageRequest(c, "name")
 	if err != nil {
-		sortBy = "name"
+		return err
 	}
 
-	err = VerifyOrder(orderBy)
-	if err != nil {
-		orderBy = "DESC"
-	}
-	books, err := r.bookService.GetBooksByCategory(c.UserContext(), page, pageSize, categoryName, sortBy, orderBy)
+	page, err := r.bookService.GetBooksByCategory(c.UserContext(), categoryName, request)
 	if err != nil {
-		return errors.Wrap(err, "list books by category")
+		return bookError(err, "list books by category")
 	}
 
-	return c.JSON(fiber.Map{"books": books})
+	return c.JSON(page)
 }
 
 func (r *Presentation) bookInfo(c *fiber.Ctx) error {
@@ -82,14 +81,8 @@ func (r *Presentation) bookInfo(c *fiber.Ctx) error {
 }
 
 func (r *Presentation) saveBook(c *fiber.Ctx) error {
-	token := c.Locals("user").(*jwt.Token)
-	err := jwt_utils.CheckAdmin(token)
-	if err != nil {
-		return &fiber.Error{Code: fiber.StatusUnauthorized}
-	}
-
 	var book schemas.Book
-	err = c.BodyParser(&book)
+	err := c.BodyParser(&book)
 	if err != nil {
 		return &fiber.Error{Code: fiber.StatusUnprocessableEntity, Message: err.Error()}
 	}
@@ -100,6 +93,9 @@ func (r *Presentation) saveBook(c *fiber.Ctx) error {
 	}
 
 	err = r.bookService.SaveBook(c.UserContext(), &book)
+	if err != nil {
+		return bookError(err, "failed to save book")
+	}
 
 	c.Status(fiber.StatusCreated)
 
@@ -107,12 +103,6 @@ func (r *Presentation) saveBook(c *fiber.Ctx) error {
 }
 
 func (r *Presentation) updateBook(c *fiber.Ctx) error {
-	token := c.Locals("user").(*jwt.Token)
-	err := jwt_utils.CheckAdmin(token)
-	if err != nil {
-		return &fiber.Error{Code: fiber.StatusUnauthorized}
-	}
-
 	id, err := uuid.Parse(c.Params("id"))
 	if err != nil {
 		return &fiber.Error{Code: fiber.StatusUnprocessableEntity, Message: "invalid book id"}
@@ -126,19 +116,13 @@ func (r *Presentation) updateBook(c *fiber.Ctx) error {
 
 	err = r.bookService.UpdateBook(c.UserContext(), id, &book)
 	if err != nil {
-		return errors.Wrap(err, "failed to update book")
+		return bookError(err, "failed to update book")
 	}
 
 	return nil
 }
 
 func (r *Presentation) deleteBook(c *fiber.Ctx) error {
-	token := c.Locals("user").(*jwt.Token)
-	err := jwt_utils.CheckAdmin(token)
-	if err != nil {
-		return &fiber.Error{Code: fiber.StatusUnauthorized}
-	}
-
 	id, err := uuid.Parse(c.Params("id"))
 	if err != nil {
 		return &fiber.Error{Code: fiber.StatusUnprocessableEntity, Message: "invalid book id"}
@@ -157,34 +141,135 @@ func (r *Presentation) searchBooks(c *fiber.Ctx) error {
 	if phrase == "" {
 		return nil
 	}
-	page := c.QueryInt("page")
-	pageSize := c.QueryInt("pageSize")
-	if page < 0 || pageSize < 1 {
+
+	request, err := pageRequest(c, "relevance")
+	if err != nil {
+		return err
+	}
+
+	page, err := r.searchService.SearchBooks(c.UserContext(), phrase, request)
+	if err != nil {
+		return bookError(err, "failed to search books")
+	}
+
+	return c.JSON(page)
+}
+
+// maxSuggestions bounds the limit query parameter of suggest.
+const maxSuggestions = 20
+
+func (r *Presentation) suggest(c *fiber.Ctx) error {
+	limit := c.QueryInt("limit", 10)
+	if limit < 1 || limit > maxSuggestions {
 		return &fiber.Error{Code: fiber.StatusUnprocessableEntity}
 	}
-	sortBy := c.Query("sortBy", "name")
-	orderBy := c.Query("orderBy", "DESC")
-	orderBy = strings.ToUpper(orderBy)
 
-	err := VerifySort(sortBy)
+	suggestions := r.searchService.Suggest(c.UserContext(), c.Query("q"), limit)
+	return c.JSON(fiber.Map{"suggestions": suggestions})
+}
+
+// bookFilter reads the filters of listBooks. Dates are RFC 3339 timestamps or plain dates;
+// a plain createdTo date includes the whole day.
+func bookFilter(c *fiber.Ctx) (*schemas.BookFilter, error) {
+	filter := schemas.BookFilter{
+		CategoryMatch: c.Query("categoryMatch", schemas.CategoryMatchAny),
+		Phrase:        strings.TrimSpace(c.Query("q")),
+	}
+	if filter.CategoryMatch != schemas.CategoryMatchAny && filter.CategoryMatch != schemas.CategoryMatchAll {
+		return nil, errors.New("categoryMatch must be any or all")
+	}
+
+	for _, bound := range []struct {
+		name  string
+		value **int
+	}{{"minPrice", &filter.MinPrice}, {"maxPrice", &filter.MaxPrice}} {
+		if c.Query(bound.name) == "" {
+			continue
+		}
+		price, err := strconv.Atoi(c.Query(bound.name))
+		if err != nil || price < 0 {
+			return nil, errors.Errorf("invalid %s", bound.name)
+		}
+		*bound.value = &price
+	}
+	if filter.MinPrice != nil && filter.MaxPrice != nil && *filter.MinPrice > *filter.MaxPrice {
+		return nil, errors.New("minPrice is above maxPrice")
+	}
+
+	var err error
+	filter.CategoryIds, err = parseIds(c.Query("categories"))
 	if err != nil {
-		sortBy = "name"
+		return nil, errors.New("invalid category id")
+	}
+	filter.AuthorIds, err = parseIds(c.Query("authors"))
+	if err != nil {
+		return nil, errors.New("invalid author id")
 	}
 
-	err = VerifyOrder(orderBy)
+	filter.CreatedFrom, err = parseDate(c.Query("createdFrom"), false)
 	if err != nil {
-		orderBy = "DESC"
+		return nil, errors.New("invalid createdFrom")
 	}
-	books, err := r.bookService.SearchBooks(c.UserContext(), page, pageSize, phrase, sortBy, orderBy)
+	filter.CreatedTo, err = parseDate(c.Query("createdTo"), true)
 	if err != nil {
-		return errors.Wrap(err, "failed to search books")
+		return nil, errors.New("invalid createdTo")
+	}
+
+	return &filter, nil
+}
+
+func parseIds(value string) ([]uuid.UUID, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	var ids []uuid.UUID
+	for _, part := range strings.Split(value, ",") {
+		id, err := uuid.Parse(strings.TrimSpace(part))
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
 	}
 
-	return c.JSON(fiber.Map{"books": books})
+	return ids, nil
+}
+
+// parseDate parses an RFC 3339 timestamp or a date. endOfDay moves a date to the start of the next day.
+func parseDate(value string, endOfDay bool) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	date, err := time.Parse(time.RFC3339, value)
+	if err == nil {
+		return &date, nil
+	}
+
+	date, err = time.Parse(time.DateOnly, value)
+	if err != nil {
+		return nil, err
+	}
+	if endOfDay {
+		date = date.AddDate(0, 0, 1)
+	}
+
+	return &date, nil
+}
+
+func bookError(err error, message string) error {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return &fiber.Error{Code: fiber.StatusNotFound, Message: "category not found"}
+	case errors.Is(err, book_service.ErrUnknownCategory), errors.Is(err, book_service.ErrUnknownAuthor),
+		errors.Is(err, search_service.ErrInvalidCursor):
+		return &fiber.Error{Code: fiber.StatusUnprocessableEntity, Message: err.Error()}
+	}
+	return errors.Wrap(err, message)
 }
 
 func VerifySort(sort string) error {
-	if sort == "name" || sort == "authors" || sort == "price" {
+	if sort == "name" || sort == "authors" || sort == "price" || sort == "relevance" {
 		return nil
 	}
 
@@ -1,32 +1,71 @@
 package web
 
 import (
-	"encoding/json"
 	"github.com/gofiber/fiber/v2"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 	"github.com/pkg/errors"
+	"gorm.io/gorm"
+	"main.go/schemas"
+	"main.go/services/cart_service"
+	validators_utils "main.go/utils/validator_utils"
 )
 
 func (r *Presentation) addToCart(c *fiber.Ctx) error {
-	body := c.Body()
-	var request struct {
-		ID uuid.UUID `json:"id"`
+	var request schemas.CartAddRequest
+	err := c.BodyParser(&request)
+	if err != nil {
+		return &fiber.Error{Code: fiber.StatusUnprocessableEntity}
+	}
+
+	err = validators_utils.Validate.Struct(&request)
+	if err != nil {
+		return &fiber.Error{Code: fiber.StatusUnprocessableEntity, Message: err.Error()}
+	}
+	if request.Quantity == 0 {
+		request.Quantity = 1
+	}
+
+	token := c.Locals("user").(*jwt.Token)
+	userId, err := GetUserIdFromJwt(token)
+	if err != nil {
+		return &fiber.Error{Code: fiber.StatusUnauthorized}
+	}
+
+	err = r.cartService.Add(c.UserContext(), userId, request.ID, request.Quantity)
+	if err != nil {
+		return cartError(err, "add to cart")
+	}
+
+	return nil
+}
+
+func (r *Presentation) setCartQuantity(c *fiber.Ctx) error {
+	bookId, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return &fiber.Error{Code: fiber.StatusBadRequest}
 	}
-	err := json.Unmarshal(body, &request)
+
+	var request schemas.CartLineRequest
+	err = c.BodyParser(&request)
 	if err != nil {
 		return &fiber.Error{Code: fiber.StatusUnprocessableEntity}
 	}
 
+	err = validators_utils.Validate.Struct(&request)
+	if err != nil {
+		return &fiber.Error{Code: fiber.StatusUnprocessableEntity, Message: err.Error()}
+	}
+
 	token := c.Locals("user").(*jwt.Token)
 	userId, err := GetUserIdFromJwt(token)
 	if err != nil {
 		return &fiber.Error{Code: fiber.StatusUnauthorized}
 	}
 
-	err = r.cartService.Add(c.UserContext(), userId, request.ID)
+	err = r.cartService.SetQuantity(c.UserContext(), userId, bookId, *request.Quantity)
 	if err != nil {
-		return errors.Wrap(err, "add to cart")
+		return cartError(err, "failed to set cart quantity")
 	}
 
 	return nil
@@ -41,7 +80,7 @@ func (r *Presentation) getCart(c *fiber.Ctx) error {
 
 	cart, books, err := r.cartService.Get(c.UserContext(), userId)
 	if err != nil {
-		return errors.Wrap(err, "failed to get cart")
+		return cartError(err, "failed to get cart")
 	}
 
 	return c.JSON(fiber.Map{"cart": cart, "books": books})
@@ -61,12 +100,88 @@ func (r *Presentation) deleteFromCart(c *fiber.Ctx) error {
 
 	err = r.cartService.DeleteBook(c.UserContext(), userId, bookId)
 	if err != nil {
-		return errors.Wrap(err, "failed to delete from cart")
+		return cartError(err, "failed to delete from cart")
 	}
 	
 	return nil
 }
 
+// userCart shows the cart of any user to staff looking into an order.
+func (r *Presentation) userCart(c *fiber.Ctx) error {
+	userId, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return &fiber.Error{Code: fiber.StatusUnprocessableEntity, Message: "invalid user id"}
+	}
+
+	cart, books, err := r.cartService.Get(c.UserContext(), userId)
+	if err != nil {
+		return cartError(err, "failed to get user cart")
+	}
+
+	return c.JSON(fiber.Map{"cart": cart, "books": books})
+}
+
+func (r *Presentation) setUserCartQuantity(c *fiber.Ctx) error {
+	userId, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return &fiber.Error{Code: fiber.StatusUnprocessableEntity, Message: "invalid user id"}
+	}
+	bookId, err := uuid.Parse(c.Params("bookId"))
+	if err != nil {
+		return &fiber.Error{Code: fiber.StatusBadRequest}
+	}
+
+	var request schemas.CartLineRequest
+	err = c.BodyParser(&request)
+	if err != nil {
+		return &fiber.Error{Code: fiber.StatusUnprocessableEntity}
+	}
+
+	err = validators_utils.Validate.Struct(&request)
+	if err != nil {
+		return &fiber.Error{Code: fiber.StatusUnprocessableEntity, Message: err.Error()}
+	}
+
+	err = r.cartService.SetQuantity(c.UserContext(), userId, bookId, *request.Quantity)
+	if err != nil {
+		return cartError(err, "failed to set user cart quantity")
+	}
+
+	return nil
+}
+
+func (r *Presentation) deleteFromUserCart(c *fiber.Ctx) error {
+	userId, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return &fiber.Error{Code: fiber.StatusUnprocessableEntity, Message: "invalid user id"}
+	}
+	bookId, err := uuid.Parse(c.Params("bookId"))
+	if err != nil {
+		return &fiber.Error{Code: fiber.StatusBadRequest}
+	}
+
+	err = r.cartService.DeleteBook(c.UserContext(), userId, bookId)
+	if err != nil {
+		return cartError(err, "failed to delete from user cart")
+	}
+
+	return nil
+}
+
+func cartError(err error, message string) error {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return &fiber.Error{Code: fiber.StatusNotFound, Message: "cart not found"}
+	case errors.Is(err, cart_service.ErrNotInCart):
+		return &fiber.Error{Code: fiber.StatusNotFound, Message: err.Error()}
+	case errors.Is(err, cart_service.ErrUnknownBook), errors.Is(err, cart_service.ErrInvalidQuantity):
+		return &fiber.Error{Code: fiber.StatusUnprocessableEntity, Message: err.Error()}
+	case errors.Is(err, cart_service.ErrOutOfStock):
+		return &fiber.Error{Code: fiber.StatusConflict, Message: err.Error()}
+	}
+	return errors.Wrap(err, message)
+}
+
 func GetUserIdFromJwt(token *jwt.Token) (uuid.UUID, error) {
 	claims := token.Claims.(jwt.MapClaims)
 	id, err := uuid.Parse(claims["sub"].(string))
@@ -2,16 +2,16 @@ package web
 
 import (
 	"github.com/gofiber/fiber/v2"
-	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 	"github.com/pkg/errors"
+	"gorm.io/gorm"
 	"main.go/schemas"
-	"main.go/utils/jwt_utils"
+	category_service "main.go/services/category_service"
 	validators_utils "main.go/utils/validator_utils"
 )
 
 func (r *Presentation) listCategories(c *fiber.Ctx) error {
-	categories, err := r.categoryService.ListCategories(c.UserContext())
+	categories, err := r.categoryService.ListCategories(c.UserContext(), c.QueryBool("counts"))
 	if err != nil {
 		return errors.Wrap(err, "failed to list categories")
 	}
@@ -20,14 +20,8 @@ func (r *Presentation) listCategories(c *fiber.Ctx) error {
 }
 
 func (r *Presentation) saveCategory(c *fiber.Ctx) error {
-	token := c.Locals("user").(*jwt.Token)
-	err := jwt_utils.CheckAdmin(token)
-	if err != nil {
-		return &fiber.Error{Code: fiber.StatusUnauthorized}
-	}
-
 	var category schemas.Category
-	err = c.BodyParser(&category)
+	err := c.BodyParser(&category)
 	if err != nil {
 		return &fiber.Error{Code: fiber.StatusUnprocessableEntity}
 	}
@@ -39,7 +33,7 @@ func (r *Presentation) saveCategory(c *fiber.Ctx) error {
 
 	err = r.categoryService.SaveCategory(c.UserContext(), &category)
 	if err != nil {
-		return errors.Wrap(err, "failed to save category")
+		return categoryError(err, "failed to save category")
 	}
 
 	c.Status(fiber.StatusCreated)
@@ -47,12 +41,6 @@ func (r *Presentation) saveCategory(c *fiber.Ctx) error {
 }
 
 func (r *Presentation) updateCategory(c *fiber.Ctx) error {
-	token := c.Locals("user").(*jwt.Token)
-	err := jwt_utils.CheckAdmin(token)
-	if err != nil {
-		return &fiber.Error{Code: fiber.StatusUnauthorized}
-	}
-
 	id, err := uuid.Parse(c.Params("id"))
 	if err != nil {
 		return &fiber.Error{Code: fiber.StatusUnprocessableEntity, Message: "invalid category id"}
@@ -77,22 +65,86 @@ func (r *Presentation) updateCategory(c *fiber.Ctx) error {
 	return nil
 }
 
+func (r *Presentation) moveCategory(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return &fiber.Error{Code: fiber.StatusUnprocessableEntity, Message: "invalid category id"}
+	}
+
+	var request schemas.CategoryMoveRequest
+	err = c.BodyParser(&request)
+	if err != nil {
+		return &fiber.Error{Code: fiber.StatusUnprocessableEntity}
+	}
+
+	err = r.categoryService.MoveCategory(c.UserContext(), id, request.ParentId)
+	if err != nil {
+		return categoryError(err, "failed to move category")
+	}
+
+	return nil
+}
+
+// deleteCategory takes the strategy query parameter: detach (the default) unlinks the books,
+// refuse fails while the category has books or subcategories, and reassign links its books to targetId.
 func (r *Presentation) deleteCategory(c *fiber.Ctx) error {
-	token := c.Locals("user").(*jwt.Token)
-	err := jwt_utils.CheckAdmin(token)
+	id, err := uuid.Parse(c.Params("id"))
 	if err != nil {
-		return &fiber.Error{Code: fiber.StatusUnauthorized}
+		return &fiber.Error{Code: fiber.StatusUnprocessableEntity, Message: "invalid category id"}
 	}
 
+	var targetId *uuid.UUID
+	if c.Query("targetId") != "" {
+		target, err := uuid.Parse(c.Query("targetId"))
+		if err != nil {
+			return &fiber.Error{Code: fiber.StatusUnprocessableEntity, Message: "invalid target category id"}
+		}
+		targetId = &target
+	}
+
+	strategy := c.Query("strategy", schemas.CategoryDeleteDetach)
+	err = r.categoryService.DeleteCategory(c.UserContext(), id, strategy, targetId)
+	if err != nil {
+		return categoryError(err, "failed to delete category")
+	}
+
+	return nil
+}
+
+func (r *Presentation) mergeCategory(c *fiber.Ctx) error {
 	id, err := uuid.Parse(c.Params("id"))
 	if err != nil {
 		return &fiber.Error{Code: fiber.StatusUnprocessableEntity, Message: "invalid category id"}
 	}
 
-	err = r.categoryService.DeleteCategory(c.UserContext(), id)
+	var request schemas.CategoryMergeRequest
+	err = c.BodyParser(&request)
 	if err != nil {
-		return errors.Wrap(err, "failed to delete category")
+		return &fiber.Error{Code: fiber.StatusUnprocessableEntity}
+	}
+
+	err = validators_utils.Validate.Struct(&request)
+	if err != nil {
+		return &fiber.Error{Code: fiber.StatusUnprocessableEntity, Message: err.Error()}
+	}
+
+	err = r.categoryService.MergeCategory(c.UserContext(), id, request.TargetId)
+	if err != nil {
+		return categoryError(err, "failed to merge category")
 	}
 
 	return nil
 }
+
+func categoryError(err error, message string) error {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return &fiber.Error{Code: fiber.StatusNotFound, Message: "category not found"}
+	case errors.Is(err, category_service.ErrUnknownParent), errors.Is(err, category_service.ErrUnknownStrategy),
+		errors.Is(err, category_service.ErrInvalidTarget):
+		return &fiber.Error{Code: fiber.StatusUnprocessableEntity, Message: err.Error()}
+	case errors.Is(err, category_service.ErrCategoryCycle), errors.Is(err, category_service.ErrCategoryNotEmpty):
+		return &fiber.Error{Code: fiber.StatusConflict, Message: err.Error()}
+	}
+	return errors.Wrap(err, message)
+}
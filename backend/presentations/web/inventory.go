@@ -0,0 +1,76 @@
+package web
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+	"main.go/schemas"
+	"main.go/services/inventory_service"
+	"main.go/utils/jwt_utils"
+	validators_utils "main.go/utils/validator_utils"
+)
+
+func (r *Presentation) adjustStock(c *fiber.Ctx) error {
+	bookId, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return &fiber.Error{Code: fiber.StatusUnprocessableEntity, Message: "invalid book id"}
+	}
+
+	var request schemas.StockAdjustmentRequest
+	err = c.BodyParser(&request)
+	if err != nil {
+		return &fiber.Error{Code: fiber.StatusUnprocessableEntity}
+	}
+
+	err = validators_utils.Validate.Struct(&request)
+	if err != nil {
+		return &fiber.Error{Code: fiber.StatusUnprocessableEntity, Message: err.Error()}
+	}
+
+	token := c.Locals("user").(*jwt.Token)
+	actorId, err := jwt_utils.GetUserId(token)
+	if err != nil {
+		return &fiber.Error{Code: fiber.StatusUnauthorized}
+	}
+
+	adjustment, err := r.inventoryService.Adjust(c.UserContext(), actorId, bookId, &request)
+	if err != nil {
+		return stockError(err, "failed to adjust stock")
+	}
+
+	c.Status(fiber.StatusCreated)
+	return c.JSON(fiber.Map{"adjustment": adjustment})
+}
+
+func (r *Presentation) listStockAdjustments(c *fiber.Ctx) error {
+	bookId, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return &fiber.Error{Code: fiber.StatusUnprocessableEntity, Message: "invalid book id"}
+	}
+
+	page, pageSize, err := pagination(c, 50)
+	if err != nil {
+		return err
+	}
+
+	book, adjustments, err := r.inventoryService.ListAdjustments(c.UserContext(), bookId, page, pageSize)
+	if err != nil {
+		return stockError(err, "failed to list stock adjustments")
+	}
+
+	return c.JSON(fiber.Map{"stock": book.Stock, "availability": book.Availability, "adjustments": adjustments})
+}
+
+func stockError(err error, message string) error {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return &fiber.Error{Code: fiber.StatusNotFound, Message: "book not found"}
+	case errors.Is(err, inventory_service.ErrUnknownReason), errors.Is(err, inventory_service.ErrInvalidQuantity):
+		return &fiber.Error{Code: fiber.StatusUnprocessableEntity, Message: err.Error()}
+	case errors.Is(err, inventory_service.ErrInsufficientStock):
+		return &fiber.Error{Code: fiber.StatusConflict, Message: err.Error()}
+	}
+	return errors.Wrap(err, message)
+}
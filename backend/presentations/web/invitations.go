@@ -0,0 +1,79 @@
+package web
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"main.go/schemas"
+	"main.go/services/invitation_service"
+	"main.go/utils/jwt_utils"
+	validators_utils "main.go/utils/validator_utils"
+)
+
+func (r *Presentation) issueInvitation(c *fiber.Ctx) error {
+	var request schemas.InvitationRequest
+	err := c.BodyParser(&request)
+	if err != nil {
+		return &fiber.Error{Code: fiber.StatusUnprocessableEntity}
+	}
+
+	err = validators_utils.Validate.Struct(&request)
+	if err != nil {
+		return &fiber.Error{Code: fiber.StatusUnprocessableEntity, Message: err.Error()}
+	}
+
+	token := c.Locals("user").(*jwt.Token)
+	actorId, err := jwt_utils.GetUserId(token)
+	if err != nil {
+		return &fiber.Error{Code: fiber.StatusUnauthorized}
+	}
+
+	code, invitation, err := r.invitationService.IssueInvitation(c.UserContext(), actorId, &request)
+	if err != nil {
+		if errors.Is(err, invitation_service.ErrInvalidTtl) || errors.Is(err, invitation_service.ErrUnknownRole) {
+			return &fiber.Error{Code: fiber.StatusUnprocessableEntity, Message: err.Error()}
+		}
+		return errors.Wrap(err, "failed to issue invitation")
+	}
+
+	c.Status(fiber.StatusCreated)
+	return c.JSON(fiber.Map{"code": code, "invitation": invitation})
+}
+
+func (r *Presentation) listInvitations(c *fiber.Ctx) error {
+	page, pageSize, err := pagination(c, 20)
+	if err != nil {
+		return err
+	}
+
+	invitations, err := r.invitationService.ListInvitations(c.UserContext(), page, pageSize)
+	if err != nil {
+		return errors.Wrap(err, "failed to list invitations")
+	}
+
+	return c.JSON(fiber.Map{"invitations": invitations})
+}
+
+func (r *Presentation) revokeInvitation(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return &fiber.Error{Code: fiber.StatusUnprocessableEntity, Message: "invalid invitation id"}
+	}
+
+	token := c.Locals("user").(*jwt.Token)
+	actorId, err := jwt_utils.GetUserId(token)
+	if err != nil {
+		return &fiber.Error{Code: fiber.StatusUnauthorized}
+	}
+
+	err = r.invitationService.RevokeInvitation(c.UserContext(), actorId, id)
+	if err != nil {
+		if errors.Is(err, invitation_service.ErrNotPending) {
+			return &fiber.Error{Code: fiber.StatusConflict, Message: err.Error()}
+		}
+		return errors.Wrap(err, "failed to revoke invitation")
+	}
+
+	return nil
+}
@@ -0,0 +1,210 @@
+package web
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+	"main.go/schemas"
+	"main.go/services/authentification_service"
+	"main.go/services/mfa_service"
+	"main.go/utils/jwt_utils"
+	validators_utils "main.go/utils/validator_utils"
+)
+
+// completeMfaLogin is the second login step, trading an mfa token and a code for regular tokens.
+func (r *Presentation) completeMfaLogin(c *fiber.Ctx) error {
+	var request schemas.MfaLoginRequest
+	err := c.BodyParser(&request)
+	if err != nil {
+		return &fiber.Error{Code: fiber.StatusUnprocessableEntity}
+	}
+
+	err = validators_utils.Validate.Struct(&request)
+	if err != nil {
+		return &fiber.Error{Code: fiber.StatusUnprocessableEntity}
+	}
+
+	user, err := r.authService.VerifyMfaToken(c.UserContext(), request.MfaToken)
+	if err != nil {
+		if errors.Is(err, authentification_service.ErrInvalidMfaToken) {
+			return &fiber.Error{Code: fiber.StatusUnauthorized, Message: err.Error()}
+		}
+		return errors.Wrap(err, "failed to log in")
+	}
+
+	retryAfter, err := r.loginLimiterService.Check(c.UserContext(), user.Username, c.IP())
+	if err != nil {
+		return errors.Wrap(err, "failed to log in")
+	}
+	if retryAfter > 0 {
+		return tooManyAttempts(c, retryAfter)
+	}
+
+	err = r.mfaService.Verify(c.UserContext(), user, request.Code)
+	if err != nil {
+		if errors.Is(err, mfa_service.ErrInvalidMfaCode) {
+			err = r.loginLimiterService.Failure(c.UserContext(), user.Username, c.IP())
+			if err != nil {
+				return errors.Wrap(err, "failed to log in")
+			}
+
+			return &fiber.Error{Code: fiber.StatusUnauthorized, Message: mfa_service.ErrInvalidMfaCode.Error()}
+		}
+		return errors.Wrap(err, "failed to log in")
+	}
+
+	err = r.loginLimiterService.Success(c.UserContext(), user.Username)
+	if err != nil {
+		return errors.Wrap(err, "failed to log in")
+	}
+
+	tokens, err := r.authService.IssueTokens(c.UserContext(), user, uuid.Nil)
+	if err != nil {
+		return errors.Wrap(err, "failed to issue tokens")
+	}
+
+	return c.JSON(tokens)
+}
+
+func (r *Presentation) beginTotpEnrollment(c *fiber.Ctx) error {
+	token := c.Locals("user").(*jwt.Token)
+	userId, err := jwt_utils.GetUserId(token)
+	if err != nil {
+		return &fiber.Error{Code: fiber.StatusUnauthorized}
+	}
+
+	enrollment, err := r.mfaService.BeginEnrollment(c.UserContext(), userId)
+	if err != nil {
+		return mfaError(err, "failed to begin totp enrollment")
+	}
+
+	return c.JSON(enrollment)
+}
+
+// confirmTotpEnrollment also issues new tokens, as tokens of users forced to enroll carry no roles.
+func (r *Presentation) confirmTotpEnrollment(c *fiber.Ctx) error {
+	var request schemas.MfaCodeRequest
+	err := c.BodyParser(&request)
+	if err != nil {
+		return &fiber.Error{Code: fiber.StatusUnprocessableEntity}
+	}
+
+	err = validators_utils.Validate.Struct(&request)
+	if err != nil {
+		return &fiber.Error{Code: fiber.StatusUnprocessableEntity}
+	}
+
+	token := c.Locals("user").(*jwt.Token)
+	userId, err := jwt_utils.GetUserId(token)
+	if err != nil {
+		return &fiber.Error{Code: fiber.StatusUnauthorized}
+	}
+
+	user, codes, err := r.mfaService.ConfirmEnrollment(c.UserContext(), userId, request.Code)
+	if err != nil {
+		return mfaError(err, "failed to confirm totp enrollment")
+	}
+
+	tokens, err := r.authService.IssueTokens(c.UserContext(), user, uuid.Nil)
+	if err != nil {
+		return errors.Wrap(err, "failed to issue tokens")
+	}
+
+	return c.JSON(fiber.Map{
+		"recoveryCodes": codes,
+		"token":         tokens.Token,
+		"refreshToken":  tokens.RefreshToken,
+	})
+}
+
+func (r *Presentation) disableTotp(c *fiber.Ctx) error {
+	var request schemas.MfaDisableRequest
+	err := c.BodyParser(&request)
+	if err != nil {
+		return &fiber.Error{Code: fiber.StatusUnprocessableEntity}
+	}
+
+	err = validators_utils.Validate.Struct(&request)
+	if err != nil {
+		return &fiber.Error{Code: fiber.StatusUnprocessableEntity}
+	}
+
+	token := c.Locals("user").(*jwt.Token)
+	userId, err := jwt_utils.GetUserId(token)
+	if err != nil {
+		return &fiber.Error{Code: fiber.StatusUnauthorized}
+	}
+
+	err = r.mfaService.Disable(c.UserContext(), userId, &request)
+	if err != nil {
+		return mfaError(err, "failed to disable totp")
+	}
+
+	return nil
+}
+
+func (r *Presentation) regenerateRecoveryCodes(c *fiber.Ctx) error {
+	var request schemas.MfaCodeRequest
+	err := c.BodyParser(&request)
+	if err != nil {
+		return &fiber.Error{Code: fiber.StatusUnprocessableEntity}
+	}
+
+	err = validators_utils.Validate.Struct(&request)
+	if err != nil {
+		return &fiber.Error{Code: fiber.StatusUnprocessableEntity}
+	}
+
+	token := c.Locals("user").(*jwt.Token)
+	userId, err := jwt_utils.GetUserId(token)
+	if err != nil {
+		return &fiber.Error{Code: fiber.StatusUnauthorized}
+	}
+
+	codes, err := r.mfaService.RegenerateRecoveryCodes(c.UserContext(), userId, request.Code)
+	if err != nil {
+		return mfaError(err, "failed to regenerate recovery codes")
+	}
+
+	return c.JSON(schemas.RecoveryCodes{RecoveryCodes: codes})
+}
+
+func (r *Presentation) resetUserMfa(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return &fiber.Error{Code: fiber.StatusUnprocessableEntity, Message: "invalid user id"}
+	}
+
+	token := c.Locals("user").(*jwt.Token)
+	actorId, err := jwt_utils.GetUserId(token)
+	if err != nil {
+		return &fiber.Error{Code: fiber.StatusUnauthorized}
+	}
+
+	err = r.mfaService.Reset(c.UserContext(), actorId, id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return &fiber.Error{Code: fiber.StatusNotFound, Message: "user not found"}
+		}
+		return mfaError(err, "failed to reset totp")
+	}
+
+	return nil
+}
+
+func mfaError(err error, message string) error {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return &fiber.Error{Code: fiber.StatusUnauthorized}
+	case errors.Is(err, mfa_service.ErrWrongPassword), errors.Is(err, mfa_service.ErrInvalidMfaCode),
+		errors.Is(err, mfa_service.ErrMfaRequired):
+		return &fiber.Error{Code: fiber.StatusForbidden, Message: err.Error()}
+	case errors.Is(err, mfa_service.ErrMfaAlreadyEnabled), errors.Is(err, mfa_service.ErrMfaNotEnabled),
+		errors.Is(err, mfa_service.ErrMfaNotStarted), errors.Is(err, mfa_service.ErrSelfModification):
+		return &fiber.Error{Code: fiber.StatusConflict, Message: err.Error()}
+	}
+
+	return errors.Wrap(err, message)
+}
@@ -0,0 +1,92 @@
+package web
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/pkg/errors"
+	"main.go/schemas"
+	"main.go/services/api_key_service"
+	"main.go/services/authentification_service"
+	"main.go/utils/client_utils"
+	"main.go/utils/jwt_utils"
+)
+
+const HeaderApiKey = "X-API-Key"
+
+// clientInfo puts the caller's address, user agent and request id into the request context,
+// where issuing tokens picks them up to record the session.
+func (r *Presentation) clientInfo(c *fiber.Ctx) error {
+	requestId, _ := c.Locals("requestid").(string)
+	c.SetUserContext(client_utils.WithClient(c.UserContext(), client_utils.Client{
+		Ip:        truncate(c.IP(), 64),
+		UserAgent: truncate(c.Get(fiber.HeaderUserAgent), 512),
+		RequestId: truncate(requestId, 64),
+	}))
+
+	return c.Next()
+}
+
+func truncate(value string, length int) string {
+	if len(value) <= length {
+		return value
+	}
+	return value[:length]
+}
+
+// checkToken runs after jwtware has validated the signature and rejects revoked tokens.
+func (r *Presentation) checkToken(c *fiber.Ctx) error {
+	token := c.Locals("user").(*jwt.Token)
+
+	err := r.authService.CheckToken(c.UserContext(), token)
+	if err != nil {
+		if errors.Is(err, authentification_service.ErrTokenRevoked) {
+			return &fiber.Error{Code: fiber.StatusUnauthorized, Message: err.Error()}
+		}
+		return errors.Wrap(err, "check token")
+	}
+
+	return c.Next()
+}
+
+// requirePermission guards a route with a permission from the token's perms claim.
+func (r *Presentation) requirePermission(permission string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		token := c.Locals("user").(*jwt.Token)
+		if !jwt_utils.HasPermission(token, permission) {
+			return &fiber.Error{Code: fiber.StatusForbidden, Message: "missing permission " + permission}
+		}
+
+		return c.Next()
+	}
+}
+
+// authenticateApiKey accepts the X-API-Key header in place of a bearer token.
+// jwtware skips requests it has already authenticated.
+func (r *Presentation) authenticateApiKey(c *fiber.Ctx) error {
+	key := c.Get(HeaderApiKey)
+	if key == "" {
+		return c.Next()
+	}
+
+	token, err := r.apiKeyService.Authenticate(c.UserContext(), key)
+	if err != nil {
+		if errors.Is(err, api_key_service.ErrInvalidApiKey) {
+			return &fiber.Error{Code: fiber.StatusUnauthorized, Message: err.Error()}
+		}
+		return errors.Wrap(err, "authenticate api key")
+	}
+
+	c.Locals("user", token)
+	return c.Next()
+}
+
+// requireSession keeps API keys away from account management, so that a leaked key
+// cannot mint more keys, change 2FA or end sessions.
+func (r *Presentation) requireSession(c *fiber.Ctx) error {
+	token := c.Locals("user").(*jwt.Token)
+	if jwt_utils.GetType(token) == schemas.TokenTypeApiKey {
+		return &fiber.Error{Code: fiber.StatusForbidden, Message: "not allowed with an api key"}
+	}
+
+	return c.Next()
+}
@@ -0,0 +1,212 @@
+package web
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+	"main.go/schemas"
+	"main.go/services/oidc_service"
+	"main.go/utils/jwt_utils"
+	"main.go/utils/settings_utils"
+	validators_utils "main.go/utils/validator_utils"
+	"net/url"
+	"time"
+)
+
+// oidcStateCookie binds an authorization request to the browser that started it. It is scoped
+// to the callback path and has to survive the top-level redirect back from the provider, hence Lax.
+const oidcStateCookie = "oidc_state"
+const oidcCookiePath = "/api/auth/oidc"
+
+func (r *Presentation) startOidcLogin(c *fiber.Ctx) error {
+	authorizationUrl, binding, err := r.oidcService.AuthorizationUrl(c.UserContext(), uuid.Nil)
+	if err != nil {
+		return oidcError(err, "failed to start oidc login")
+	}
+	setOidcStateCookie(c, binding, time.Now().Add(oidc_service.StateTtl))
+
+	return c.Redirect(authorizationUrl, fiber.StatusFound)
+}
+
+// oidcCallback is where the identity provider sends the browser back. The outcome goes to
+// OIDC_FRONTEND_URL in the URL fragment, with a one-time code to exchange for the tokens,
+// or is answered as JSON if that is not set.
+func (r *Presentation) oidcCallback(c *fiber.Ctx) error {
+	binding := c.Cookies(oidcStateCookie)
+	setOidcStateCookie(c, "", time.Unix(0, 0))
+
+	if providerError := c.Query("error"); providerError != "" {
+		return r.oidcResult(c, url.Values{"error": {providerError}},
+			&fiber.Error{Code: fiber.StatusUnauthorized, Message: providerError})
+	}
+
+	state, code := c.Query("state"), c.Query("code")
+	if state == "" || code == "" {
+		return &fiber.Error{Code: fiber.StatusUnprocessableEntity}
+	}
+
+	user, linked, err := r.oidcService.Callback(c.UserContext(), state, binding, code)
+	if err != nil {
+		fiberError, ok := oidcError(err, "oidc callback failed").(*fiber.Error)
+		if !ok {
+			return errors.Wrap(err, "oidc callback failed")
+		}
+		return r.oidcResult(c, url.Values{"error": {fiberError.Message}}, fiberError)
+	}
+
+	if linked {
+		return r.oidcResult(c, url.Values{"linked": {"true"}}, nil)
+	}
+
+	if settings_utils.Settings.OidcFrontendUrl != "" {
+		loginCode, err := r.oidcService.IssueLoginCode(c.UserContext(), user.ID)
+		if err != nil {
+			return errors.Wrap(err, "oidc callback failed")
+		}
+
+		return r.oidcResult(c, url.Values{"code": {loginCode}}, nil)
+	}
+
+	return r.oidcLogin(c, user)
+}
+
+// exchangeOidcCode trades the one-time code of oidcCallback for tokens, or an MFA challenge.
+func (r *Presentation) exchangeOidcCode(c *fiber.Ctx) error {
+	var request schemas.OidcExchangeRequest
+	err := c.BodyParser(&request)
+	if err != nil {
+		return &fiber.Error{Code: fiber.StatusUnprocessableEntity}
+	}
+
+	err = validators_utils.Validate.Struct(&request)
+	if err != nil {
+		return &fiber.Error{Code: fiber.StatusUnprocessableEntity, Message: err.Error()}
+	}
+
+	user, err := r.oidcService.RedeemLoginCode(c.UserContext(), request.Code)
+	if err != nil {
+		return oidcError(err, "failed to exchange oidc code")
+	}
+
+	return r.oidcLogin(c, user)
+}
+
+// oidcLogin answers like a password login: an MFA challenge if the user needs one, tokens otherwise.
+func (r *Presentation) oidcLogin(c *fiber.Ctx, user *schemas.User) error {
+	if user.MfaEnabled() {
+		challenge, err := r.authService.IssueMfaChallenge(c.UserContext(), user)
+		if err != nil {
+			return errors.Wrap(err, "failed to log in")
+		}
+
+		return c.JSON(challenge)
+	}
+
+	tokens, err := r.authService.IssueTokens(c.UserContext(), user, uuid.Nil)
+	if err != nil {
+		return errors.Wrap(err, "failed to issue tokens")
+	}
+
+	return c.JSON(tokens)
+}
+
+func (r *Presentation) oidcResult(c *fiber.Ctx, values url.Values, failure *fiber.Error) error {
+	if settings_utils.Settings.OidcFrontendUrl != "" {
+		return c.Redirect(settings_utils.Settings.OidcFrontendUrl+"#"+values.Encode(), fiber.StatusFound)
+	}
+	if failure != nil {
+		return failure
+	}
+
+	result := fiber.Map{}
+	for key := range values {
+		result[key] = values.Get(key)
+	}
+	return c.JSON(result)
+}
+
+func setOidcStateCookie(c *fiber.Ctx, value string, expires time.Time) {
+	c.Cookie(&fiber.Cookie{
+		Name:     oidcStateCookie,
+		Value:    value,
+		Path:     oidcCookiePath,
+		Expires:  expires,
+		Secure:   c.Protocol() == "https",
+		HTTPOnly: true,
+		SameSite: fiber.CookieSameSiteLaxMode,
+	})
+}
+
+// startOidcLink returns the authorization URL instead of redirecting, since it is called with a bearer token.
+// The state cookie is set all the same, so only this browser can complete the link.
+func (r *Presentation) startOidcLink(c *fiber.Ctx) error {
+	token := c.Locals("user").(*jwt.Token)
+	userId, err := jwt_utils.GetUserId(token)
+	if err != nil {
+		return &fiber.Error{Code: fiber.StatusUnauthorized}
+	}
+
+	authorizationUrl, binding, err := r.oidcService.AuthorizationUrl(c.UserContext(), userId)
+	if err != nil {
+		return oidcError(err, "failed to start oidc link")
+	}
+	setOidcStateCookie(c, binding, time.Now().Add(oidc_service.StateTtl))
+
+	return c.JSON(fiber.Map{"url": authorizationUrl})
+}
+
+func (r *Presentation) listIdentities(c *fiber.Ctx) error {
+	token := c.Locals("user").(*jwt.Token)
+	userId, err := jwt_utils.GetUserId(token)
+	if err != nil {
+		return &fiber.Error{Code: fiber.StatusUnauthorized}
+	}
+
+	identities, err := r.oidcService.ListIdentities(c.UserContext(), userId)
+	if err != nil {
+		return errors.Wrap(err, "failed to list identities")
+	}
+
+	return c.JSON(fiber.Map{"identities": identities})
+}
+
+func (r *Presentation) unlinkIdentity(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return &fiber.Error{Code: fiber.StatusUnprocessableEntity, Message: "invalid identity id"}
+	}
+
+	token := c.Locals("user").(*jwt.Token)
+	userId, err := jwt_utils.GetUserId(token)
+	if err != nil {
+		return &fiber.Error{Code: fiber.StatusUnauthorized}
+	}
+
+	err = r.oidcService.Unlink(c.UserContext(), userId, id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return &fiber.Error{Code: fiber.StatusNotFound, Message: "identity not found"}
+		}
+		return oidcError(err, "failed to unlink identity")
+	}
+
+	return nil
+}
+
+func oidcError(err error, message string) error {
+	switch {
+	case errors.Is(err, oidc_service.ErrOidcDisabled):
+		return &fiber.Error{Code: fiber.StatusNotFound, Message: err.Error()}
+	case errors.Is(err, oidc_service.ErrInvalidState), errors.Is(err, oidc_service.ErrInvalidIdToken),
+		errors.Is(err, oidc_service.ErrInvalidLoginCode):
+		return &fiber.Error{Code: fiber.StatusUnauthorized, Message: err.Error()}
+	case errors.Is(err, oidc_service.ErrUnknownIdentity), errors.Is(err, oidc_service.ErrUserDisabled):
+		return &fiber.Error{Code: fiber.StatusForbidden, Message: err.Error()}
+	case errors.Is(err, oidc_service.ErrIdentityTaken), errors.Is(err, oidc_service.ErrLastCredential):
+		return &fiber.Error{Code: fiber.StatusConflict, Message: err.Error()}
+	}
+
+	return errors.Wrap(err, message)
+}
@@ -0,0 +1,60 @@
+package web
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"main.go/schemas"
+	"main.go/utils/settings_utils"
+	"strings"
+)
+
+// pagination reads page and pageSize. pageSize is capped at the MAX_PAGE_SIZE setting.
+func pagination(c *fiber.Ctx, defaultPageSize int) (int, int, error) {
+	page := c.QueryInt("page")
+	pageSize := c.QueryInt("pageSize", defaultPageSize)
+	if page < 0 || pageSize < 1 {
+		return 0, 0, &fiber.Error{Code: fiber.StatusUnprocessableEntity}
+	}
+
+	return page, min(pageSize, settings_utils.Settings.MaxPageSize), nil
+}
+
+// pageRequest reads the paging of book listings: a cursor, or a page for offset paging, pageSize,
+// sortBy, orderBy and total. A cursor brings its own sort, which then overrides sortBy and orderBy.
+func pageRequest(c *fiber.Ctx, defaultSort string) (*schemas.PageRequest, error) {
+	page, pageSize, err := pagination(c, 20)
+	if err != nil {
+		return nil, err
+	}
+
+	request := schemas.PageRequest{
+		Page:     page,
+		PageSize: pageSize,
+		SortBy:   c.Query("sortBy", defaultSort),
+		OrderBy:  strings.ToUpper(c.Query("orderBy", "DESC")),
+		Total:    c.Query("total", schemas.TotalExact),
+	}
+	if request.Total != schemas.TotalExact && request.Total != schemas.TotalEstimate && request.Total != schemas.TotalNone {
+		return nil, &fiber.Error{Code: fiber.StatusUnprocessableEntity, Message: "total must be exact, estimate or none"}
+	}
+
+	if c.Query("cursor") != "" {
+		request.Cursor, err = schemas.ParseCursor(c.Query("cursor"))
+		if err != nil || VerifySort(request.Cursor.SortBy) != nil {
+			return nil, &fiber.Error{Code: fiber.StatusUnprocessableEntity, Message: "invalid cursor"}
+		}
+		request.SortBy = request.Cursor.SortBy
+		request.OrderBy = request.Cursor.OrderBy
+	}
+
+	err = VerifySort(request.SortBy)
+	if err != nil {
+		request.SortBy = defaultSort
+	}
+
+	err = VerifyOrder(request.OrderBy)
+	if err != nil {
+		request.OrderBy = "DESC"
+	}
+
+	return &request, nil
+}
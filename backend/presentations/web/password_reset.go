@@ -0,0 +1,52 @@
+package web
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/pkg/errors"
+	"main.go/schemas"
+	"main.go/services/password_reset_service"
+	validators_utils "main.go/utils/validator_utils"
+)
+
+func (r *Presentation) requestPasswordReset(c *fiber.Ctx) error {
+	var request schemas.PasswordResetRequest
+	err := c.BodyParser(&request)
+	if err != nil {
+		return &fiber.Error{Code: fiber.StatusUnprocessableEntity}
+	}
+
+	err = validators_utils.Validate.Struct(&request)
+	if err != nil {
+		return &fiber.Error{Code: fiber.StatusUnprocessableEntity, Message: err.Error()}
+	}
+
+	err = r.passwordResetService.RequestReset(c.UserContext(), request.Email)
+	if err != nil {
+		return errors.Wrap(err, "failed to request password reset")
+	}
+
+	return c.SendStatus(fiber.StatusAccepted)
+}
+
+func (r *Presentation) confirmPasswordReset(c *fiber.Ctx) error {
+	var request schemas.PasswordResetConfirmRequest
+	err := c.BodyParser(&request)
+	if err != nil {
+		return &fiber.Error{Code: fiber.StatusUnprocessableEntity}
+	}
+
+	err = validators_utils.Validate.Struct(&request)
+	if err != nil {
+		return &fiber.Error{Code: fiber.StatusUnprocessableEntity, Message: err.Error()}
+	}
+
+	err = r.passwordResetService.ConfirmReset(c.UserContext(), &request)
+	if err != nil {
+		if errors.Is(err, password_reset_service.ErrInvalidResetToken) {
+			return &fiber.Error{Code: fiber.StatusBadRequest, Message: err.Error()}
+		}
+		return errors.Wrap(err, "failed to reset password")
+	}
+
+	return nil
+}
@@ -0,0 +1,125 @@
+package web
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+	"main.go/schemas"
+	"main.go/services/user_service"
+	"main.go/utils/jwt_utils"
+	validators_utils "main.go/utils/validator_utils"
+)
+
+func (r *Presentation) getProfile(c *fiber.Ctx) error {
+	token := c.Locals("user").(*jwt.Token)
+	userId, err := jwt_utils.GetUserId(token)
+	if err != nil {
+		return &fiber.Error{Code: fiber.StatusUnauthorized}
+	}
+
+	user, err := r.userService.GetUser(c.UserContext(), userId)
+	if err != nil {
+		return profileError(err, "failed to get profile")
+	}
+
+	return c.JSON(fiber.Map{"user": user})
+}
+
+func (r *Presentation) updateProfile(c *fiber.Ctx) error {
+	var request schemas.ProfileRequest
+	err := c.BodyParser(&request)
+	if err != nil {
+		return &fiber.Error{Code: fiber.StatusUnprocessableEntity}
+	}
+
+	err = validators_utils.Validate.Struct(&request)
+	if err != nil {
+		return &fiber.Error{Code: fiber.StatusUnprocessableEntity, Message: err.Error()}
+	}
+
+	token := c.Locals("user").(*jwt.Token)
+	userId, err := jwt_utils.GetUserId(token)
+	if err != nil {
+		return &fiber.Error{Code: fiber.StatusUnauthorized}
+	}
+
+	user, err := r.userService.UpdateProfile(c.UserContext(), userId, &request)
+	if err != nil {
+		return profileError(err, "failed to update profile")
+	}
+
+	return c.JSON(fiber.Map{"user": user})
+}
+
+func (r *Presentation) changePassword(c *fiber.Ctx) error {
+	var request schemas.PasswordChangeRequest
+	err := c.BodyParser(&request)
+	if err != nil {
+		return &fiber.Error{Code: fiber.StatusUnprocessableEntity}
+	}
+
+	err = validators_utils.Validate.Struct(&request)
+	if err != nil {
+		return &fiber.Error{Code: fiber.StatusUnprocessableEntity, Message: err.Error()}
+	}
+
+	token := c.Locals("user").(*jwt.Token)
+	userId, err := jwt_utils.GetUserId(token)
+	if err != nil {
+		return &fiber.Error{Code: fiber.StatusUnauthorized}
+	}
+
+	user, err := r.userService.ChangePassword(c.UserContext(), userId, &request)
+	if err != nil {
+		return profileError(err, "failed to change password")
+	}
+
+	tokens, err := r.authService.IssueTokens(c.UserContext(), user, uuid.Nil)
+	if err != nil {
+		return errors.Wrap(err, "failed to issue tokens")
+	}
+
+	return c.JSON(tokens)
+}
+
+func (r *Presentation) deleteAccount(c *fiber.Ctx) error {
+	var request schemas.AccountDeletionRequest
+	err := c.BodyParser(&request)
+	if err != nil {
+		return &fiber.Error{Code: fiber.StatusUnprocessableEntity}
+	}
+
+	err = validators_utils.Validate.Struct(&request)
+	if err != nil {
+		return &fiber.Error{Code: fiber.StatusUnprocessableEntity, Message: err.Error()}
+	}
+
+	token := c.Locals("user").(*jwt.Token)
+	userId, err := jwt_utils.GetUserId(token)
+	if err != nil {
+		return &fiber.Error{Code: fiber.StatusUnauthorized}
+	}
+
+	err = r.userService.DeleteAccount(c.UserContext(), userId, request.Password)
+	if err != nil {
+		return profileError(err, "failed to delete account")
+	}
+
+	return nil
+}
+
+func profileError(err error, message string) error {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return &fiber.Error{Code: fiber.StatusUnauthorized}
+	case errors.Is(err, user_service.ErrWrongPassword):
+		return &fiber.Error{Code: fiber.StatusForbidden, Message: err.Error()}
+	case errors.Is(err, user_service.ErrUsernameTaken), errors.Is(err, user_service.ErrEmailTaken),
+		errors.Is(err, user_service.ErrLastAdmin):
+		return &fiber.Error{Code: fiber.StatusConflict, Message: err.Error()}
+	}
+
+	return errors.Wrap(err, message)
+}
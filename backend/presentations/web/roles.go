@@ -0,0 +1,72 @@
+package web
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+	"main.go/schemas"
+	"main.go/services/role_service"
+	validators_utils "main.go/utils/validator_utils"
+)
+
+func (r *Presentation) listRoles(c *fiber.Ctx) error {
+	roles, err := r.roleService.ListRoles(c.UserContext())
+	if err != nil {
+		return errors.Wrap(err, "failed to list roles")
+	}
+
+	return c.JSON(fiber.Map{"roles": roles, "permissions": schemas.Permissions})
+}
+
+func (r *Presentation) saveRole(c *fiber.Ctx) error {
+	var role schemas.Role
+	err := c.BodyParser(&role)
+	if err != nil {
+		return &fiber.Error{Code: fiber.StatusUnprocessableEntity}
+	}
+
+	err = validators_utils.Validate.StructPartial(&role, "Name")
+	if err != nil {
+		return &fiber.Error{Code: fiber.StatusUnprocessableEntity, Message: err.Error()}
+	}
+
+	err = r.roleService.SaveRole(c.UserContext(), &role)
+	if err != nil {
+		if errors.Is(err, role_service.ErrUnknownPermission) {
+			return &fiber.Error{Code: fiber.StatusUnprocessableEntity, Message: err.Error()}
+		}
+		if errors.Is(err, role_service.ErrRoleExists) {
+			return &fiber.Error{Code: fiber.StatusConflict, Message: err.Error()}
+		}
+		return errors.Wrap(err, "failed to save role")
+	}
+
+	c.Status(fiber.StatusCreated)
+	return c.JSON(fiber.Map{"role": role})
+}
+
+func (r *Presentation) updateRole(c *fiber.Ctx) error {
+	name := c.Params("name")
+
+	var role schemas.Role
+	err := c.BodyParser(&role)
+	if err != nil {
+		return &fiber.Error{Code: fiber.StatusUnprocessableEntity}
+	}
+
+	err = r.roleService.UpdateRole(c.UserContext(), name, &role)
+	if err != nil {
+		if errors.Is(err, role_service.ErrUnknownPermission) {
+			return &fiber.Error{Code: fiber.StatusUnprocessableEntity, Message: err.Error()}
+		}
+		if errors.Is(err, role_service.ErrBuiltinRole) {
+			return &fiber.Error{Code: fiber.StatusConflict, Message: err.Error()}
+		}
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return &fiber.Error{Code: fiber.StatusNotFound, Message: "role not found"}
+		}
+		return errors.Wrap(err, "failed to update role")
+	}
+
+	return nil
+}
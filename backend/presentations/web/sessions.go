@@ -0,0 +1,104 @@
+package web
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+	"main.go/utils/jwt_utils"
+)
+
+func (r *Presentation) listSessions(c *fiber.Ctx) error {
+	token := c.Locals("user").(*jwt.Token)
+	userId, err := jwt_utils.GetUserId(token)
+	if err != nil {
+		return &fiber.Error{Code: fiber.StatusUnauthorized}
+	}
+	currentId, _ := jwt_utils.GetSessionId(token)
+
+	page, pageSize, err := pagination(c, 20)
+	if err != nil {
+		return err
+	}
+
+	sessions, err := r.sessionService.ListSessions(c.UserContext(), userId, currentId, page, pageSize)
+	if err != nil {
+		return sessionError(err, "failed to list sessions")
+	}
+
+	return c.JSON(fiber.Map{"sessions": sessions})
+}
+
+func (r *Presentation) revokeSession(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return &fiber.Error{Code: fiber.StatusUnprocessableEntity, Message: "invalid session id"}
+	}
+
+	token := c.Locals("user").(*jwt.Token)
+	userId, err := jwt_utils.GetUserId(token)
+	if err != nil {
+		return &fiber.Error{Code: fiber.StatusUnauthorized}
+	}
+
+	err = r.sessionService.RevokeSession(c.UserContext(), userId, userId, id)
+	if err != nil {
+		return sessionError(err, "failed to revoke session")
+	}
+
+	return nil
+}
+
+func (r *Presentation) listUserSessions(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return &fiber.Error{Code: fiber.StatusUnprocessableEntity, Message: "invalid user id"}
+	}
+
+	token := c.Locals("user").(*jwt.Token)
+	currentId, _ := jwt_utils.GetSessionId(token)
+
+	page, pageSize, err := pagination(c, 20)
+	if err != nil {
+		return err
+	}
+
+	sessions, err := r.sessionService.ListSessions(c.UserContext(), id, currentId, page, pageSize)
+	if err != nil {
+		return sessionError(err, "failed to list user sessions")
+	}
+
+	return c.JSON(fiber.Map{"sessions": sessions})
+}
+
+func (r *Presentation) revokeUserSession(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return &fiber.Error{Code: fiber.StatusUnprocessableEntity, Message: "invalid user id"}
+	}
+	sessionId, err := uuid.Parse(c.Params("sessionId"))
+	if err != nil {
+		return &fiber.Error{Code: fiber.StatusUnprocessableEntity, Message: "invalid session id"}
+	}
+
+	token := c.Locals("user").(*jwt.Token)
+	actorId, err := jwt_utils.GetUserId(token)
+	if err != nil {
+		return &fiber.Error{Code: fiber.StatusUnauthorized}
+	}
+
+	err = r.sessionService.RevokeSession(c.UserContext(), actorId, id, sessionId)
+	if err != nil {
+		return sessionError(err, "failed to revoke user session")
+	}
+
+	return nil
+}
+
+func sessionError(err error, message string) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return &fiber.Error{Code: fiber.StatusNotFound, Message: "session not found"}
+	}
+	return errors.Wrap(err, message)
+}
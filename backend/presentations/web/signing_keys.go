@@ -0,0 +1,44 @@
+package web
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/pkg/errors"
+	"main.go/utils/jwt_utils"
+)
+
+// jwks publishes the public keys so that other services can verify our tokens without a shared secret.
+func (r *Presentation) jwks(c *fiber.Ctx) error {
+	c.Set(fiber.HeaderCacheControl, "public, max-age=300")
+	return c.JSON(r.signingKeyService.Jwks())
+}
+
+func (r *Presentation) listSigningKeys(c *fiber.Ctx) error {
+	page, pageSize, err := pagination(c, 20)
+	if err != nil {
+		return err
+	}
+
+	keys, err := r.signingKeyService.ListKeys(c.UserContext(), page, pageSize)
+	if err != nil {
+		return errors.Wrap(err, "failed to list signing keys")
+	}
+
+	return c.JSON(fiber.Map{"keys": keys})
+}
+
+func (r *Presentation) rotateSigningKey(c *fiber.Ctx) error {
+	token := c.Locals("user").(*jwt.Token)
+	actorId, err := jwt_utils.GetUserId(token)
+	if err != nil {
+		return &fiber.Error{Code: fiber.StatusUnauthorized}
+	}
+
+	key, err := r.signingKeyService.Rotate(c.UserContext(), actorId)
+	if err != nil {
+		return errors.Wrap(err, "failed to rotate signing key")
+	}
+
+	c.Status(fiber.StatusCreated)
+	return c.JSON(fiber.Map{"key": key})
+}
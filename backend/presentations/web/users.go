@@ -0,0 +1,139 @@
+package web
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+	"main.go/schemas"
+	"main.go/services/user_service"
+	"main.go/utils/jwt_utils"
+	validators_utils "main.go/utils/validator_utils"
+)
+
+func (r *Presentation) listUsers(c *fiber.Ctx) error {
+	page, pageSize, err := pagination(c, 20)
+	if err != nil {
+		return err
+	}
+
+	users, total, err := r.userService.ListUsers(c.UserContext(), page, pageSize, c.Query("q"), c.Query("status"))
+	if err != nil {
+		return errors.Wrap(err, "failed to list users")
+	}
+
+	return c.JSON(fiber.Map{"users": users, "total": total})
+}
+
+func (r *Presentation) userInfo(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return &fiber.Error{Code: fiber.StatusUnprocessableEntity, Message: "invalid user id"}
+	}
+
+	user, err := r.userService.GetUser(c.UserContext(), id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return &fiber.Error{Code: fiber.StatusNotFound, Message: "user not found"}
+		}
+		return errors.Wrap(err, "failed to get user")
+	}
+
+	return c.JSON(fiber.Map{"user": user})
+}
+
+func (r *Presentation) setUserRoles(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return &fiber.Error{Code: fiber.StatusUnprocessableEntity, Message: "invalid user id"}
+	}
+
+	var request schemas.UserRolesRequest
+	err = c.BodyParser(&request)
+	if err != nil {
+		return &fiber.Error{Code: fiber.StatusUnprocessableEntity}
+	}
+
+	err = validators_utils.Validate.Struct(&request)
+	if err != nil {
+		return &fiber.Error{Code: fiber.StatusUnprocessableEntity, Message: err.Error()}
+	}
+
+	token := c.Locals("user").(*jwt.Token)
+	actorId, err := jwt_utils.GetUserId(token)
+	if err != nil {
+		return &fiber.Error{Code: fiber.StatusUnauthorized}
+	}
+
+	err = r.userService.SetRoles(c.UserContext(), actorId, id, request.Roles)
+	if err != nil {
+		return userManagementError(err, "failed to set user roles")
+	}
+
+	return nil
+}
+
+func (r *Presentation) setUserStatus(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return &fiber.Error{Code: fiber.StatusUnprocessableEntity, Message: "invalid user id"}
+	}
+
+	var request schemas.UserStatusRequest
+	err = c.BodyParser(&request)
+	if err != nil {
+		return &fiber.Error{Code: fiber.StatusUnprocessableEntity}
+	}
+
+	err = validators_utils.Validate.Struct(&request)
+	if err != nil {
+		return &fiber.Error{Code: fiber.StatusUnprocessableEntity, Message: err.Error()}
+	}
+
+	token := c.Locals("user").(*jwt.Token)
+	actorId, err := jwt_utils.GetUserId(token)
+	if err != nil {
+		return &fiber.Error{Code: fiber.StatusUnauthorized}
+	}
+
+	err = r.userService.SetStatus(c.UserContext(), actorId, id, &request)
+	if err != nil {
+		return userManagementError(err, "failed to set user status")
+	}
+
+	return nil
+}
+
+func (r *Presentation) deleteUser(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return &fiber.Error{Code: fiber.StatusUnprocessableEntity, Message: "invalid user id"}
+	}
+
+	token := c.Locals("user").(*jwt.Token)
+	actorId, err := jwt_utils.GetUserId(token)
+	if err != nil {
+		return &fiber.Error{Code: fiber.StatusUnauthorized}
+	}
+
+	err = r.userService.DeleteUser(c.UserContext(), actorId, id)
+	if err != nil {
+		return userManagementError(err, "failed to delete user")
+	}
+
+	return nil
+}
+
+func userManagementError(err error, message string) error {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return &fiber.Error{Code: fiber.StatusNotFound, Message: "user not found"}
+	case errors.Is(err, user_service.ErrUnknownRole):
+		return &fiber.Error{Code: fiber.StatusUnprocessableEntity, Message: err.Error()}
+	case errors.Is(err, user_service.ErrSelfModification), errors.Is(err, user_service.ErrLastAdmin):
+		return &fiber.Error{Code: fiber.StatusConflict, Message: errors.Cause(err).Error()}
+	}
+
+	return errors.Wrap(err, message)
+}
@@ -0,0 +1,108 @@
+package api_key_repository
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+	"main.go/schemas"
+	"time"
+)
+
+type Repository struct {
+	db *gorm.DB
+}
+
+func NewRepository(db *gorm.DB) *Repository {
+	return &Repository{db: db}
+}
+
+func (r *Repository) SaveApiKey(ctx context.Context, apiKey *schemas.ApiKey) error {
+	err := r.db.WithContext(ctx).Table("api_key").Create(apiKey).Error
+	if err != nil {
+		return errors.Wrap(err, "save api key repo")
+	}
+
+	return nil
+}
+
+func (r *Repository) GetApiKeys(ctx context.Context, userId uuid.UUID) (*[]schemas.ApiKey, error) {
+	var apiKeys []schemas.ApiKey
+	err := r.db.WithContext(ctx).Table("api_key").
+		Where("user_id", userId).
+		Where("revoked_at IS NULL").
+		Order("created_at DESC").
+		Find(&apiKeys).Error
+	if err != nil {
+		return nil, errors.Wrap(err, "get api keys repo")
+	}
+
+	return &apiKeys, nil
+}
+
+func (r *Repository) GetApiKeyByHash(ctx context.Context, keyHash string) (*schemas.ApiKey, error) {
+	var apiKey schemas.ApiKey
+	row := r.db.WithContext(ctx).Table("api_key").Where("key_hash", keyHash).Find(&apiKey)
+	if row.Error != nil {
+		return nil, errors.Wrap(row.Error, "get api key repo")
+	}
+	if row.RowsAffected == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	return &apiKey, nil
+}
+
+func (r *Repository) CountApiKeys(ctx context.Context, userId uuid.UUID) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Table("api_key").
+		Where("user_id", userId).
+		Where("revoked_at IS NULL").
+		Count(&count).Error
+	if err != nil {
+		return 0, errors.Wrap(err, "count api keys repo")
+	}
+
+	return count, nil
+}
+
+// TouchApiKey records a use. Writes are skipped while the stored value is younger than
+// precision, so that busy scripts don't cause a write per request.
+func (r *Repository) TouchApiKey(ctx context.Context, id uuid.UUID, now time.Time, precision time.Duration) error {
+	err := r.db.WithContext(ctx).Table("api_key").
+		Where("id", id).
+		Where("last_used_at IS NULL OR last_used_at < ?", now.Add(-precision)).
+		Update("last_used_at", now).Error
+	if err != nil {
+		return errors.Wrap(err, "touch api key repo")
+	}
+
+	return nil
+}
+
+// RevokeApiKey reports false if the user has no such active key.
+func (r *Repository) RevokeApiKey(ctx context.Context, id, userId uuid.UUID, now time.Time) (bool, error) {
+	row := r.db.WithContext(ctx).Table("api_key").
+		Where("id", id).
+		Where("user_id", userId).
+		Where("revoked_at IS NULL").
+		Update("revoked_at", now)
+	if row.Error != nil {
+		return false, errors.Wrap(row.Error, "revoke api key repo")
+	}
+
+	return row.RowsAffected == 1, nil
+}
+
+// AnonymizeUser revokes every key of a deleted account.
+func (r *Repository) AnonymizeUser(ctx context.Context, userId uuid.UUID) error {
+	err := r.db.WithContext(ctx).Table("api_key").
+		Where("user_id", userId).
+		Where("revoked_at IS NULL").
+		Update("revoked_at", time.Now().UTC()).Error
+	if err != nil {
+		return errors.Wrap(err, "anonymize api keys repo")
+	}
+
+	return nil
+}
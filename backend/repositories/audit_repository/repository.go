@@ -0,0 +1,52 @@
+package audit_repository
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+	"main.go/schemas"
+	"time"
+)
+
+type Repository struct {
+	db *gorm.DB
+}
+
+func NewRepository(db *gorm.DB) *Repository {
+	return &Repository{db: db}
+}
+
+func (r *Repository) Record(ctx context.Context, actorId uuid.UUID, action string, targetId uuid.UUID, details map[string]interface{}) error {
+	entry := schemas.AuditLog{
+		ID:        uuid.New(),
+		ActorId:   actorId,
+		Action:    action,
+		TargetId:  targetId,
+		Details:   details,
+		CreatedAt: time.Now().UTC(),
+	}
+	err := r.db.WithContext(ctx).Table("audit_log").Create(&entry).Error
+	if err != nil {
+		return errors.Wrap(err, "record audit log repo")
+	}
+
+	return nil
+}
+
+func (r *Repository) GetAuditLogs(ctx context.Context, page int, pageSize int, action string) (*[]schemas.AuditLog, error) {
+	var logs *[]schemas.AuditLog
+	query := r.db.WithContext(ctx).Table("audit_log")
+	if action != "" {
+		query = query.Where("action", action)
+	}
+
+	err := query.Order("created_at DESC").
+		Limit(pageSize).Offset(page * pageSize).
+		Find(&logs).Error
+	if err != nil {
+		return nil, errors.Wrap(err, "get audit logs repo")
+	}
+
+	return logs, nil
+}
@@ -0,0 +1,130 @@
+package author_repository
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"main.go/schemas"
+	"time"
+)
+
+type Repository struct {
+	db *gorm.DB
+}
+
+func NewRepository(db *gorm.DB) *Repository {
+	return &Repository{db: db}
+}
+
+func (r *Repository) GetAuthor(ctx context.Context, id uuid.UUID) (*schemas.Author, error) {
+	var author schemas.Author
+	row := r.db.WithContext(ctx).Table("author").Where("id", id).Find(&author)
+	if row.Error != nil {
+		return nil, errors.Wrap(row.Error, "get author repo")
+	}
+
+	if row.RowsAffected == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	return &author, nil
+}
+
+// FindAuthor looks the name up among author names and aliases.
+func (r *Repository) FindAuthor(ctx context.Context, name string) (*schemas.Author, error) {
+	var author schemas.Author
+	row := r.db.WithContext(ctx).Table("author").
+		Where("name = ? OR JSON_CONTAINS(aliases, JSON_QUOTE(?))", name, name).
+		Limit(1).
+		Find(&author)
+	if row.Error != nil {
+		return nil, errors.Wrap(row.Error, "find author repo")
+	}
+
+	if row.RowsAffected == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	return &author, nil
+}
+
+// FindOrCreateAuthor returns the author known under name, creating one if there is none.
+// A concurrent creation of the same name is resolved by reading the winner back.
+func (r *Repository) FindOrCreateAuthor(ctx context.Context, name string, now time.Time) (*schemas.Author, error) {
+	author, err := r.FindAuthor(ctx, name)
+	if err == nil || !errors.Is(err, gorm.ErrRecordNotFound) {
+		return author, err
+	}
+
+	err = r.db.WithContext(ctx).Table("author").
+		Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&schemas.Author{ID: uuid.New(), Name: name, Aliases: []string{}, CreatedAt: now, UpdatedAt: now}).Error
+	if err != nil {
+		return nil, errors.Wrap(err, "find or create author repo")
+	}
+
+	return r.FindAuthor(ctx, name)
+}
+
+func (r *Repository) UpdateAuthor(ctx context.Context, id uuid.UUID, author *schemas.Author) error {
+	row := r.db.WithContext(ctx).Table("author").
+		Where("id", id).Select("name", "biography", "aliases", "updated_at").
+		Updates(author)
+	if row.Error != nil {
+		return errors.Wrap(row.Error, "update author repo")
+	}
+
+	if row.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// MergeAuthor moves the books of source to target and deletes source, saving target's aliases
+// in the same transaction. Links target already has in the same role are dropped with source.
+// It reports false when either author no longer exists.
+func (r *Repository) MergeAuthor(ctx context.Context, target *schemas.Author, sourceId uuid.UUID) (bool, error) {
+	merged := false
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var ids []uuid.UUID
+		err := tx.Table("author").Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id IN ?", []uuid.UUID{target.ID, sourceId}).
+			Pluck("id", &ids).Error
+		if err != nil {
+			return err
+		}
+		if len(ids) != 2 {
+			return nil
+		}
+
+		err = tx.Exec(`INSERT IGNORE INTO book_author (book_id, author_id, role, position, created_at)
+			SELECT book_id, ?, role, position, created_at FROM book_author WHERE author_id = ?`,
+			target.ID, sourceId).Error
+		if err != nil {
+			return err
+		}
+
+		err = tx.Table("author").Where("id", sourceId).Delete(&schemas.Author{}).Error
+		if err != nil {
+			return err
+		}
+
+		err = tx.Table("author").Where("id", target.ID).
+			Select("aliases", "updated_at").
+			Updates(target).Error
+		if err != nil {
+			return err
+		}
+
+		merged = true
+		return nil
+	})
+	if err != nil {
+		return false, errors.Wrap(err, "merge author repo")
+	}
+
+	return merged, nil
+}
@@ -2,13 +2,21 @@ package book_repository
 
 import (
 	"context"
+	"encoding/json"
 	"github.com/google/uuid"
 	"github.com/pkg/errors"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 	"main.go/schemas"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 )
 
+// estimateThreshold is the number of books an estimated total counts exactly.
+const estimateThreshold = 10000
+
 type Repository struct {
 	db *gorm.DB
 }
@@ -17,128 +25,675 @@ func NewRepository(db *gorm.DB) *Repository {
 	return &Repository{db: db}
 }
 
-func (r *Repository) GetBooks(ctx context.Context, page int, pageSize int, sortBy, orderBy string) (*[]schemas.Book, error) {
-	var books *[]schemas.Book
-	err := r.db.WithContext(ctx).Table("book").
-		Limit(pageSize).Offset(page * pageSize).Where("deleted_at IS NULL").
-		Order(sortBy + " " + orderBy).
-		Find(&books).Error
-	if err != nil {
-		return nil, errors.Wrap(err, "failed to find books")
+func (r *Repository) BookInfo(ctx context.Context, id uuid.UUID) (*schemas.Book, error) {
+	var book schemas.Book
+	row := r.db.WithContext(ctx).Table("book").Where("id", id).Find(&book)
+	if row.Error != nil {
+		return nil, errors.Wrap(row.Error, "get book info")
+	}
+
+	if row.RowsAffected == 0 {
+		return nil, gorm.ErrRecordNotFound
 	}
 
-	return books, nil
+	books := []schemas.Book{book}
+	err := r.loadRelations(ctx, books)
+	if err != nil {
+		return nil, errors.Wrap(err, "get book info")
+	}
+	return &books[0], nil
 }
 
-func (r *Repository) GetBooksByCategory(ctx context.Context, page int, pageSize int, categoryName string, sortBy, orderBy string) (*[]schemas.Book, error) {
-	var books []schemas.Book
-	var category schemas.Category
+func (r *Repository) SaveBook(ctx context.Context, book *schemas.Book) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Table("book").Save(&book).Error
+		if err != nil {
+			return errors.Wrap(err, "save book repo")
+		}
 
-	err := r.db.Transaction(func(tx *gorm.DB) error {
-		row := r.db.WithContext(ctx).Table("category").
-			Where("name", categoryName).Where("deleted_at IS NULL").
-			Find(&category)
-		if row.Error != nil {
-			return errors.Wrap(row.Error, "failed to find category")
+		err = setCategories(tx, book.ID, book.CategoryIds, book.CreatedAt)
+		if err != nil {
+			return errors.Wrap(err, "save book repo")
 		}
 
-		if row.RowsAffected == 0 {
-			return gorm.ErrRecordNotFound
+		err = setAuthors(tx, book.ID, book.Contributors, book.CreatedAt)
+		if err != nil {
+			return errors.Wrap(err, "save book repo")
 		}
 
-		err := r.db.WithContext(ctx).Table("book"). //TODO: rewrite for something more adequate
-								Where("categories LIKE ?", "%"+category.ID.String()+"%").
-								Where("deleted_at IS NULL").
-								Limit(pageSize).Offset(page * pageSize).
-								Find(&books).Error
+		return nil
+	})
+}
+
+// UpdateBook replaces the book's categories only when CategoryIds is set
+// and its authors only when Contributors is set.
+func (r *Repository) UpdateBook(ctx context.Context, id uuid.UUID, book *schemas.Book) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Table("book").
+			Where("id", id).Omit("id", "created_at", "deleted_at").
+			Updates(&book).Error
 		if err != nil {
-			return errors.Wrap(err, "failed to find books")
+			return errors.Wrap(err, "update book repo")
 		}
+
+		if book.CategoryIds != nil {
+			err = tx.Table("book_category").Where("book_id", id).Delete(&schemas.BookCategory{}).Error
+			if err != nil {
+				return errors.Wrap(err, "update book repo")
+			}
+
+			err = setCategories(tx, id, book.CategoryIds, book.UpdatedAt)
+			if err != nil {
+				return errors.Wrap(err, "update book repo")
+			}
+		}
+
+		if book.Contributors != nil {
+			err = tx.Table("book_author").Where("book_id", id).Delete(&schemas.BookAuthor{}).Error
+			if err != nil {
+				return errors.Wrap(err, "update book repo")
+			}
+
+			err = setAuthors(tx, id, book.Contributors, book.UpdatedAt)
+			if err != nil {
+				return errors.Wrap(err, "update book repo")
+			}
+		}
+
 		return nil
 	})
+}
+
+// CountCategories returns how many of ids name existing categories, to validate links before saving them.
+func (r *Repository) CountCategories(ctx context.Context, ids []uuid.UUID) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Table("category").
+		Where("id IN ?", ids).Where("deleted_at IS NULL").
+		Count(&count).Error
 	if err != nil {
-		return nil, errors.Wrap(err, "transaction")
+		return 0, errors.Wrap(err, "count categories repo")
 	}
 
-	return &books, nil
+	return count, nil
+}
+
+func (r *Repository) DeleteBook(ctx context.Context, id uuid.UUID) error {
+	err := r.db.WithContext(ctx).Table("book").
+		Where("id", id).
+		Update("deleted_at", time.Now().UTC()).Error
+
+	if err != nil {
+		return errors.Wrap(err, "delete book repo")
+	}
 
+	return nil
 }
 
-func (r *Repository) BookInfo(ctx context.Context, id uuid.UUID) (*schemas.Book, error) {
-	var book schemas.Book
-	row := r.db.WithContext(ctx).Table("book").Where("id", id).Find(&book)
-	if row.Error != nil {
-		return nil, errors.Wrap(row.Error, "get book info")
+// GetBooksByIds returns the live books among ids, in no particular order.
+func (r *Repository) GetBooksByIds(ctx context.Context, ids []uuid.UUID) (*[]schemas.Book, error) {
+	var books []schemas.Book
+	err := r.db.WithContext(ctx).Table("book").
+		Where("id IN ?", ids).Where("deleted_at IS NULL").
+		Find(&books).Error
+	if err != nil {
+		return nil, errors.Wrap(err, "get books by ids repo")
 	}
 
-	if row.RowsAffected == 0 {
-		return nil, gorm.ErrRecordNotFound
+	err = r.loadRelations(ctx, books)
+	if err != nil {
+		return nil, errors.Wrap(err, "get books by ids repo")
 	}
-	return &book, nil
+
+	return &books, nil
 }
 
-func (r *Repository) SaveBook(ctx context.Context, book *schemas.Book) error {
-	err := r.db.WithContext(ctx).Table("book").Save(&book).Error
+// PageBooks returns the page of live books matching filter selected by request. The sort is
+// completed by the id, which makes it total and lets a cursor resume right after a book.
+func (r *Repository) PageBooks(ctx context.Context, filter *schemas.BookFilter, request *schemas.PageRequest) (*schemas.BookPage, error) {
+	query := where(r.db.WithContext(ctx), filter)
+	if request.Cursor != nil {
+		comparison := ">"
+		if request.OrderBy == "DESC" {
+			comparison = "<"
+		}
+		query = query.Where("("+sortKey(request.SortBy)+", id) "+comparison+" (?, ?)", request.Cursor.Arg(), request.Cursor.ID)
+	} else {
+		query = query.Offset(request.Page * request.PageSize)
+	}
+
+	// One book more than asked tells whether there is a next page.
+	var books []schemas.Book
+	err := query.Limit(request.PageSize + 1).Order(order(request.SortBy, request.OrderBy)).Find(&books).Error
 	if err != nil {
-		return errors.Wrap(err, "save book repo")
+		return nil, errors.Wrap(err, "page books repo")
 	}
 
-	return nil
+	more := len(books) > request.PageSize
+	if more {
+		books = books[:request.PageSize]
+	}
+	err = r.loadRelations(ctx, books)
+	if err != nil {
+		return nil, errors.Wrap(err, "page books repo")
+	}
+
+	page := &schemas.BookPage{Books: books, Sort: schemas.Sort{By: request.SortBy, Order: request.OrderBy}, PageSize: request.PageSize}
+	if more {
+		last := &books[len(books)-1]
+		cursor := schemas.Cursor{SortBy: request.SortBy, OrderBy: request.OrderBy, Value: sortValue(last, request.SortBy), ID: last.ID}
+		page.NextCursor = cursor.Encode()
+	}
+
+	page.Total, page.TotalEstimated, err = r.countBooks(ctx, filter, request.Total)
+	if err != nil {
+		return nil, errors.Wrap(err, "page books repo")
+	}
+
+	return page, nil
 }
 
-func (r *Repository) UpdateBook(ctx context.Context, id uuid.UUID, book *schemas.Book) error {
-	err := r.db.WithContext(ctx).Table("book").
-		Where("id", id).Omit("id", "created_at", "deleted_at").
-		Updates(&book).Error
+// FilterBookIds returns the ids of all live books matching filter, in no particular order.
+func (r *Repository) FilterBookIds(ctx context.Context, filter *schemas.BookFilter) ([]uuid.UUID, error) {
+	var ids []uuid.UUID
+	err := where(r.db.WithContext(ctx), filter).Pluck("id", &ids).Error
 	if err != nil {
-		return errors.Wrap(err, "update book repo")
+		return nil, errors.Wrap(err, "filter book ids repo")
 	}
 
-	return nil
+	return ids, nil
 }
 
-func (r *Repository) DeleteBook(ctx context.Context, id uuid.UUID) error {
-	err := r.db.WithContext(ctx).Table("book").
-		Where("id", id).
-		Update("deleted_at", time.Now().UTC()).Error
+// GetFilteredCategoryLinks returns the category links of the live books matching filter.
+func (r *Repository) GetFilteredCategoryLinks(ctx context.Context, filter *schemas.BookFilter) (*[]schemas.BookCategory, error) {
+	db := r.db.WithContext(ctx)
+	var links []schemas.BookCategory
+	err := db.Table("book_category").
+		Where("book_id IN (?)", where(db, filter).Select("id")).
+		Select("book_id", "category_id").
+		Find(&links).Error
+	if err != nil {
+		return nil, errors.Wrap(err, "get filtered category links repo")
+	}
+
+	return &links, nil
+}
 
+// CountAuthors counts the live books matching filter per author, in any role. Only the limit
+// authors with the most books are returned.
+func (r *Repository) CountAuthors(ctx context.Context, filter *schemas.BookFilter, limit int) ([]schemas.FacetCount, error) {
+	db := r.db.WithContext(ctx)
+	var counts []schemas.FacetCount
+	err := db.Table("book_author").
+		Joins("JOIN author ON author.id = book_author.author_id").
+		Where("book_author.book_id IN (?)", where(db, filter).Select("id")).
+		Select("author.id AS id, author.name AS name, COUNT(DISTINCT book_author.book_id) AS count").
+		Group("author.id, author.name").
+		Order("count DESC").Order("name").
+		Limit(limit).
+		Find(&counts).Error
 	if err != nil {
-		return errors.Wrap(err, "delete book repo")
+		return nil, errors.Wrap(err, "count authors repo")
 	}
 
-	return nil
+	return counts, nil
 }
 
-func (r *Repository) SearchBooks(ctx context.Context, page int, pageSize int, phrase string, sortBy, orderBy string) (*[]schemas.Book, error) {
-	var books *[]schemas.Book
-	phrase = "%" + phrase + "%"
-	row := r.db.WithContext(ctx).Table("book").
-		Where("LOWER(name) LIKE LOWER(?)", phrase).Where("deleted_at IS NULL").
-		Limit(pageSize).Offset(page * pageSize).Order(sortBy + " " + orderBy).
-		Find(&books)
-	if row.Error != nil {
-		return nil, errors.Wrap(row.Error, "search books repo")
+// CountPrices counts the live books matching filter in the price buckets starting at 0 and at
+// each of the ascending bounds.
+func (r *Repository) CountPrices(ctx context.Context, filter *schemas.BookFilter, bounds []int) ([]int64, error) {
+	var rows []struct {
+		Bucket int
+		Count  int64
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(bounds)), ", ")
+	args := make([]any, 0, len(bounds))
+	for _, bound := range bounds {
+		args = append(args, bound)
+	}
+	err := where(r.db.WithContext(ctx), filter).
+		Select("INTERVAL(price, "+placeholders+") AS bucket, COUNT(*) AS count", args...).
+		Group("bucket").
+		Find(&rows).Error
+	if err != nil {
+		return nil, errors.Wrap(err, "count prices repo")
+	}
+
+	counts := make([]int64, len(bounds)+1)
+	for _, row := range rows {
+		counts[row.Bucket] = row.Count
 	}
 
-	return books, nil
+	return counts, nil
+}
+
+// EachBook calls fn with batches of all live books, relations loaded.
+func (r *Repository) EachBook(ctx context.Context, batchSize int, fn func(books []schemas.Book) error) error {
+	var books []schemas.Book
+	err := r.db.WithContext(ctx).Table("book").Where("deleted_at IS NULL").
+		FindInBatches(&books, batchSize, func(tx *gorm.DB, _ int) error {
+			err := r.loadRelations(ctx, books)
+			if err != nil {
+				return err
+			}
+			return fn(books)
+		}).Error
+	if err != nil {
+		return errors.Wrap(err, "each book repo")
+	}
+
+	return nil
 }
 
 func (r *Repository) GetBooksInCart(ctx context.Context, bookIds []uuid.UUID) (*[]schemas.Book, error) {
-	var books *[]schemas.Book
+	var books []schemas.Book
 	err := r.db.WithContext(ctx).Table("book").Where("id IN ?", bookIds).Find(&books).Error
 	if err != nil {
 		return nil, errors.Wrap(err, "get books in cart repo")
 	}
 
-	return books, nil
+	err = r.loadRelations(ctx, books)
+	if err != nil {
+		return nil, errors.Wrap(err, "get books in cart repo")
+	}
+
+	return &books, nil
 }
 
-func (r *Repository) GetBookPrice(ctx context.Context, bookId uuid.UUID) (int, error) {
-	var price int
-	err := r.db.WithContext(ctx).Table("book").Where("id", bookId).Select("price").Find(&price).Error
+// GetBooksByAuthor returns the live books the author took part in, in any role.
+func (r *Repository) GetBooksByAuthor(ctx context.Context, authorId uuid.UUID) (*[]schemas.Book, error) {
+	db := r.db.WithContext(ctx)
+	var books []schemas.Book
+	err := db.Table("book").
+		Where("id IN (?)", db.Table("book_author").Select("book_id").Where("author_id", authorId)).
+		Where("deleted_at IS NULL").
+		Order("name").
+		Find(&books).Error
+	if err != nil {
+		return nil, errors.Wrap(err, "get books by author repo")
+	}
+
+	err = r.loadRelations(ctx, books)
 	if err != nil {
-		return 0, errors.Wrap(err, "get book price repo")
+		return nil, errors.Wrap(err, "get books by author repo")
+	}
+
+	return &books, nil
+}
+
+// MigrateCategoryColumn moves the old JSON book.categories column into book_category and drops it.
+// IDs of categories that no longer exist are left behind.
+func (r *Repository) MigrateCategoryColumn(ctx context.Context) error {
+	db := r.db.WithContext(ctx)
+	if !db.Migrator().HasColumn(&schemas.Book{}, "categories") {
+		return nil
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		var rows []struct {
+			ID         uuid.UUID
+			Categories []byte
+		}
+		err := tx.Table("book").Select("id", "categories").
+			Where("categories IS NOT NULL").
+			Find(&rows).Error
+		if err != nil {
+			return errors.Wrap(err, "migrate category column repo")
+		}
+
+		now := time.Now().UTC()
+		for _, row := range rows {
+			var categoryIds []uuid.UUID
+			err = json.Unmarshal(row.Categories, &categoryIds)
+			if err != nil || len(categoryIds) == 0 {
+				continue
+			}
+
+			err = tx.Exec("INSERT IGNORE INTO book_category (book_id, category_id, created_at) "+
+				"SELECT ?, id, ? FROM category WHERE id IN ? AND deleted_at IS NULL", row.ID, now, categoryIds).Error
+			if err != nil {
+				return errors.Wrap(err, "migrate category column repo")
+			}
+		}
+
+		err = tx.Migrator().DropColumn(&schemas.Book{}, "categories")
+		if err != nil {
+			return errors.Wrap(err, "migrate category column repo")
+		}
+
+		return nil
+	})
+}
+
+// MigrateAuthorColumn moves the old JSON book.authors column into author and book_author and drops it.
+// Spellings with the same schemas.AuthorKey and agreeing initials become one author;
+// the other spellings are kept as aliases. Duplicates it misses can be folded with author merges.
+func (r *Repository) MigrateAuthorColumn(ctx context.Context) error {
+	db := r.db.WithContext(ctx)
+	if !db.Migrator().HasColumn(&schemas.Book{}, "authors") {
+		return nil
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		var rows []struct {
+			ID      uuid.UUID
+			Authors []byte
+		}
+		err := tx.Table("book").Select("id", "authors").
+			Where("authors IS NOT NULL").
+			Find(&rows).Error
+		if err != nil {
+			return errors.Wrap(err, "migrate author column repo")
+		}
+
+		now := time.Now().UTC()
+		type group struct {
+			author   *schemas.Author
+			initials string
+		}
+		groups := make(map[string][]*group)
+		var created []*schemas.Author
+		type pending struct {
+			bookId   uuid.UUID
+			author   *schemas.Author
+			position int
+		}
+		var pendings []pending
+		for _, row := range rows {
+			var names []string
+			err = json.Unmarshal(row.Authors, &names)
+			if err != nil {
+				continue
+			}
+
+			seen := make(map[*schemas.Author]bool)
+			for _, name := range names {
+				name = schemas.NormalizeAuthorName(name)
+				key, initials := schemas.AuthorKey(name)
+				if key == "" {
+					continue
+				}
+
+				i := slices.IndexFunc(groups[key], func(g *group) bool {
+					return schemas.InitialsAgree(g.initials, initials)
+				})
+				if i == -1 {
+					author := &schemas.Author{ID: uuid.New(), Name: name, Aliases: []string{}, CreatedAt: now, UpdatedAt: now}
+					groups[key] = append(groups[key], &group{author: author, initials: initials})
+					created = append(created, author)
+					i = len(groups[key]) - 1
+				}
+
+				g := groups[key][i]
+				author := g.author
+				if len(initials) > len(g.initials) {
+					// "Tolstoy" followed by "L. N. Tolstoy" narrows the group to the fuller initials.
+					g.initials = initials
+				}
+				if name != author.Name && !slices.Contains(author.Aliases, name) {
+					author.Aliases = append(author.Aliases, name)
+				}
+
+				if seen[author] {
+					continue
+				}
+				seen[author] = true
+				pendings = append(pendings, pending{bookId: row.ID, author: author, position: len(seen) - 1})
+			}
+		}
+
+		for _, author := range created {
+			row := tx.Table("author").Clauses(clause.OnConflict{DoNothing: true}).Create(author)
+			if row.Error != nil {
+				return errors.Wrap(row.Error, "migrate author column repo")
+			}
+			if row.RowsAffected == 0 {
+				// The collation considers the name equal to one created earlier, e.g. by accents.
+				err = tx.Table("author").Where("name", author.Name).Select("id").Find(&author.ID).Error
+				if err != nil {
+					return errors.Wrap(err, "migrate author column repo")
+				}
+			}
+		}
+
+		links := make([]schemas.BookAuthor, 0, len(pendings))
+		for _, p := range pendings {
+			links = append(links, schemas.BookAuthor{BookId: p.bookId, AuthorId: p.author.ID,
+				Role: schemas.AuthorRoleAuthor, Position: p.position, CreatedAt: now})
+		}
+
+		if len(links) > 0 {
+			err = tx.Table("book_author").Clauses(clause.OnConflict{DoNothing: true}).CreateInBatches(&links, 500).Error
+			if err != nil {
+				return errors.Wrap(err, "migrate author column repo")
+			}
+		}
+
+		err = tx.Migrator().DropColumn(&schemas.Book{}, "authors")
+		if err != nil {
+			return errors.Wrap(err, "migrate author column repo")
+		}
+
+		return nil
+	})
+}
+
+// loadRelations fills the authors, categories and availability of every book.
+func (r *Repository) loadRelations(ctx context.Context, books []schemas.Book) error {
+	for i := range books {
+		books[i].Availability = schemas.Availability(books[i].Stock)
+	}
+
+	err := r.loadAuthors(ctx, books)
+	if err != nil {
+		return err
+	}
+
+	return r.loadCategories(ctx, books)
+}
+
+// loadAuthors fills Authors of every book with one query over book_author.
+func (r *Repository) loadAuthors(ctx context.Context, books []schemas.Book) error {
+	if len(books) == 0 {
+		return nil
+	}
+
+	bookIds := make([]uuid.UUID, len(books))
+	positions := make(map[uuid.UUID]int, len(books))
+	for i := range books {
+		bookIds[i] = books[i].ID
+		positions[books[i].ID] = i
+		books[i].Authors = []schemas.AuthorRef{}
+	}
+
+	var rows []struct {
+		BookId uuid.UUID
+		ID     uuid.UUID
+		Name   string
+		Role   string
+	}
+	err := r.db.WithContext(ctx).Table("book_author").
+		Joins("JOIN author ON author.id = book_author.author_id").
+		Where("book_author.book_id IN ?", bookIds).
+		Order("book_author.position").
+		Select("book_author.book_id", "author.id", "author.name", "book_author.role").
+		Find(&rows).Error
+	if err != nil {
+		return errors.Wrap(err, "load book authors")
+	}
+
+	for _, row := range rows {
+		i := positions[row.BookId]
+		books[i].Authors = append(books[i].Authors, schemas.AuthorRef{ID: row.ID, Name: row.Name, Role: row.Role})
+	}
+
+	return nil
+}
+
+// loadCategories fills Categories of every book with one query over book_category.
+func (r *Repository) loadCategories(ctx context.Context, books []schemas.Book) error {
+	if len(books) == 0 {
+		return nil
+	}
+
+	bookIds := make([]uuid.UUID, len(books))
+	for i := range books {
+		bookIds[i] = books[i].ID
+		books[i].Categories = []schemas.CategoryRef{}
+	}
+
+	var rows []struct {
+		BookId uuid.UUID
+		ID     uuid.UUID
+		Name   string
+	}
+	err := r.db.WithContext(ctx).Table("book_category").
+		Joins("JOIN category ON category.id = book_category.category_id").
+		Where("book_category.book_id IN ?", bookIds).
+		Where("category.deleted_at IS NULL").
+		Order("category.name").
+		Select("book_category.book_id", "category.id", "category.name").
+		Find(&rows).Error
+	if err != nil {
+		return errors.Wrap(err, "load book categories")
+	}
+
+	positions := make(map[uuid.UUID]int, len(books))
+	for i := range books {
+		positions[books[i].ID] = i
+	}
+	for _, row := range rows {
+		i := positions[row.BookId]
+		books[i].Categories = append(books[i].Categories, schemas.CategoryRef{ID: row.ID, Name: row.Name})
+	}
+
+	return nil
+}
+
+func setCategories(tx *gorm.DB, bookId uuid.UUID, categoryIds []uuid.UUID, now time.Time) error {
+	if len(categoryIds) == 0 {
+		return nil
+	}
+
+	links := make([]schemas.BookCategory, 0, len(categoryIds))
+	for _, categoryId := range categoryIds {
+		links = append(links, schemas.BookCategory{BookId: bookId, CategoryId: categoryId, CreatedAt: now})
+	}
+
+	return tx.Table("book_category").Clauses(clause.OnConflict{DoNothing: true}).Create(&links).Error
+}
+
+// setAuthors links the contributors in the given order. Their AuthorId must already be resolved.
+func setAuthors(tx *gorm.DB, bookId uuid.UUID, contributors []schemas.BookContributor, now time.Time) error {
+	if len(contributors) == 0 {
+		return nil
+	}
+
+	links := make([]schemas.BookAuthor, 0, len(contributors))
+	for i, contributor := range contributors {
+		links = append(links, schemas.BookAuthor{BookId: bookId, AuthorId: contributor.AuthorId,
+			Role: contributor.Role, Position: i, CreatedAt: now})
+	}
+
+	return tx.Table("book_author").Clauses(clause.OnConflict{DoNothing: true}).Create(&links).Error
+}
+
+// countBooks counts the live books matching filter as mode asks. Estimates count exactly up to
+// estimateThreshold books. Beyond it an unfiltered count comes from the table statistics, while
+// a filtered one stays at the threshold as a lower bound.
+func (r *Repository) countBooks(ctx context.Context, filter *schemas.BookFilter, mode string) (*int64, bool, error) {
+	db := r.db.WithContext(ctx)
+	var count int64
+	switch mode {
+	case schemas.TotalNone:
+		return nil, false, nil
+	case schemas.TotalEstimate:
+		err := db.Table("(?) AS matching", where(db, filter).Select("id").Limit(estimateThreshold)).Count(&count).Error
+		if err != nil {
+			return nil, false, err
+		}
+		if count < estimateThreshold {
+			return &count, false, nil
+		}
+		if filter.Empty() {
+			var rows int64
+			err = db.Table("information_schema.TABLES").
+				Where("TABLE_SCHEMA = DATABASE()").Where("TABLE_NAME", "book").
+				Pluck("TABLE_ROWS", &rows).Error
+			if err != nil {
+				return nil, false, err
+			}
+			count = max(count, rows)
+		}
+		return &count, true, nil
+	}
+
+	err := where(db, filter).Count(&count).Error
+	if err != nil {
+		return nil, false, err
+	}
+
+	return &count, false, nil
+}
+
+// where narrows a query on book to the live books matching filter.
+func where(db *gorm.DB, filter *schemas.BookFilter) *gorm.DB {
+	query := db.Table("book").Where("deleted_at IS NULL")
+	if filter.MinPrice != nil {
+		query = query.Where("price >= ?", *filter.MinPrice)
+	}
+	if filter.MaxPrice != nil {
+		query = query.Where("price <= ?", *filter.MaxPrice)
+	}
+	if filter.CreatedFrom != nil {
+		query = query.Where("created_at >= ?", *filter.CreatedFrom)
+	}
+	if filter.CreatedTo != nil {
+		query = query.Where("created_at < ?", *filter.CreatedTo)
+	}
+	if filter.BookIds != nil {
+		query = query.Where("id IN ?", filter.BookIds)
+	}
+	for _, group := range filter.CategoryGroups {
+		query = query.Where("id IN (?)", db.Table("book_category").Select("book_id").Where("category_id IN ?", group))
+	}
+	if len(filter.AuthorIds) > 0 {
+		query = query.Where("id IN (?)", db.Table("book_author").Select("book_id").Where("author_id IN ?", filter.AuthorIds))
+	}
+
+	return query
+}
+
+// order maps a sort field checked by the presentation to SQL, completed by the id.
+func order(sortBy, orderBy string) string {
+	return sortKey(sortBy) + " " + orderBy + ", id " + orderBy
+}
+
+// sortKey is the SQL value books are sorted by. Books are sorted by authors through the name of
+// their first listed person, or an empty one. Relevance only exists for search results, which
+// the search service orders itself, so elsewhere it falls back to the name.
+func sortKey(sortBy string) string {
+	switch sortBy {
+	case "authors":
+		return "COALESCE((SELECT author.name FROM book_author JOIN author ON author.id = book_author.author_id " +
+			"WHERE book_author.book_id = book.id ORDER BY book_author.position LIMIT 1), '')"
+	case "price":
+		return "price"
+	}
+
+	return "name"
+}
+
+// sortValue is the value of sortKey for the book, whose authors must be loaded.
+func sortValue(book *schemas.Book, sortBy string) string {
+	switch sortBy {
+	case "authors":
+		if len(book.Authors) == 0 {
+			return ""
+		}
+		return book.Authors[0].Name
+	case "price":
+		return strconv.Itoa(book.Price)
 	}
 
-	return price, nil
+	return book.Name
 }
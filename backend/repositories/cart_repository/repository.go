@@ -2,10 +2,13 @@ package cart_repository
 
 import (
 	"context"
+	"encoding/json"
 	"github.com/google/uuid"
 	"github.com/pkg/errors"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 	"main.go/schemas"
+	"time"
 )
 
 type Repository struct {
@@ -16,6 +19,7 @@ func NewRepository(db *gorm.DB) *Repository {
 	return &Repository{db: db}
 }
 
+// GetCart returns the user's cart with its lines, oldest first, and their total.
 func (r *Repository) GetCart(ctx context.Context, userId uuid.UUID) (*schemas.Cart, error) {
 	var cart *schemas.Cart
 	row := r.db.WithContext(ctx).Table("cart").Where("user_id", userId).Find(&cart)
@@ -27,22 +31,277 @@ func (r *Repository) GetCart(ctx context.Context, userId uuid.UUID) (*schemas.Ca
 		return nil, gorm.ErrRecordNotFound
 	}
 
+	err := r.db.WithContext(ctx).Table("cart_line").Where("cart_id", cart.ID).
+		Order("created_at").Order("book_id").
+		Find(&cart.Lines).Error
+	if err != nil {
+		return nil, errors.Wrap(err, "get cart repo")
+	}
+
+	for _, line := range cart.Lines {
+		cart.TotalPrice += line.Quantity * line.UnitPrice
+	}
+
 	return cart, nil
 }
 
-func (r *Repository) UpdateCart(ctx context.Context, cart *schemas.Cart) error {
-	err := r.db.WithContext(ctx).Table("cart").Where("id", cart.ID).Updates(cart).Error
+// CreateCart stores the cart unless its user already has one, reporting whether it was stored.
+func (r *Repository) CreateCart(ctx context.Context, cart *schemas.Cart) (bool, error) {
+	row := r.db.WithContext(ctx).Table("cart").Clauses(clause.OnConflict{DoNothing: true}).Create(cart)
+	if row.Error != nil {
+		return false, errors.Wrap(row.Error, "create cart repo")
+	}
+
+	return row.RowsAffected != 0, nil
+}
+
+// SetLine stores the line of the book in one transaction that locks the book row, so that concurrent
+// changes of the line and of the stock are serialized. With add the quantity is added to the stored one,
+// otherwise it replaces it. check sees the stock, the stored and the resulting quantity before the write
+// and aborts it with an error. A new line takes the book's current price, an existing one keeps its own.
+func (r *Repository) SetLine(ctx context.Context, cartId, bookId uuid.UUID, quantity int, add bool, now time.Time,
+	check func(stock *int, current, next int) error) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var book struct {
+			Price int
+			Stock *int
+		}
+		row := tx.Table("book").Clauses(clause.Locking{Strength: "UPDATE"}).
+			Select("price", "stock").Where("id", bookId).Where("deleted_at IS NULL").
+			Find(&book)
+		if row.Error != nil {
+			return errors.Wrap(row.Error, "set cart line repo")
+		}
+		if row.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+
+		var stored []int
+		err := tx.Table("cart_line").Where("cart_id", cartId).Where("book_id", bookId).
+			Pluck("quantity", &stored).Error
+		if err != nil {
+			return errors.Wrap(err, "set cart line repo")
+		}
+		current := 0
+		if len(stored) > 0 {
+			current = stored[0]
+		}
+		next := quantity
+		if add {
+			next += current
+		}
+		err = check(book.Stock, current, next)
+		if err != nil {
+			return err
+		}
+
+		updates := clause.AssignmentColumns([]string{"quantity", "updated_at"})
+		if add {
+			updates = clause.Assignments(map[string]interface{}{
+				"quantity":   gorm.Expr("quantity + VALUES(quantity)"),
+				"updated_at": gorm.Expr("VALUES(updated_at)"),
+			})
+		}
+		line := schemas.CartLine{CartId: cartId, BookId: bookId, Quantity: quantity, UnitPrice: book.Price,
+			CreatedAt: now, UpdatedAt: now}
+		err = tx.Table("cart_line").Clauses(clause.OnConflict{DoUpdates: updates}).Create(&line).Error
+		if err != nil {
+			return errors.Wrap(err, "set cart line repo")
+		}
+
+		err = tx.Table("cart").Where("id", cartId).Update("updated_at", now).Error
+		if err != nil {
+			return errors.Wrap(err, "set cart line repo")
+		}
+
+		return nil
+	})
+}
+
+// DeleteLine removes the book from the cart. It reports false if the cart does not contain it.
+func (r *Repository) DeleteLine(ctx context.Context, cartId, bookId uuid.UUID, now time.Time) (bool, error) {
+	deleted := false
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		row := tx.Table("cart_line").Where("cart_id", cartId).Where("book_id", bookId).Delete(&schemas.CartLine{})
+		if row.Error != nil {
+			return errors.Wrap(row.Error, "delete cart line repo")
+		}
+		if row.RowsAffected == 0 {
+			return nil
+		}
+		deleted = true
+
+		err := tx.Table("cart").Where("id", cartId).Update("updated_at", now).Error
+		if err != nil {
+			return errors.Wrap(err, "delete cart line repo")
+		}
+
+		return nil
+	})
+
+	return deleted, err
+}
+
+// AnonymizeUser detaches the user's carts from the account while keeping them for statistics.
+func (r *Repository) AnonymizeUser(ctx context.Context, userId uuid.UUID) error {
+	err := r.db.WithContext(ctx).Table("cart").Where("user_id", userId).Update("user_id", nil).Error
+	if err != nil {
+		return errors.Wrap(err, "anonymize carts repo")
+	}
+
+	return nil
+}
+
+// MergeDuplicateCarts prepares the cart table for the unique index on cart.user_id, which AutoMigrate
+// creates, and has to run before it. Carts of deleted accounts, which used to keep the zero user id,
+// are detached, and the carts of a user are merged into the oldest one, adding up the copies of
+// a book. It does nothing once the index exists.
+func (r *Repository) MergeDuplicateCarts(ctx context.Context) error {
+	db := r.db.WithContext(ctx)
+	if !db.Migrator().HasTable(&schemas.Cart{}) || db.Migrator().HasIndex(&schemas.Cart{}, "UserId") {
+		return nil
+	}
+	hasLines := db.Migrator().HasTable(&schemas.CartLine{})
+	hasBookIds := db.Migrator().HasColumn(&schemas.Cart{}, "book_ids")
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Table("cart").Where("user_id", uuid.Nil).Update("user_id", nil).Error
+		if err != nil {
+			return err
+		}
+
+		columns := []string{"id", "user_id"}
+		if hasBookIds {
+			columns = append(columns, "book_ids")
+		}
+		var carts []struct {
+			ID      uuid.UUID
+			UserId  uuid.UUID
+			BookIds []byte
+		}
+		duplicated := tx.Table("cart").Select("user_id").
+			Where("user_id IS NOT NULL").
+			Group("user_id").Having("COUNT(*) > 1")
+		err = tx.Table("cart").Select(columns).Where("user_id IN (?)", duplicated).
+			Order("user_id").Order("created_at").Order("id").
+			Find(&carts).Error
+		if err != nil {
+			return err
+		}
+
+		kept := 0
+		for i, cart := range carts {
+			if cart.UserId != carts[kept].UserId {
+				kept = i
+			}
+			if i == kept {
+				continue
+			}
+
+			if hasLines {
+				err = tx.Exec("INSERT INTO cart_line (cart_id, book_id, quantity, unit_price, created_at, updated_at) "+
+					"SELECT ?, book_id, quantity, unit_price, created_at, updated_at FROM cart_line AS merged WHERE merged.cart_id = ? "+
+					"ON DUPLICATE KEY UPDATE quantity = cart_line.quantity + VALUES(quantity)",
+					carts[kept].ID, cart.ID).Error
+				if err != nil {
+					return err
+				}
+
+				err = tx.Table("cart_line").Where("cart_id", cart.ID).Delete(&schemas.CartLine{}).Error
+				if err != nil {
+					return err
+				}
+			}
+
+			if hasBookIds {
+				var keptIds, bookIds []uuid.UUID
+				_ = json.Unmarshal(carts[kept].BookIds, &keptIds)
+				_ = json.Unmarshal(cart.BookIds, &bookIds)
+				carts[kept].BookIds, err = json.Marshal(append(keptIds, bookIds...))
+				if err != nil {
+					return err
+				}
+
+				err = tx.Table("cart").Where("id", carts[kept].ID).Update("book_ids", carts[kept].BookIds).Error
+				if err != nil {
+					return err
+				}
+			}
+
+			err = tx.Table("cart").Where("id", cart.ID).Delete(&schemas.Cart{}).Error
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
 	if err != nil {
-		return errors.Wrap(err, "update cart repo")
+		return errors.Wrap(err, "merge duplicate carts repo")
 	}
 
 	return nil
 }
 
-func (r *Repository) SaveCart(ctx context.Context, cart *schemas.Cart) error {
-	err := r.db.WithContext(ctx).Table("cart").Save(&cart).Error
+// MigrateBookIdsColumn moves the old JSON cart.book_ids column into cart_line and drops it together
+// with the stored cart.total_price. Repeated ids become the quantity of one line priced at the book's
+// current price; deleted books and books that no longer exist are left behind. The lines are written
+// in one transaction and the columns dropped after it commits, as MySQL commits DDL implicitly.
+// A failed drop is retried on the next start, which skips the lines already written.
+func (r *Repository) MigrateBookIdsColumn(ctx context.Context) error {
+	db := r.db.WithContext(ctx)
+	if !db.Migrator().HasColumn(&schemas.Cart{}, "book_ids") {
+		return nil
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var rows []struct {
+			ID        uuid.UUID
+			BookIds   []byte
+			UpdatedAt time.Time
+		}
+		err := tx.Table("cart").Select("id", "book_ids", "updated_at").
+			Where("book_ids IS NOT NULL").
+			Find(&rows).Error
+		if err != nil {
+			return err
+		}
+
+		for _, row := range rows {
+			var bookIds []uuid.UUID
+			err = json.Unmarshal(row.BookIds, &bookIds)
+			if err != nil {
+				continue
+			}
+
+			quantities := make(map[uuid.UUID]int)
+			for _, bookId := range bookIds {
+				quantities[bookId]++
+			}
+			for bookId, quantity := range quantities {
+				err = tx.Exec("INSERT IGNORE INTO cart_line (cart_id, book_id, quantity, unit_price, created_at, updated_at) "+
+					"SELECT ?, id, ?, price, ?, ? FROM book WHERE id = ? AND deleted_at IS NULL",
+					row.ID, quantity, row.UpdatedAt, row.UpdatedAt, bookId).Error
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		return nil
+	})
 	if err != nil {
-		return errors.Wrap(err, "save cart repo")
+		return errors.Wrap(err, "migrate cart book ids repo")
+	}
+
+	for _, column := range []string{"book_ids", "total_price"} {
+		if !db.Migrator().HasColumn(&schemas.Cart{}, column) {
+			continue
+		}
+		err = db.Migrator().DropColumn(&schemas.Cart{}, column)
+		if err != nil {
+			return errors.Wrap(err, "migrate cart book ids repo")
+		}
 	}
 
 	return nil
@@ -5,6 +5,7 @@ import (
 	"github.com/google/uuid"
 	"github.com/pkg/errors"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 	"main.go/schemas"
 	"time"
 )
@@ -19,7 +20,7 @@ func NewRepositpory(db *gorm.DB) *Repository {
 
 func (r *Repository) GetCategories(ctx context.Context) (*[]schemas.Category, error) {
 	var categories *[]schemas.Category
-	err := r.db.WithContext(ctx).Table("category").Where("deleted_at IS NULL").Find(&categories).Error
+	err := r.db.WithContext(ctx).Table("category").Where("deleted_at IS NULL").Order("name").Find(&categories).Error
 	if err != nil {
 		return nil, errors.Wrap(err, "get categories repo")
 	}
@@ -47,11 +48,201 @@ func (r *Repository) UpdateCategory(ctx context.Context, id uuid.UUID, category
 	return nil
 }
 
-func (r *Repository) DeleteCategory(ctx context.Context, id uuid.UUID) error {
-	err := r.db.WithContext(ctx).Table("category").Where("id", id).Update("deleted_at", time.Now().UTC()).Error
+// MoveCategory changes the parent of a category. All categories are locked while check
+// inspects them, so that two concurrent moves cannot together form a cycle.
+func (r *Repository) MoveCategory(ctx context.Context, id uuid.UUID, parentId *uuid.UUID, now time.Time,
+	check func(categories []schemas.Category) error) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		categories, err := lockCategories(tx)
+		if err != nil {
+			return errors.Wrap(err, "move category repo")
+		}
+
+		err = check(categories)
+		if err != nil {
+			return err
+		}
+
+		err = tx.Table("category").Where("id", id).
+			Updates(map[string]interface{}{"parent_id": parentId, "updated_at": now}).Error
+		if err != nil {
+			return errors.Wrap(err, "move category repo")
+		}
+
+		return nil
+	})
+}
+
+func (r *Repository) GetCategory(ctx context.Context, id uuid.UUID) (*schemas.Category, error) {
+	var category schemas.Category
+	row := r.db.WithContext(ctx).Table("category").Where("id = ? AND deleted_at IS NULL", id).Find(&category)
+	if row.Error != nil {
+		return nil, errors.Wrap(row.Error, "get category repo")
+	}
+
+	if row.RowsAffected == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	return &category, nil
+}
+
+// GetCategoryBookIds returns the live books linked to the category itself, not to its subcategories.
+func (r *Repository) GetCategoryBookIds(ctx context.Context, id uuid.UUID) ([]uuid.UUID, error) {
+	var bookIds []uuid.UUID
+	err := r.db.WithContext(ctx).Table("book_category").
+		Joins("JOIN book ON book.id = book_category.book_id").
+		Where("book_category.category_id = ? AND book.deleted_at IS NULL", id).
+		Pluck("book_category.book_id", &bookIds).Error
 	if err != nil {
-		return errors.Wrap(err, "delete category repo")
+		return nil, errors.Wrap(err, "get category book ids repo")
 	}
 
-	return nil
+	return bookIds, nil
+}
+
+// CountBooks counts the live books linked to each live category, directly and over its whole subtree.
+// Subtree totals count a book once even if it is linked to several categories of the subtree.
+func (r *Repository) CountBooks(ctx context.Context) (map[uuid.UUID]int64, map[uuid.UUID]int64, error) {
+	db := r.db.WithContext(ctx)
+
+	var direct []struct {
+		CategoryId uuid.UUID
+		Count      int64
+	}
+	err := db.Table("book_category").
+		Joins("JOIN book ON book.id = book_category.book_id").
+		Where("book.deleted_at IS NULL").
+		Group("book_category.category_id").
+		Select("book_category.category_id", "COUNT(*) AS count").
+		Find(&direct).Error
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "count category books repo")
+	}
+
+	var totals []struct {
+		AncestorId uuid.UUID
+		Count      int64
+	}
+	err = db.Raw("WITH RECURSIVE subtree (ancestor_id, category_id) AS (" +
+		"SELECT id, id FROM category WHERE deleted_at IS NULL " +
+		"UNION ALL " +
+		"SELECT subtree.ancestor_id, category.id FROM subtree " +
+		"JOIN category ON category.parent_id = subtree.category_id AND category.deleted_at IS NULL) " +
+		"SELECT subtree.ancestor_id, COUNT(DISTINCT book_category.book_id) AS count FROM subtree " +
+		"JOIN book_category ON book_category.category_id = subtree.category_id " +
+		"JOIN book ON book.id = book_category.book_id AND book.deleted_at IS NULL " +
+		"GROUP BY subtree.ancestor_id").
+		Scan(&totals).Error
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "count category books repo")
+	}
+
+	directCounts := make(map[uuid.UUID]int64, len(direct))
+	for _, row := range direct {
+		directCounts[row.CategoryId] = row.Count
+	}
+	totalCounts := make(map[uuid.UUID]int64, len(totals))
+	for _, row := range totals {
+		totalCounts[row.AncestorId] = row.Count
+	}
+
+	return directCounts, totalCounts, nil
+}
+
+// DeleteCategory soft deletes the category and moves its children up a level. Its books are
+// linked to targetId when set and unlinked otherwise. check sees the locked categories and the
+// number of live books in the category before anything changes.
+func (r *Repository) DeleteCategory(ctx context.Context, id uuid.UUID, targetId *uuid.UUID, now time.Time,
+	check func(categories []schemas.Category, books int64) error) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		categories, err := lockCategories(tx)
+		if err != nil {
+			return errors.Wrap(err, "delete category repo")
+		}
+
+		var books int64
+		err = tx.Table("book_category").
+			Joins("JOIN book ON book.id = book_category.book_id").
+			Where("book_category.category_id", id).
+			Where("book.deleted_at IS NULL").
+			Count(&books).Error
+		if err != nil {
+			return errors.Wrap(err, "delete category repo")
+		}
+
+		err = check(categories, books)
+		if err != nil {
+			return err
+		}
+
+		var parentId *uuid.UUID
+		for _, category := range categories {
+			if category.ID == id {
+				parentId = category.ParentId
+			}
+		}
+
+		err = removeCategory(tx, id, targetId, parentId, now)
+		if err != nil {
+			return errors.Wrap(err, "delete category repo")
+		}
+
+		return nil
+	})
+}
+
+// MergeCategory moves the books and children of category id into targetId and deletes it.
+func (r *Repository) MergeCategory(ctx context.Context, id, targetId uuid.UUID, now time.Time,
+	check func(categories []schemas.Category) error) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		categories, err := lockCategories(tx)
+		if err != nil {
+			return errors.Wrap(err, "merge category repo")
+		}
+
+		err = check(categories)
+		if err != nil {
+			return err
+		}
+
+		err = removeCategory(tx, id, &targetId, &targetId, now)
+		if err != nil {
+			return errors.Wrap(err, "merge category repo")
+		}
+
+		return nil
+	})
+}
+
+func lockCategories(tx *gorm.DB) ([]schemas.Category, error) {
+	var categories []schemas.Category
+	err := tx.Table("category").Where("deleted_at IS NULL").
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		Find(&categories).Error
+
+	return categories, err
+}
+
+func removeCategory(tx *gorm.DB, id uuid.UUID, targetId, childrenParentId *uuid.UUID, now time.Time) error {
+	if targetId != nil {
+		err := tx.Exec("INSERT IGNORE INTO book_category (book_id, category_id, created_at) "+
+			"SELECT book_id, ?, ? FROM book_category WHERE category_id = ?", *targetId, now, id).Error
+		if err != nil {
+			return err
+		}
+	}
+
+	err := tx.Table("book_category").Where("category_id", id).Delete(&schemas.BookCategory{}).Error
+	if err != nil {
+		return err
+	}
+
+	err = tx.Table("category").Where("parent_id", id).
+		Updates(map[string]interface{}{"parent_id": childrenParentId, "updated_at": now}).Error
+	if err != nil {
+		return err
+	}
+
+	return tx.Table("category").Where("id", id).Update("deleted_at", now).Error
 }
@@ -0,0 +1,193 @@
+package identity_repository
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+	"main.go/schemas"
+	"time"
+)
+
+type Repository struct {
+	db *gorm.DB
+}
+
+func NewRepository(db *gorm.DB) *Repository {
+	return &Repository{db: db}
+}
+
+func (r *Repository) SaveIdentity(ctx context.Context, identity *schemas.UserIdentity) error {
+	err := r.db.WithContext(ctx).Table("user_identity").Create(identity).Error
+	if err != nil {
+		return errors.Wrap(err, "save identity repo")
+	}
+
+	return nil
+}
+
+func (r *Repository) GetIdentity(ctx context.Context, issuer, subject string) (*schemas.UserIdentity, error) {
+	var identity schemas.UserIdentity
+	row := r.db.WithContext(ctx).Table("user_identity").
+		Where("issuer", issuer).
+		Where("subject", subject).
+		Find(&identity)
+	if row.Error != nil {
+		return nil, errors.Wrap(row.Error, "get identity repo")
+	}
+	if row.RowsAffected == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	return &identity, nil
+}
+
+func (r *Repository) GetUserIdentities(ctx context.Context, userId uuid.UUID) (*[]schemas.UserIdentity, error) {
+	var identities []schemas.UserIdentity
+	err := r.db.WithContext(ctx).Table("user_identity").
+		Where("user_id", userId).
+		Order("created_at").
+		Find(&identities).Error
+	if err != nil {
+		return nil, errors.Wrap(err, "get user identities repo")
+	}
+
+	return &identities, nil
+}
+
+func (r *Repository) TouchIdentity(ctx context.Context, id uuid.UUID, email string, now time.Time) error {
+	err := r.db.WithContext(ctx).Table("user_identity").Where("id", id).Updates(map[string]interface{}{
+		"email":         email,
+		"last_login_at": now,
+	}).Error
+	if err != nil {
+		return errors.Wrap(err, "touch identity repo")
+	}
+
+	return nil
+}
+
+func (r *Repository) DeleteIdentity(ctx context.Context, id, userId uuid.UUID) error {
+	row := r.db.WithContext(ctx).Table("user_identity").
+		Where("id", id).
+		Where("user_id", userId).
+		Delete(&schemas.UserIdentity{})
+	if row.Error != nil {
+		return errors.Wrap(row.Error, "delete identity repo")
+	}
+	if row.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// AnonymizeUser unlinks every identity of a deleted account, so that the IdP account can sign up again.
+func (r *Repository) AnonymizeUser(ctx context.Context, userId uuid.UUID) error {
+	err := r.db.WithContext(ctx).Table("user_identity").Where("user_id", userId).Delete(&schemas.UserIdentity{}).Error
+	if err != nil {
+		return errors.Wrap(err, "anonymize identities repo")
+	}
+
+	return nil
+}
+
+func (r *Repository) SaveState(ctx context.Context, state *schemas.OidcState) error {
+	err := r.db.WithContext(ctx).Table("oidc_state").Create(state).Error
+	if err != nil {
+		return errors.Wrap(err, "save oidc state repo")
+	}
+
+	return nil
+}
+
+// TakeState returns and deletes the state, so that each authorization response is accepted once.
+func (r *Repository) TakeState(ctx context.Context, stateHash string) (*schemas.OidcState, error) {
+	var state schemas.OidcState
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		row := tx.Table("oidc_state").Where("state_hash", stateHash).Find(&state)
+		if row.Error != nil {
+			return row.Error
+		}
+		if row.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+
+		row = tx.Table("oidc_state").Where("state_hash", stateHash).Delete(&schemas.OidcState{})
+		if row.Error != nil {
+			return row.Error
+		}
+		if row.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+
+		return nil
+	})
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, err
+		}
+		return nil, errors.Wrap(err, "take oidc state repo")
+	}
+
+	return &state, nil
+}
+
+func (r *Repository) DeleteExpiredStates(ctx context.Context, now time.Time) (int64, error) {
+	row := r.db.WithContext(ctx).Table("oidc_state").Where("expires_at < ?", now).Delete(&schemas.OidcState{})
+	if row.Error != nil {
+		return 0, errors.Wrap(row.Error, "delete expired oidc states repo")
+	}
+
+	return row.RowsAffected, nil
+}
+
+func (r *Repository) SaveLoginCode(ctx context.Context, code *schemas.OidcLoginCode) error {
+	err := r.db.WithContext(ctx).Table("oidc_login_code").Create(code).Error
+	if err != nil {
+		return errors.Wrap(err, "save oidc login code repo")
+	}
+
+	return nil
+}
+
+// TakeLoginCode returns and deletes the login code, so that it can be exchanged once.
+func (r *Repository) TakeLoginCode(ctx context.Context, codeHash string) (*schemas.OidcLoginCode, error) {
+	var code schemas.OidcLoginCode
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		row := tx.Table("oidc_login_code").Where("code_hash", codeHash).Find(&code)
+		if row.Error != nil {
+			return row.Error
+		}
+		if row.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+
+		row = tx.Table("oidc_login_code").Where("code_hash", codeHash).Delete(&schemas.OidcLoginCode{})
+		if row.Error != nil {
+			return row.Error
+		}
+		if row.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+
+		return nil
+	})
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, err
+		}
+		return nil, errors.Wrap(err, "take oidc login code repo")
+	}
+
+	return &code, nil
+}
+
+func (r *Repository) DeleteExpiredLoginCodes(ctx context.Context, now time.Time) (int64, error) {
+	row := r.db.WithContext(ctx).Table("oidc_login_code").Where("expires_at < ?", now).Delete(&schemas.OidcLoginCode{})
+	if row.Error != nil {
+		return 0, errors.Wrap(row.Error, "delete expired oidc login codes repo")
+	}
+
+	return row.RowsAffected, nil
+}
@@ -0,0 +1,78 @@
+package inventory_repository
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"main.go/schemas"
+)
+
+type Repository struct {
+	db *gorm.DB
+}
+
+func NewRepository(db *gorm.DB) *Repository {
+	return &Repository{db: db}
+}
+
+// Adjust applies adjustment.Quantity to the stock of a live book and records it in the ledger,
+// filling adjustment.Balance. The book row stays locked until both are written, so concurrent
+// adjustments cannot lose updates. It reports false and changes nothing when the stock would
+// drop below zero.
+func (r *Repository) Adjust(ctx context.Context, adjustment *schemas.StockAdjustment) (bool, error) {
+	applied := false
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var stock struct {
+			Stock *int
+		}
+		row := tx.Table("book").Clauses(clause.Locking{Strength: "UPDATE"}).
+			Select("stock").Where("id", adjustment.BookId).Where("deleted_at IS NULL").
+			Find(&stock)
+		if row.Error != nil {
+			return errors.Wrap(row.Error, "adjust stock repo")
+		}
+		if row.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+
+		balance := adjustment.Quantity
+		if stock.Stock != nil {
+			balance += *stock.Stock
+		}
+		if balance < 0 {
+			return nil
+		}
+		adjustment.Balance = balance
+
+		err := tx.Table("book").Where("id", adjustment.BookId).Update("stock", balance).Error
+		if err != nil {
+			return errors.Wrap(err, "adjust stock repo")
+		}
+
+		err = tx.Table("stock_adjustment").Create(adjustment).Error
+		if err != nil {
+			return errors.Wrap(err, "adjust stock repo")
+		}
+
+		applied = true
+		return nil
+	})
+
+	return applied, err
+}
+
+// GetAdjustments returns the book's ledger, newest first.
+func (r *Repository) GetAdjustments(ctx context.Context, bookId uuid.UUID, page, pageSize int) (*[]schemas.StockAdjustment, error) {
+	var adjustments []schemas.StockAdjustment
+	err := r.db.WithContext(ctx).Table("stock_adjustment").Where("book_id", bookId).
+		Order("created_at DESC").Order("id").
+		Limit(pageSize).Offset(page * pageSize).
+		Find(&adjustments).Error
+	if err != nil {
+		return nil, errors.Wrap(err, "get stock adjustments repo")
+	}
+
+	return &adjustments, nil
+}
@@ -0,0 +1,109 @@
+package invitation_repository
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+	"main.go/schemas"
+	"time"
+)
+
+type Repository struct {
+	db *gorm.DB
+}
+
+func NewRepository(db *gorm.DB) *Repository {
+	return &Repository{db: db}
+}
+
+func (r *Repository) SaveInvitation(ctx context.Context, invitation *schemas.Invitation) error {
+	err := r.db.WithContext(ctx).Table("invitation").Create(&invitation).Error
+	if err != nil {
+		return errors.Wrap(err, "save invitation repo")
+	}
+
+	return nil
+}
+
+func (r *Repository) GetInvitations(ctx context.Context, page int, pageSize int) (*[]schemas.Invitation, error) {
+	var invitations *[]schemas.Invitation
+	err := r.db.WithContext(ctx).Table("invitation").
+		Select("invitation.*, role.name AS role_name").
+		Joins("LEFT JOIN role ON role.id = invitation.role_id").
+		Order("invitation.created_at DESC").
+		Limit(pageSize).Offset(page * pageSize).
+		Find(&invitations).Error
+	if err != nil {
+		return nil, errors.Wrap(err, "get invitations repo")
+	}
+
+	return invitations, nil
+}
+
+func (r *Repository) GetInvitationByCodeHash(ctx context.Context, codeHash string) (*schemas.Invitation, error) {
+	var invitation schemas.Invitation
+	row := r.db.WithContext(ctx).Table("invitation").Where("code_hash", codeHash).Find(&invitation)
+	if row.Error != nil {
+		return nil, errors.Wrap(row.Error, "get invitation repo")
+	}
+
+	if row.RowsAffected == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	return &invitation, nil
+}
+
+// CountPending counts the invitations for the role that can still be redeemed.
+func (r *Repository) CountPending(ctx context.Context, roleId uuid.UUID, now time.Time) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Table("invitation").
+		Where("role_id", roleId).
+		Where("redeemed_at IS NULL").Where("revoked_at IS NULL").
+		Where("expires_at > ?", now).
+		Count(&count).Error
+	if err != nil {
+		return 0, errors.Wrap(err, "count pending invitations repo")
+	}
+
+	return count, nil
+}
+
+// Claim marks a pending invitation as redeemed by userId. It returns false if the
+// invitation was redeemed, revoked or expired in the meantime.
+func (r *Repository) Claim(ctx context.Context, id, userId uuid.UUID, now time.Time) (bool, error) {
+	row := r.db.WithContext(ctx).Table("invitation").
+		Where("id", id).
+		Where("redeemed_at IS NULL").Where("revoked_at IS NULL").
+		Where("expires_at > ?", now).
+		Updates(map[string]interface{}{"redeemed_at": now, "redeemed_by": userId})
+	if row.Error != nil {
+		return false, errors.Wrap(row.Error, "claim invitation repo")
+	}
+
+	return row.RowsAffected == 1, nil
+}
+
+// Release undoes Claim when registration fails after the invitation was claimed.
+func (r *Repository) Release(ctx context.Context, id uuid.UUID) error {
+	err := r.db.WithContext(ctx).Table("invitation").
+		Where("id", id).
+		Updates(map[string]interface{}{"redeemed_at": nil, "redeemed_by": uuid.Nil}).Error
+	if err != nil {
+		return errors.Wrap(err, "release invitation repo")
+	}
+
+	return nil
+}
+
+func (r *Repository) Revoke(ctx context.Context, id uuid.UUID, now time.Time) (bool, error) {
+	row := r.db.WithContext(ctx).Table("invitation").
+		Where("id", id).Where("redeemed_at IS NULL").Where("revoked_at IS NULL").
+		Update("revoked_at", now)
+	if row.Error != nil {
+		return false, errors.Wrap(row.Error, "revoke invitation repo")
+	}
+
+	return row.RowsAffected == 1, nil
+}
@@ -0,0 +1,90 @@
+package login_attempt_repository
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"main.go/schemas"
+	"time"
+)
+
+// Repository is the database-backed login limiter store, shared by all backend instances.
+type Repository struct {
+	db *gorm.DB
+}
+
+func NewRepository(db *gorm.DB) *Repository {
+	return &Repository{db: db}
+}
+
+func (r *Repository) Get(ctx context.Context, key string) (*schemas.LoginAttempt, error) {
+	attempt := schemas.LoginAttempt{Key: key}
+	err := r.db.WithContext(ctx).Table("login_attempt").Where("key", key).Find(&attempt).Error
+	if err != nil {
+		return nil, errors.Wrap(err, "get login attempt repo")
+	}
+
+	return &attempt, nil
+}
+
+func (r *Repository) Increment(ctx context.Context, key string, now time.Time, window time.Duration) (*schemas.LoginAttempt, error) {
+	attempt := schemas.LoginAttempt{Key: key}
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		row := tx.Table("login_attempt").
+			Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("key", key).
+			Find(&attempt)
+		if row.Error != nil {
+			return row.Error
+		}
+
+		if now.Sub(attempt.LastFailureAt) > window {
+			attempt.Failures = 0
+		}
+		attempt.Failures++
+		attempt.LastFailureAt = now
+
+		if row.RowsAffected == 0 {
+			return tx.Table("login_attempt").Create(&attempt).Error
+		}
+		return tx.Table("login_attempt").Where("key", key).
+			Select("failures", "last_failure_at").
+			Updates(&attempt).Error
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "increment login attempt repo")
+	}
+
+	return &attempt, nil
+}
+
+func (r *Repository) Block(ctx context.Context, key string, until time.Time) error {
+	err := r.db.WithContext(ctx).Table("login_attempt").Where("key", key).Update("blocked_until", until).Error
+	if err != nil {
+		return errors.Wrap(err, "block login attempt repo")
+	}
+
+	return nil
+}
+
+func (r *Repository) Reset(ctx context.Context, key string) error {
+	err := r.db.WithContext(ctx).Table("login_attempt").Where("key", key).Delete(&schemas.LoginAttempt{}).Error
+	if err != nil {
+		return errors.Wrap(err, "reset login attempt repo")
+	}
+
+	return nil
+}
+
+func (r *Repository) Prune(ctx context.Context, before time.Time) (int64, error) {
+	row := r.db.WithContext(ctx).Table("login_attempt").
+		Where("last_failure_at < ?", before).
+		Where("blocked_until IS NULL OR blocked_until < ?", before).
+		Delete(&schemas.LoginAttempt{})
+	if row.Error != nil {
+		return 0, errors.Wrap(row.Error, "prune login attempts repo")
+	}
+
+	return row.RowsAffected, nil
+}
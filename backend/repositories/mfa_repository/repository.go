@@ -0,0 +1,76 @@
+package mfa_repository
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+	"main.go/schemas"
+	"time"
+)
+
+type Repository struct {
+	db *gorm.DB
+}
+
+func NewRepository(db *gorm.DB) *Repository {
+	return &Repository{db: db}
+}
+
+// ReplaceRecoveryCodes drops every recovery code of the user and stores the given ones.
+func (r *Repository) ReplaceRecoveryCodes(ctx context.Context, userId uuid.UUID, codes []schemas.RecoveryCode) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Table("recovery_code").Where("user_id", userId).Delete(&schemas.RecoveryCode{}).Error
+		if err != nil {
+			return err
+		}
+
+		return tx.Table("recovery_code").Create(&codes).Error
+	})
+	if err != nil {
+		return errors.Wrap(err, "replace recovery codes repo")
+	}
+
+	return nil
+}
+
+// UseRecoveryCode marks the code used and reports false if it does not exist or was used already.
+func (r *Repository) UseRecoveryCode(ctx context.Context, userId uuid.UUID, codeHash string, now time.Time) (bool, error) {
+	row := r.db.WithContext(ctx).Table("recovery_code").
+		Where("user_id", userId).
+		Where("code_hash", codeHash).
+		Where("used_at IS NULL").
+		Update("used_at", now)
+	if row.Error != nil {
+		return false, errors.Wrap(row.Error, "use recovery code repo")
+	}
+
+	return row.RowsAffected == 1, nil
+}
+
+func (r *Repository) CountUnusedRecoveryCodes(ctx context.Context, userId uuid.UUID) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Table("recovery_code").
+		Where("user_id", userId).
+		Where("used_at IS NULL").
+		Count(&count).Error
+	if err != nil {
+		return 0, errors.Wrap(err, "count recovery codes repo")
+	}
+
+	return count, nil
+}
+
+func (r *Repository) DeleteRecoveryCodes(ctx context.Context, userId uuid.UUID) error {
+	err := r.db.WithContext(ctx).Table("recovery_code").Where("user_id", userId).Delete(&schemas.RecoveryCode{}).Error
+	if err != nil {
+		return errors.Wrap(err, "delete recovery codes repo")
+	}
+
+	return nil
+}
+
+// AnonymizeUser drops the recovery codes of a deleted account.
+func (r *Repository) AnonymizeUser(ctx context.Context, userId uuid.UUID) error {
+	return r.DeleteRecoveryCodes(ctx, userId)
+}
@@ -0,0 +1,252 @@
+package role_repository
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"main.go/schemas"
+	"time"
+)
+
+type Repository struct {
+	db *gorm.DB
+}
+
+func NewRepository(db *gorm.DB) *Repository {
+	return &Repository{db: db}
+}
+
+func (r *Repository) GetRoles(ctx context.Context) (*[]schemas.Role, error) {
+	var roles *[]schemas.Role
+	err := r.db.WithContext(ctx).Table("role").Order("name").Find(&roles).Error
+	if err != nil {
+		return nil, errors.Wrap(err, "get roles repo")
+	}
+
+	return roles, nil
+}
+
+func (r *Repository) GetRoleByName(ctx context.Context, name string) (*schemas.Role, error) {
+	var role schemas.Role
+	row := r.db.WithContext(ctx).Table("role").Where("name", name).Find(&role)
+	if row.Error != nil {
+		return nil, errors.Wrap(row.Error, "get role by name repo")
+	}
+
+	if row.RowsAffected == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	return &role, nil
+}
+
+func (r *Repository) SaveRole(ctx context.Context, role *schemas.Role) error {
+	err := r.db.WithContext(ctx).Table("role").Save(&role).Error
+	if err != nil {
+		return errors.Wrap(err, "save role repo")
+	}
+
+	return nil
+}
+
+func (r *Repository) UpdateRole(ctx context.Context, id uuid.UUID, role *schemas.Role) error {
+	err := r.db.WithContext(ctx).Table("role").
+		Where("id", id).Select("description", "permissions", "updated_at").
+		Updates(&role).Error
+	if err != nil {
+		return errors.Wrap(err, "update role repo")
+	}
+
+	return nil
+}
+
+func (r *Repository) GetUserRoles(ctx context.Context, userId uuid.UUID) (*[]schemas.Role, error) {
+	var roles *[]schemas.Role
+	err := r.db.WithContext(ctx).Table("role").
+		Joins("JOIN user_role ON user_role.role_id = role.id").
+		Where("user_role.user_id", userId).
+		Order("role.name").
+		Find(&roles).Error
+	if err != nil {
+		return nil, errors.Wrap(err, "get user roles repo")
+	}
+
+	return roles, nil
+}
+
+func (r *Repository) AssignRole(ctx context.Context, userId, roleId uuid.UUID) error {
+	err := r.db.WithContext(ctx).Table("user_role").
+		Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&schemas.UserRole{UserId: userId, RoleId: roleId, CreatedAt: time.Now().UTC()}).Error
+	if err != nil {
+		return errors.Wrap(err, "assign role repo")
+	}
+
+	return nil
+}
+
+func (r *Repository) RemoveRole(ctx context.Context, userId, roleId uuid.UUID) error {
+	err := r.db.WithContext(ctx).Table("user_role").
+		Where("user_id", userId).Where("role_id", roleId).
+		Delete(&schemas.UserRole{}).Error
+	if err != nil {
+		return errors.Wrap(err, "remove role repo")
+	}
+
+	return nil
+}
+
+// SetUserRoles replaces the user's roles in one transaction. When adminRoleId is set the other active
+// administrators are locked and the change is refused, reporting false, if none would remain.
+func (r *Repository) SetUserRoles(ctx context.Context, userId uuid.UUID, roleIds []uuid.UUID, adminRoleId *uuid.UUID) (bool, error) {
+	applied := false
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if adminRoleId != nil {
+			var adminIds []uuid.UUID
+			err := tx.Table("user_role").Clauses(clause.Locking{Strength: "UPDATE"}).
+				Joins("JOIN user ON user.id = user_role.user_id").
+				Where("user_role.role_id", *adminRoleId).
+				Where("user.deleted_at IS NULL").
+				Where("user.status = ? OR user.status = ''", schemas.UserStatusActive).
+				Where("user.id <> ?", userId).
+				Pluck("user.id", &adminIds).Error
+			if err != nil {
+				return errors.Wrap(err, "set user roles repo")
+			}
+			if len(adminIds) == 0 {
+				return nil
+			}
+		}
+
+		removed := tx.Table("user_role").Where("user_id", userId)
+		if len(roleIds) > 0 {
+			removed = removed.Where("role_id NOT IN ?", roleIds)
+		}
+		err := removed.Delete(&schemas.UserRole{}).Error
+		if err != nil {
+			return errors.Wrap(err, "set user roles repo")
+		}
+
+		now := time.Now().UTC()
+		for _, roleId := range roleIds {
+			err = tx.Table("user_role").Clauses(clause.OnConflict{DoNothing: true}).
+				Create(&schemas.UserRole{UserId: userId, RoleId: roleId, CreatedAt: now}).Error
+			if err != nil {
+				return errors.Wrap(err, "set user roles repo")
+			}
+		}
+
+		applied = true
+		return nil
+	})
+
+	return applied, err
+}
+
+// SeedRoles creates missing default roles and keeps the admin role in sync with the full permission list.
+func (r *Repository) SeedRoles(ctx context.Context, roles []schemas.Role) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		now := time.Now().UTC()
+		for _, role := range roles {
+			var existing schemas.Role
+			row := tx.Table("role").Where("name", role.Name).Find(&existing)
+			if row.Error != nil {
+				return errors.Wrap(row.Error, "seed roles repo")
+			}
+
+			if row.RowsAffected == 0 {
+				role.ID = uuid.New()
+				role.CreatedAt = now
+				role.UpdatedAt = now
+				err := tx.Table("role").Create(&role).Error
+				if err != nil {
+					return errors.Wrap(err, "seed roles repo")
+				}
+				continue
+			}
+
+			if role.Name == schemas.RoleAdmin {
+				existing.Permissions = role.Permissions
+				existing.UpdatedAt = now
+				err := tx.Table("role").Where("id", existing.ID).
+					Select("permissions", "updated_at").Updates(&existing).Error
+				if err != nil {
+					return errors.Wrap(err, "seed roles repo")
+				}
+			}
+		}
+
+		return nil
+	})
+}
+
+// MigrateAdminFlag grants the admin role to users that had the old user.admin flag and drops the column.
+func (r *Repository) MigrateAdminFlag(ctx context.Context) error {
+	db := r.db.WithContext(ctx)
+	if !db.Migrator().HasColumn(&schemas.User{}, "admin") {
+		return nil
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		var adminRole schemas.Role
+		row := tx.Table("role").Where("name", schemas.RoleAdmin).Find(&adminRole)
+		if row.Error != nil {
+			return errors.Wrap(row.Error, "migrate admin flag repo")
+		}
+		if row.RowsAffected == 0 {
+			return errors.Wrap(gorm.ErrRecordNotFound, "admin role")
+		}
+
+		err := tx.Exec("INSERT IGNORE INTO user_role (user_id, role_id, created_at) "+
+			"SELECT id, ?, ? FROM user WHERE admin = TRUE", adminRole.ID, time.Now().UTC()).Error
+		if err != nil {
+			return errors.Wrap(err, "migrate admin flag repo")
+		}
+
+		err = tx.Migrator().DropColumn(&schemas.User{}, "admin")
+		if err != nil {
+			return errors.Wrap(err, "migrate admin flag repo")
+		}
+
+		return nil
+	})
+}
+
+func (r *Repository) CountUsersWithRole(ctx context.Context, roleId uuid.UUID) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Table("user_role").
+		Joins("JOIN user ON user.id = user_role.user_id").
+		Where("user_role.role_id", roleId).
+		Where("user.deleted_at IS NULL").
+		Count(&count).Error
+	if err != nil {
+		return 0, errors.Wrap(err, "count users with role repo")
+	}
+
+	return count, nil
+}
+
+func (r *Repository) GetRoleNamesForUsers(ctx context.Context, userIds []uuid.UUID) (map[uuid.UUID][]string, error) {
+	var rows []struct {
+		UserId uuid.UUID
+		Name   string
+	}
+	err := r.db.WithContext(ctx).Table("user_role").
+		Select("user_role.user_id, role.name").
+		Joins("JOIN role ON role.id = user_role.role_id").
+		Where("user_role.user_id IN ?", userIds).
+		Order("role.name").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, errors.Wrap(err, "get role names for users repo")
+	}
+
+	names := make(map[uuid.UUID][]string, len(userIds))
+	for _, row := range rows {
+		names[row.UserId] = append(names[row.UserId], row.Name)
+	}
+
+	return names, nil
+}
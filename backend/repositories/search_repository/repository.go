@@ -0,0 +1,76 @@
+package search_repository
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"main.go/schemas"
+	"strings"
+)
+
+// Repository is the search index backed by MySQL FULLTEXT indexes on search_document.
+type Repository struct {
+	db *gorm.DB
+}
+
+func NewRepository(db *gorm.DB) *Repository {
+	return &Repository{db: db}
+}
+
+func (r *Repository) Upsert(ctx context.Context, document *schemas.SearchDocument) error {
+	err := r.db.WithContext(ctx).Table("search_document").
+		Clauses(clause.OnConflict{UpdateAll: true}).
+		Create(document).Error
+	if err != nil {
+		return errors.Wrap(err, "upsert search document repo")
+	}
+
+	return nil
+}
+
+func (r *Repository) Delete(ctx context.Context, bookId uuid.UUID) error {
+	err := r.db.WithContext(ctx).Table("search_document").
+		Where("book_id", bookId).
+		Delete(&schemas.SearchDocument{}).Error
+	if err != nil {
+		return errors.Wrap(err, "delete search document repo")
+	}
+
+	return nil
+}
+
+// Search ranks with natural language mode relevance, weighting title and author matches
+// the same way as the in-memory index.
+func (r *Repository) Search(ctx context.Context, terms []string, limit int) ([]schemas.SearchHit, error) {
+	query := strings.Join(terms, " ")
+	var hits []schemas.SearchHit
+	err := r.db.WithContext(ctx).Table("search_document").
+		Select("book_id, 3 * MATCH(title) AGAINST(?) + 2 * MATCH(authors) AGAINST(?) "+
+			"+ MATCH(title, authors, description) AGAINST(?) AS score", query, query, query).
+		Where("MATCH(title, authors, description) AGAINST(?)", query).
+		Order("score DESC").Order("book_id").
+		Limit(limit).
+		Find(&hits).Error
+	if err != nil {
+		return nil, errors.Wrap(err, "search repo")
+	}
+
+	return hits, nil
+}
+
+func (r *Repository) Outdated(ctx context.Context, version int) (bool, error) {
+	var total, stale int64
+	err := r.db.WithContext(ctx).Table("search_document").Count(&total).Error
+	if err != nil {
+		return false, errors.Wrap(err, "count search documents repo")
+	}
+
+	err = r.db.WithContext(ctx).Table("search_document").Where("version <> ?", version).Count(&stale).Error
+	if err != nil {
+		return false, errors.Wrap(err, "count search documents repo")
+	}
+
+	return total == 0 || stale > 0, nil
+}
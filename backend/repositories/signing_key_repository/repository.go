@@ -0,0 +1,101 @@
+package signing_key_repository
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"main.go/schemas"
+	"time"
+)
+
+type Repository struct {
+	db *gorm.DB
+}
+
+func NewRepository(db *gorm.DB) *Repository {
+	return &Repository{db: db}
+}
+
+// SaveSigningKey stores key unless due is set and the newest key started signing after due. The newest key
+// is locked while checking, so that instances rotating at the same time store a single key between them.
+func (r *Repository) SaveSigningKey(ctx context.Context, key *schemas.SigningKey, due time.Time) (bool, error) {
+	saved := false
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if !due.IsZero() {
+			var newest []time.Time
+			err := tx.Table("signing_key").Clauses(clause.Locking{Strength: "UPDATE"}).
+				Order("not_before DESC").Limit(1).
+				Pluck("not_before", &newest).Error
+			if err != nil {
+				return errors.Wrap(err, "save signing key repo")
+			}
+			if len(newest) != 0 && newest[0].After(due) {
+				return nil
+			}
+		}
+
+		err := tx.Table("signing_key").Create(key).Error
+		if err != nil {
+			return errors.Wrap(err, "save signing key repo")
+		}
+
+		saved = true
+		return nil
+	})
+
+	return saved, err
+}
+
+// GetSigningKeys returns the keys that are not expired at now, newest first.
+func (r *Repository) GetSigningKeys(ctx context.Context, now time.Time) (*[]schemas.SigningKey, error) {
+	var keys []schemas.SigningKey
+	err := r.db.WithContext(ctx).Table("signing_key").
+		Where("expires_at IS NULL OR expires_at > ?", now).
+		Order("not_before DESC").
+		Find(&keys).Error
+	if err != nil {
+		return nil, errors.Wrap(err, "get signing keys repo")
+	}
+
+	return &keys, nil
+}
+
+// PageSigningKeys returns a page of the keys GetSigningKeys returns, for listing them.
+func (r *Repository) PageSigningKeys(ctx context.Context, now time.Time, page int, pageSize int) (*[]schemas.SigningKey, error) {
+	var keys []schemas.SigningKey
+	err := r.db.WithContext(ctx).Table("signing_key").
+		Where("expires_at IS NULL OR expires_at > ?", now).
+		Order("not_before DESC").Order("kid").
+		Limit(pageSize).Offset(page * pageSize).
+		Find(&keys).Error
+	if err != nil {
+		return nil, errors.Wrap(err, "page signing keys repo")
+	}
+
+	return &keys, nil
+}
+
+// ExpireSigningKeys sets the end of verification on keys that do not have one yet, except kid.
+func (r *Repository) ExpireSigningKeys(ctx context.Context, exceptKid string, expiresAt time.Time) error {
+	err := r.db.WithContext(ctx).Table("signing_key").
+		Where("kid <> ?", exceptKid).
+		Where("expires_at IS NULL").
+		Update("expires_at", expiresAt).Error
+	if err != nil {
+		return errors.Wrap(err, "expire signing keys repo")
+	}
+
+	return nil
+}
+
+func (r *Repository) DeleteExpiredSigningKeys(ctx context.Context, before time.Time) (int64, error) {
+	row := r.db.WithContext(ctx).Table("signing_key").
+		Where("expires_at < ?", before).
+		Delete(&schemas.SigningKey{})
+	if row.Error != nil {
+		return 0, errors.Wrap(row.Error, "delete expired signing keys repo")
+	}
+
+	return row.RowsAffected, nil
+}
@@ -0,0 +1,273 @@
+package token_repository
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"main.go/schemas"
+	"time"
+)
+
+type Repository struct {
+	db *gorm.DB
+}
+
+func NewRepository(db *gorm.DB) *Repository {
+	return &Repository{db: db}
+}
+
+func (r *Repository) RevokeToken(ctx context.Context, token *schemas.RevokedToken) error {
+	err := r.db.WithContext(ctx).Table("revoked_token").
+		Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&token).Error
+	if err != nil {
+		return errors.Wrap(err, "revoke token repo")
+	}
+
+	return nil
+}
+
+func (r *Repository) IsRevoked(ctx context.Context, jti uuid.UUID) (bool, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Table("revoked_token").Where("jti", jti).Count(&count).Error
+	if err != nil {
+		return false, errors.Wrap(err, "is token revoked repo")
+	}
+
+	return count > 0, nil
+}
+
+func (r *Repository) DeleteExpired(ctx context.Context, now time.Time) (int64, error) {
+	row := r.db.WithContext(ctx).Table("revoked_token").
+		Where("expires_at < ?", now).
+		Delete(&schemas.RevokedToken{})
+	if row.Error != nil {
+		return 0, errors.Wrap(row.Error, "delete expired tokens repo")
+	}
+
+	return row.RowsAffected, nil
+}
+
+func (r *Repository) SaveRefreshToken(ctx context.Context, token *schemas.RefreshToken) error {
+	err := r.db.WithContext(ctx).Table("refresh_token").Create(&token).Error
+	if err != nil {
+		return errors.Wrap(err, "save refresh token repo")
+	}
+
+	return nil
+}
+
+func (r *Repository) GetRefreshToken(ctx context.Context, tokenHash string) (*schemas.RefreshToken, error) {
+	var token schemas.RefreshToken
+	row := r.db.WithContext(ctx).Table("refresh_token").Where("token_hash", tokenHash).Find(&token)
+	if row.Error != nil {
+		return nil, errors.Wrap(row.Error, "get refresh token repo")
+	}
+
+	if row.RowsAffected == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	return &token, nil
+}
+
+// MarkRefreshTokenUsed returns false if the token had already been used, which means it is being replayed.
+func (r *Repository) MarkRefreshTokenUsed(ctx context.Context, id uuid.UUID, now time.Time) (bool, error) {
+	row := r.db.WithContext(ctx).Table("refresh_token").
+		Where("id", id).Where("used_at IS NULL").
+		Update("used_at", now)
+	if row.Error != nil {
+		return false, errors.Wrap(row.Error, "mark refresh token used repo")
+	}
+
+	return row.RowsAffected == 1, nil
+}
+
+func (r *Repository) RevokeRefreshTokenFamily(ctx context.Context, familyId uuid.UUID, now time.Time) error {
+	err := r.db.WithContext(ctx).Table("refresh_token").
+		Where("family_id", familyId).Where("revoked_at IS NULL").
+		Update("revoked_at", now).Error
+	if err != nil {
+		return errors.Wrap(err, "revoke refresh token family repo")
+	}
+
+	return nil
+}
+
+func (r *Repository) RevokeUserRefreshTokens(ctx context.Context, userId uuid.UUID, now time.Time) error {
+	err := r.db.WithContext(ctx).Table("refresh_token").
+		Where("user_id", userId).Where("revoked_at IS NULL").
+		Update("revoked_at", now).Error
+	if err != nil {
+		return errors.Wrap(err, "revoke user refresh tokens repo")
+	}
+
+	return nil
+}
+
+func (r *Repository) DeleteExpiredRefreshTokens(ctx context.Context, now time.Time) (int64, error) {
+	row := r.db.WithContext(ctx).Table("refresh_token").
+		Where("expires_at < ?", now).
+		Delete(&schemas.RefreshToken{})
+	if row.Error != nil {
+		return 0, errors.Wrap(row.Error, "delete expired refresh tokens repo")
+	}
+
+	return row.RowsAffected, nil
+}
+
+func (r *Repository) SavePasswordResetToken(ctx context.Context, token *schemas.PasswordResetToken) error {
+	err := r.db.WithContext(ctx).Table("password_reset_token").Create(&token).Error
+	if err != nil {
+		return errors.Wrap(err, "save password reset token repo")
+	}
+
+	return nil
+}
+
+func (r *Repository) GetPasswordResetToken(ctx context.Context, tokenHash string) (*schemas.PasswordResetToken, error) {
+	var token schemas.PasswordResetToken
+	row := r.db.WithContext(ctx).Table("password_reset_token").Where("token_hash", tokenHash).Find(&token)
+	if row.Error != nil {
+		return nil, errors.Wrap(row.Error, "get password reset token repo")
+	}
+
+	if row.RowsAffected == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	return &token, nil
+}
+
+// UsePasswordResetToken consumes a token. It returns false if the token was used or expired concurrently.
+func (r *Repository) UsePasswordResetToken(ctx context.Context, id uuid.UUID, now time.Time) (bool, error) {
+	row := r.db.WithContext(ctx).Table("password_reset_token").
+		Where("id", id).Where("used_at IS NULL").Where("expires_at > ?", now).
+		Update("used_at", now)
+	if row.Error != nil {
+		return false, errors.Wrap(row.Error, "use password reset token repo")
+	}
+
+	return row.RowsAffected == 1, nil
+}
+
+// InvalidatePasswordResetTokens consumes every pending token of the user, so only the latest link works.
+func (r *Repository) InvalidatePasswordResetTokens(ctx context.Context, userId uuid.UUID, now time.Time) error {
+	err := r.db.WithContext(ctx).Table("password_reset_token").
+		Where("user_id", userId).Where("used_at IS NULL").
+		Update("used_at", now).Error
+	if err != nil {
+		return errors.Wrap(err, "invalidate password reset tokens repo")
+	}
+
+	return nil
+}
+
+func (r *Repository) DeleteExpiredPasswordResetTokens(ctx context.Context, now time.Time) (int64, error) {
+	row := r.db.WithContext(ctx).Table("password_reset_token").
+		Where("expires_at < ?", now).
+		Delete(&schemas.PasswordResetToken{})
+	if row.Error != nil {
+		return 0, errors.Wrap(row.Error, "delete expired password reset tokens repo")
+	}
+
+	return row.RowsAffected, nil
+}
+
+// SaveSession creates the session or, on refresh, moves it to the newly issued token.
+func (r *Repository) SaveSession(ctx context.Context, session *schemas.Session) error {
+	err := r.db.WithContext(ctx).Table("session").
+		Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"jti", "ip", "user_agent", "request_id", "last_seen_at", "expires_at"}),
+		}).
+		Create(session).Error
+	if err != nil {
+		return errors.Wrap(err, "save session repo")
+	}
+
+	return nil
+}
+
+func (r *Repository) GetSession(ctx context.Context, id uuid.UUID) (*schemas.Session, error) {
+	var session schemas.Session
+	row := r.db.WithContext(ctx).Table("session").Where("id", id).Find(&session)
+	if row.Error != nil {
+		return nil, errors.Wrap(row.Error, "get session repo")
+	}
+
+	if row.RowsAffected == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	return &session, nil
+}
+
+// GetUserSessions returns the sessions that are neither revoked nor expired, most recently used first.
+func (r *Repository) GetUserSessions(ctx context.Context, userId uuid.UUID, now time.Time, page int, pageSize int) (*[]schemas.Session, error) {
+	var sessions []schemas.Session
+	err := r.db.WithContext(ctx).Table("session").
+		Where("user_id", userId).
+		Where("revoked_at IS NULL").
+		Where("expires_at > ?", now).
+		Order("last_seen_at DESC").Order("id").
+		Limit(pageSize).Offset(page * pageSize).
+		Find(&sessions).Error
+	if err != nil {
+		return nil, errors.Wrap(err, "get user sessions repo")
+	}
+
+	return &sessions, nil
+}
+
+// TouchSession records activity, skipping the write while last_seen_at is younger than precision.
+func (r *Repository) TouchSession(ctx context.Context, id uuid.UUID, now time.Time, precision time.Duration) error {
+	err := r.db.WithContext(ctx).Table("session").
+		Where("id", id).
+		Where("last_seen_at < ?", now.Add(-precision)).
+		Update("last_seen_at", now).Error
+	if err != nil {
+		return errors.Wrap(err, "touch session repo")
+	}
+
+	return nil
+}
+
+// RevokeSession reports false if the user has no such active session.
+func (r *Repository) RevokeSession(ctx context.Context, id, userId uuid.UUID, now time.Time) (bool, error) {
+	row := r.db.WithContext(ctx).Table("session").
+		Where("id", id).
+		Where("user_id", userId).
+		Where("revoked_at IS NULL").
+		Update("revoked_at", now)
+	if row.Error != nil {
+		return false, errors.Wrap(row.Error, "revoke session repo")
+	}
+
+	return row.RowsAffected == 1, nil
+}
+
+func (r *Repository) RevokeUserSessions(ctx context.Context, userId uuid.UUID, now time.Time) error {
+	err := r.db.WithContext(ctx).Table("session").
+		Where("user_id", userId).
+		Where("revoked_at IS NULL").
+		Update("revoked_at", now).Error
+	if err != nil {
+		return errors.Wrap(err, "revoke user sessions repo")
+	}
+
+	return nil
+}
+
+func (r *Repository) DeleteExpiredSessions(ctx context.Context, now time.Time) (int64, error) {
+	row := r.db.WithContext(ctx).Table("session").
+		Where("expires_at < ?", now).
+		Delete(&schemas.Session{})
+	if row.Error != nil {
+		return 0, errors.Wrap(row.Error, "delete expired sessions repo")
+	}
+
+	return row.RowsAffected, nil
+}
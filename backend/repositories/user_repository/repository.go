@@ -5,6 +5,7 @@ import (
 	"github.com/google/uuid"
 	"github.com/pkg/errors"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 	"main.go/schemas"
 	"time"
 )
@@ -44,3 +45,297 @@ func (r *Repository) UpdateLastLoginAt(ctx context.Context, userId uuid.UUID) er
 
 	return nil
 }
+
+func (r *Repository) GetUserById(ctx context.Context, userId uuid.UUID) (*schemas.User, error) {
+	var user schemas.User
+	row := r.db.WithContext(ctx).Table("user").Where("id", userId).Find(&user)
+	if row.Error != nil {
+		return nil, errors.Wrap(row.Error, "get user by id repo")
+	}
+
+	if row.RowsAffected == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	return &user, nil
+}
+
+// RevokeTokensBefore truncates before to milliseconds, the precision of both the column and the iat claim,
+// so that MySQL rounding cannot revoke a token issued right after the call. It returns the stored cutoff,
+// which is later than before if the tokens were already revoked up to a later moment.
+func (r *Repository) RevokeTokensBefore(ctx context.Context, userId uuid.UUID, before time.Time) (time.Time, error) {
+	before = before.Truncate(time.Millisecond)
+	var cutoff time.Time
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Table("user").
+			Where("id", userId).
+			Where("tokens_revoked_at IS NULL OR tokens_revoked_at < ?", before).
+			Update("tokens_revoked_at", before).Error
+		if err != nil {
+			return err
+		}
+
+		return tx.Table("user").Select("tokens_revoked_at").Where("id", userId).Scan(&cutoff).Error
+	})
+	if err != nil {
+		return time.Time{}, errors.Wrap(err, "revoke tokens before repo")
+	}
+
+	return cutoff.UTC(), nil
+}
+
+// UpdatePasswordHash stores a new encoded hash and drops the legacy SHA-256 columns.
+func (r *Repository) UpdatePasswordHash(ctx context.Context, userId uuid.UUID, passwordHash string) error {
+	err := r.db.WithContext(ctx).Table("user").Where("id", userId).Updates(map[string]interface{}{
+		"password_hash": passwordHash,
+		"pwd_salt":      "",
+		"pwd_hash":      "",
+		"updated_at":    time.Now().UTC(),
+	}).Error
+	if err != nil {
+		return errors.Wrap(err, "update password hash repo")
+	}
+
+	return nil
+}
+
+// ListUsers searches users by username substring and status. Soft-deleted users are excluded.
+func (r *Repository) ListUsers(ctx context.Context, page int, pageSize int, query, status string) (*[]schemas.User, int64, error) {
+	var users *[]schemas.User
+	var total int64
+
+	db := r.db.WithContext(ctx).Table("user").Where("deleted_at IS NULL")
+	if query != "" {
+		db = db.Where("LOWER(username) LIKE LOWER(?)", "%"+query+"%")
+	}
+	if status != "" {
+		db = db.Where("status", status)
+	}
+
+	err := db.Count(&total).Error
+	if err != nil {
+		return nil, 0, errors.Wrap(err, "count users repo")
+	}
+
+	err = db.Order("username ASC").
+		Limit(pageSize).Offset(page * pageSize).
+		Find(&users).Error
+	if err != nil {
+		return nil, 0, errors.Wrap(err, "list users repo")
+	}
+
+	return users, total, nil
+}
+
+// SetStatus changes the user's status and records entry in one transaction. Leaving the active status
+// also revokes every token, refresh token and session of the user. When adminRoleId is set the active
+// administrators are locked and the change is refused, reporting false, if none other would remain.
+func (r *Repository) SetStatus(ctx context.Context, userId uuid.UUID, status, reason string,
+	adminRoleId *uuid.UUID, entry *schemas.AuditLog) (bool, error) {
+	applied := false
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if adminRoleId != nil {
+			remaining, err := lockOtherAdmins(tx, *adminRoleId, userId)
+			if err != nil {
+				return errors.Wrap(err, "set user status repo")
+			}
+			if !remaining {
+				return nil
+			}
+		}
+
+		now := time.Now().UTC()
+		err := tx.Table("user").Where("id", userId).Updates(map[string]interface{}{
+			"status":        status,
+			"status_reason": reason,
+			"updated_at":    now,
+		}).Error
+		if err != nil {
+			return errors.Wrap(err, "set user status repo")
+		}
+
+		if status != schemas.UserStatusActive {
+			err = tx.Table("user").Where("id", userId).
+				Update("tokens_revoked_at", now.Truncate(time.Millisecond)).Error
+			if err != nil {
+				return errors.Wrap(err, "set user status repo")
+			}
+
+			for _, table := range []string{"refresh_token", "session"} {
+				err = tx.Table(table).Where("user_id", userId).Where("revoked_at IS NULL").
+					Update("revoked_at", now).Error
+				if err != nil {
+					return errors.Wrap(err, "set user status repo")
+				}
+			}
+		}
+
+		err = tx.Table("audit_log").Create(entry).Error
+		if err != nil {
+			return errors.Wrap(err, "set user status repo")
+		}
+
+		applied = true
+		return nil
+	})
+
+	return applied, err
+}
+
+// SoftDeleteUser marks the user as deleted. When adminRoleId is set the other active administrators
+// are locked and the deletion is refused, reporting false, if none would remain.
+func (r *Repository) SoftDeleteUser(ctx context.Context, userId uuid.UUID, adminRoleId *uuid.UUID) (bool, error) {
+	applied := false
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if adminRoleId != nil {
+			remaining, err := lockOtherAdmins(tx, *adminRoleId, userId)
+			if err != nil {
+				return errors.Wrap(err, "soft delete user repo")
+			}
+			if !remaining {
+				return nil
+			}
+		}
+
+		now := time.Now().UTC()
+		err := tx.Table("user").Where("id", userId).Updates(map[string]interface{}{
+			"deleted_at": now,
+			"updated_at": now,
+		}).Error
+		if err != nil {
+			return errors.Wrap(err, "soft delete user repo")
+		}
+
+		applied = true
+		return nil
+	})
+
+	return applied, err
+}
+
+// UpdateProfile sets the username and, unless email is nil, the email.
+func (r *Repository) UpdateProfile(ctx context.Context, userId uuid.UUID, username string, email *string) error {
+	updates := map[string]interface{}{
+		"username":   username,
+		"updated_at": time.Now().UTC(),
+	}
+	if email != nil {
+		updates["email"] = *email
+	}
+
+	err := r.db.WithContext(ctx).Table("user").Where("id", userId).Updates(updates).Error
+	if err != nil {
+		return errors.Wrap(err, "update profile repo")
+	}
+
+	return nil
+}
+
+// GetUserByEmail returns a user with an empty ID when nobody uses the email, like GetUserByUsername.
+func (r *Repository) GetUserByEmail(ctx context.Context, email string) (*schemas.User, error) {
+	var userFound schemas.User
+	err := r.db.WithContext(ctx).Table("user").
+		Where("email", email).Where("deleted_at IS NULL").
+		Find(&userFound).Error
+	if err != nil {
+		return nil, errors.Wrap(err, "get user by email repo")
+	}
+
+	return &userFound, nil
+}
+
+// AnonymizeUser strips personal data from the user row and soft-deletes it.
+func (r *Repository) AnonymizeUser(ctx context.Context, userId uuid.UUID) error {
+	now := time.Now().UTC()
+	err := r.db.WithContext(ctx).Table("user").Where("id", userId).Updates(map[string]interface{}{
+		"username":        "deleted-" + userId.String(),
+		"email":           "",
+		"password_hash":   "",
+		"pwd_salt":        "",
+		"pwd_hash":        "",
+		"status_reason":   "",
+		"totp_secret":     "",
+		"totp_enabled_at": nil,
+		"updated_at":      now,
+		"deleted_at":      now,
+	}).Error
+	if err != nil {
+		return errors.Wrap(err, "anonymize user repo")
+	}
+
+	return nil
+}
+
+// SetTotpSecret starts a new enrollment. Any enabled secret is replaced but stays disabled until confirmed.
+func (r *Repository) SetTotpSecret(ctx context.Context, userId uuid.UUID, secret string) error {
+	err := r.db.WithContext(ctx).Table("user").Where("id", userId).Updates(map[string]interface{}{
+		"totp_secret":       secret,
+		"totp_enabled_at":   nil,
+		"totp_last_counter": 0,
+		"updated_at":        time.Now().UTC(),
+	}).Error
+	if err != nil {
+		return errors.Wrap(err, "set totp secret repo")
+	}
+
+	return nil
+}
+
+func (r *Repository) EnableTotp(ctx context.Context, userId uuid.UUID, counter int64, now time.Time) error {
+	err := r.db.WithContext(ctx).Table("user").Where("id", userId).Updates(map[string]interface{}{
+		"totp_enabled_at":   now,
+		"totp_last_counter": counter,
+		"updated_at":        now,
+	}).Error
+	if err != nil {
+		return errors.Wrap(err, "enable totp repo")
+	}
+
+	return nil
+}
+
+func (r *Repository) DisableTotp(ctx context.Context, userId uuid.UUID) error {
+	err := r.db.WithContext(ctx).Table("user").Where("id", userId).Updates(map[string]interface{}{
+		"totp_secret":       "",
+		"totp_enabled_at":   nil,
+		"totp_last_counter": 0,
+		"updated_at":        time.Now().UTC(),
+	}).Error
+	if err != nil {
+		return errors.Wrap(err, "disable totp repo")
+	}
+
+	return nil
+}
+
+// UseTotpCounter moves the last accepted time step forward and reports false if
+// the step was already used, which makes concurrent replays of one code fail.
+func (r *Repository) UseTotpCounter(ctx context.Context, userId uuid.UUID, counter int64) (bool, error) {
+	row := r.db.WithContext(ctx).Table("user").
+		Where("id", userId).
+		Where("totp_last_counter < ?", counter).
+		Update("totp_last_counter", counter)
+	if row.Error != nil {
+		return false, errors.Wrap(row.Error, "use totp counter repo")
+	}
+
+	return row.RowsAffected == 1, nil
+}
+
+// lockOtherAdmins locks the active administrators other than the user until tx ends and reports whether
+// there are any, so that two transactions cannot each demote one of the last two administrators.
+func lockOtherAdmins(tx *gorm.DB, adminRoleId, userId uuid.UUID) (bool, error) {
+	var adminIds []uuid.UUID
+	err := tx.Table("user_role").Clauses(clause.Locking{Strength: "UPDATE"}).
+		Joins("JOIN user ON user.id = user_role.user_id").
+		Where("user_role.role_id", adminRoleId).
+		Where("user.deleted_at IS NULL").
+		Where("user.status = ? OR user.status = ''", schemas.UserStatusActive).
+		Where("user.id <> ?", userId).
+		Pluck("user.id", &adminIds).Error
+	if err != nil {
+		return false, err
+	}
+
+	return len(adminIds) > 0, nil
+}
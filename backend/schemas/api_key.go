@@ -0,0 +1,33 @@
+package schemas
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+// ApiKeyPrefix starts every API key so that leaked keys are easy to recognize and grep for.
+const ApiKeyPrefix = "bk_"
+
+// TokenTypeApiKey marks the token built for a request authenticated with an API key.
+const TokenTypeApiKey = "api_key"
+
+// ApiKey is a long-lived credential for scripts. Only its hash is stored; Prefix is
+// the beginning of the key, kept so that users can tell their keys apart.
+type ApiKey struct {
+	ID         uuid.UUID `json:"id" gorm:"primaryKey"`
+	UserId     uuid.UUID `json:"userId" gorm:"index"`
+	Name       string    `json:"name" gorm:"size:64"`
+	Prefix     string    `json:"prefix" gorm:"size:16"`
+	KeyHash    string    `json:"-" gorm:"type:CHAR(64);uniqueIndex"`
+	Scopes     []string  `json:"scopes" gorm:"serializer:json"`
+	ExpiresAt  time.Time `json:"expiresAt,omitempty" gorm:"default:NULL"`
+	LastUsedAt time.Time `json:"lastUsedAt,omitempty" gorm:"default:NULL"`
+	RevokedAt  time.Time `json:"revokedAt,omitempty" gorm:"default:NULL"`
+	CreatedAt  time.Time `json:"createdAt"`
+}
+
+type ApiKeyRequest struct {
+	Name   string   `json:"name" validate:"required,max=64"`
+	Scopes []string `json:"scopes" validate:"max=16"`
+	Ttl    string   `json:"ttl,omitempty"`
+}
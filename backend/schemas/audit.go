@@ -0,0 +1,34 @@
+package schemas
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+const (
+	AuditInvitationIssued   = "invitation.issued"
+	AuditInvitationRedeemed = "invitation.redeemed"
+	AuditInvitationRevoked  = "invitation.revoked"
+	AuditUserRolesChanged   = "user.roles.changed"
+	AuditUserStatusChanged  = "user.status.changed"
+	AuditUserDeleted        = "user.deleted"
+	AuditMfaEnabled         = "user.mfa.enabled"
+	AuditMfaDisabled        = "user.mfa.disabled"
+	AuditMfaReset           = "user.mfa.reset"
+	AuditMfaRecoveryCodes   = "user.mfa.recovery_codes.regenerated"
+	AuditApiKeyCreated      = "api_key.created"
+	AuditApiKeyRevoked      = "api_key.revoked"
+	AuditIdentityLinked     = "user.identity.linked"
+	AuditIdentityUnlinked   = "user.identity.unlinked"
+	AuditSigningKeyRotated  = "signing_key.rotated"
+	AuditSessionRevoked     = "user.session.revoked"
+)
+
+type AuditLog struct {
+	ID        uuid.UUID              `json:"id" gorm:"primaryKey"`
+	ActorId   uuid.UUID              `json:"actorId" gorm:"index"`
+	Action    string                 `json:"action" gorm:"index;size:64"`
+	TargetId  uuid.UUID              `json:"targetId"`
+	Details   map[string]interface{} `json:"details,omitempty" gorm:"serializer:json"`
+	CreatedAt time.Time              `json:"createdAt" gorm:"index"`
+}
@@ -0,0 +1,98 @@
+package schemas
+
+import (
+	"github.com/google/uuid"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	AuthorRoleAuthor     = "author"
+	AuthorRoleTranslator = "translator"
+	AuthorRoleEditor     = "editor"
+)
+
+type Author struct {
+	ID        uuid.UUID `json:"id" gorm:"primaryKey"`
+	Name      string    `json:"name" gorm:"unique;size:255"`
+	Biography string    `json:"biography,omitempty" gorm:"type:text"`
+	// Aliases are other spellings of the name that resolve to this author when books are saved.
+	Aliases   []string  `json:"aliases" gorm:"serializer:json"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// BookAuthor links a book to a person in some role. Position orders the people on the book.
+type BookAuthor struct {
+	BookId    uuid.UUID `json:"bookId" gorm:"primaryKey"`
+	AuthorId  uuid.UUID `json:"authorId" gorm:"primaryKey;index"`
+	Role      string    `json:"role" gorm:"primaryKey;size:16"`
+	Position  int       `json:"position"`
+	CreatedAt time.Time `json:"createdAt"`
+
+	Book   *Book   `json:"-" gorm:"foreignKey:BookId;constraint:OnDelete:CASCADE"`
+	Author *Author `json:"-" gorm:"foreignKey:AuthorId;constraint:OnDelete:CASCADE"`
+}
+
+type AuthorRef struct {
+	ID   uuid.UUID `json:"id"`
+	Name string    `json:"name"`
+	Role string    `json:"role"`
+}
+
+// BookContributor names a person of a book being saved, either by id or by name.
+// Unknown names create a new author.
+type BookContributor struct {
+	AuthorId uuid.UUID `json:"authorId,omitempty"`
+	Name     string    `json:"name,omitempty" validate:"required_without=AuthorId,max=255"`
+	Role     string    `json:"role,omitempty" validate:"omitempty,oneof=author translator editor"`
+}
+
+type AuthorRequest struct {
+	Name      string   `json:"name" validate:"required,max=255"`
+	Biography string   `json:"biography" validate:"max=10000"`
+	Aliases   []string `json:"aliases" validate:"max=20,dive,required,max=255"`
+}
+
+// AuthorMergeRequest names the duplicate folded into the author of the route.
+type AuthorMergeRequest struct {
+	AuthorId uuid.UUID `json:"authorId" validate:"required"`
+}
+
+// NormalizeAuthorName trims the name and collapses inner whitespace.
+func NormalizeAuthorName(name string) string {
+	return strings.Join(strings.Fields(name), " ")
+}
+
+// AuthorKey identifies spellings that differ only in case, punctuation, spacing, word order
+// or initials, so "Tolstoy, L." and "L. Tolstoy" both have the key "tolstoy" and the initials "l".
+// Spellings with one key are the same person only when their initials agree, see InitialsAgree.
+func AuthorKey(name string) (key string, initials string) {
+	fields := strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	var words []string
+	for _, field := range fields {
+		if utf8.RuneCountInString(field) == 1 {
+			initials += field
+		} else {
+			words = append(words, field)
+		}
+	}
+	if len(words) == 0 {
+		return initials, ""
+	}
+
+	slices.Sort(words)
+	return strings.Join(words, " "), initials
+}
+
+// InitialsAgree reports whether two sets of initials may belong to one person:
+// "" agrees with anything and "l" agrees with "ln", but "a" and "l" do not.
+func InitialsAgree(a, b string) bool {
+	return strings.HasPrefix(a, b) || strings.HasPrefix(b, a)
+}
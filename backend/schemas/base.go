@@ -6,37 +6,124 @@ import (
 )
 
 type Book struct {
-	ID          uuid.UUID   `json:"id" gorm:"primaryKey"`
-	Name        string      `json:"name"`
-	Authors     []string    `json:"authors" gorm:"serializer:json"`
-	Price       int         `json:"price"`
-	Description string      `json:"desc"`
-	Categories  []uuid.UUID `json:"categories,omitempty" gorm:"serializer:json"`
-	CreatedAt   time.Time   `json:"createdAt,omitempty"`
-	UpdatedAt   time.Time   `json:"updatedAt,omitempty"`
-	DeletedAt   time.Time   `json:"deletedAt,omitempty" gorm:"default:NULL"`
+	ID          uuid.UUID `json:"id" gorm:"primaryKey"`
+	Name        string    `json:"name"`
+	Price       int       `json:"price"`
+	Description string    `json:"desc"`
+	CreatedAt   time.Time `json:"createdAt,omitempty"`
+	UpdatedAt   time.Time `json:"updatedAt,omitempty"`
+	DeletedAt   time.Time `json:"deletedAt,omitempty" gorm:"default:NULL"`
+	// Stock counts the copies on hand; nil means stock is not tracked. It only changes
+	// through stock adjustments, never through saving the book.
+	Stock *int `json:"stock" gorm:"->;default:NULL"`
+
+	// Contributors sets the book's authors on save; nil leaves them unchanged on update.
+	Contributors []BookContributor `json:"contributors,omitempty" gorm:"-" validate:"dive"`
+	// Authors is filled from book_author when the book is read.
+	Authors []AuthorRef `json:"authors" gorm:"-"`
+	// CategoryIds sets the book's categories on save; nil leaves them unchanged on update.
+	CategoryIds []uuid.UUID `json:"categoryIds,omitempty" gorm:"-"`
+	// Categories is filled from book_category when the book is read.
+	Categories []CategoryRef `json:"categories" gorm:"-"`
+	// Breadcrumbs holds the path from the root to each of the book's categories, in book detail only.
+	Breadcrumbs [][]CategoryRef `json:"breadcrumbs,omitempty" gorm:"-"`
+	// Availability is derived from Stock when the book is read.
+	Availability string `json:"availability" gorm:"-"`
+	// Relevance and Highlights are only set on search results. Highlights maps name, authors
+	// and desc to HTML-escaped text with matched words wrapped in <mark>.
+	Relevance  float64           `json:"relevance,omitempty" gorm:"-"`
+	Highlights map[string]string `json:"highlights,omitempty" gorm:"-"`
+}
+
+type CategoryRef struct {
+	ID   uuid.UUID `json:"id"`
+	Name string    `json:"name"`
+}
+
+// BookCategory links a book to a category. Both sides are foreign keys, so links
+// cannot point at rows that do not exist.
+type BookCategory struct {
+	BookId     uuid.UUID `json:"bookId" gorm:"primaryKey"`
+	CategoryId uuid.UUID `json:"categoryId" gorm:"primaryKey;index"`
+	CreatedAt  time.Time `json:"createdAt"`
+
+	Book     *Book     `json:"-" gorm:"foreignKey:BookId;constraint:OnDelete:CASCADE"`
+	Category *Category `json:"-" gorm:"foreignKey:CategoryId;constraint:OnDelete:CASCADE"`
 }
 
 type Category struct {
-	ID        uuid.UUID `json:"id" gorm:"primaryKey"`
-	Name      string    `json:"name" gorm:"unique"`
+	ID        uuid.UUID  `json:"id" gorm:"primaryKey"`
+	Name      string     `json:"name" gorm:"unique"`
+	ParentId  *uuid.UUID `json:"parentId,omitempty" gorm:"index;default:NULL"`
+	CreatedAt time.Time  `json:"createdAt"`
+	UpdatedAt time.Time  `json:"updatedAt"`
+	DeletedAt time.Time  `json:"deletedAt,omitempty" gorm:"default:NULL"`
+
+	Children []Category `json:"children,omitempty" gorm:"-"`
+	// BookCount counts live books in the category itself and TotalBookCount distinct ones
+	// in its whole subtree. Both are only filled when counts are requested.
+	BookCount      *int64 `json:"bookCount,omitempty" gorm:"-"`
+	TotalBookCount *int64 `json:"totalBookCount,omitempty" gorm:"-"`
+}
+
+// Cart belongs to one user, who has at most one. UserId is nil once the account was deleted.
+type Cart struct {
+	ID        uuid.UUID  `json:"id" gorm:"primaryKey"`
+	UserId    *uuid.UUID `json:"userId" gorm:"uniqueIndex;default:NULL"`
+	CreatedAt time.Time  `json:"createdAt"`
+	UpdatedAt time.Time  `json:"updatedAt"`
+	DeletedAt time.Time  `json:"deletedAt,omitempty" gorm:"default:NULL"`
+
+	// Lines and TotalPrice are filled when the cart is read. The total is always
+	// recomputed from the lines.
+	Lines      []CartLine `json:"lines" gorm:"-"`
+	TotalPrice int        `json:"totalPrice" gorm:"-"`
+}
+
+// CartLine holds the copies of one book in a cart. UnitPrice is the price of the book
+// when the line was created and does not follow later price changes.
+type CartLine struct {
+	CartId    uuid.UUID `json:"-" gorm:"primaryKey"`
+	BookId    uuid.UUID `json:"bookId" gorm:"primaryKey;index"`
+	Quantity  int       `json:"quantity"`
+	UnitPrice int       `json:"unitPrice"`
 	CreatedAt time.Time `json:"createdAt"`
 	UpdatedAt time.Time `json:"updatedAt"`
-	DeletedAt time.Time `json:"deletedAt,omitempty" gorm:"default:NULL"`
+
+	Cart *Cart `json:"-" gorm:"foreignKey:CartId;constraint:OnDelete:CASCADE"`
+	Book *Book `json:"-" gorm:"foreignKey:BookId;constraint:OnDelete:CASCADE"`
 }
 
-type Cart struct {
-	ID         uuid.UUID   `json:"id" gorm:"primaryKey"`
-	UserId     uuid.UUID   `json:"userId"`
-	BookIds    []uuid.UUID `json:"bookIds" gorm:"serializer:json"`
-	TotalPrice int         `json:"totalPrice"`
-	CreatedAt  time.Time   `json:"createdAt"`
-	UpdatedAt  time.Time   `json:"updatedAt"`
-	DeletedAt  time.Time   `json:"deletedAt,omitempty" gorm:"default:NULL"`
+type CartAddRequest struct {
+	ID uuid.UUID `json:"id" validate:"required"`
+	// Quantity defaults to one copy.
+	Quantity int `json:"quantity" validate:"omitempty,min=1,max=1000"`
+}
+
+type CartLineRequest struct {
+	// Quantity replaces the number of copies; zero removes the line.
+	Quantity *int `json:"quantity" validate:"required,min=0,max=1000"`
 }
 
 type LoginRequest struct {
-	Username string `json:"username"`
-	Password string `json:"password"`
-	Key      string `json:"key,omitempty"`
+	Username   string `json:"username"`
+	Password   string `json:"password"`
+	Email      string `json:"email,omitempty" validate:"omitempty,email"`
+	InviteCode string `json:"inviteCode,omitempty"`
+}
+
+type ProfileRequest struct {
+	Username string `json:"username" validate:"required,min=3,max=64"`
+	// Email is left unchanged when absent. It cannot be cleared here since password
+	// reset and identity linking rely on it.
+	Email *string `json:"email,omitempty" validate:"omitempty,email,max=255"`
+}
+
+type PasswordChangeRequest struct {
+	CurrentPassword string `json:"currentPassword" validate:"required"`
+	NewPassword     string `json:"newPassword" validate:"required,min=8,max=128"`
+}
+
+type AccountDeletionRequest struct {
+	Password string `json:"password" validate:"required"`
 }
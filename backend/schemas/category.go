@@ -0,0 +1,114 @@
+package schemas
+
+import (
+	"github.com/google/uuid"
+	"slices"
+	"strings"
+)
+
+// What happens to the books of a deleted category.
+const (
+	CategoryDeleteRefuse   = "refuse"
+	CategoryDeleteDetach   = "detach"
+	CategoryDeleteReassign = "reassign"
+)
+
+type CategoryMergeRequest struct {
+	TargetId uuid.UUID `json:"targetId" validate:"required"`
+}
+
+type CategoryMoveRequest struct {
+	// ParentId is the new parent; null moves the category to the top level.
+	ParentId *uuid.UUID `json:"parentId"`
+}
+
+// CategoryTree indexes a flat list of categories by parent. Categories whose parent
+// is missing from the list are treated as roots.
+type CategoryTree struct {
+	byId     map[uuid.UUID]Category
+	children map[uuid.UUID][]uuid.UUID
+	roots    []uuid.UUID
+}
+
+func NewCategoryTree(categories []Category) *CategoryTree {
+	tree := &CategoryTree{
+		byId:     make(map[uuid.UUID]Category, len(categories)),
+		children: make(map[uuid.UUID][]uuid.UUID),
+	}
+	for _, category := range categories {
+		tree.byId[category.ID] = category
+	}
+	for _, category := range categories {
+		if category.ParentId != nil {
+			if _, ok := tree.byId[*category.ParentId]; ok {
+				tree.children[*category.ParentId] = append(tree.children[*category.ParentId], category.ID)
+				continue
+			}
+		}
+		tree.roots = append(tree.roots, category.ID)
+	}
+
+	return tree
+}
+
+func (r *CategoryTree) Contains(id uuid.UUID) bool {
+	_, ok := r.byId[id]
+	return ok
+}
+
+// Nested returns the roots with their descendants filled into Children.
+func (r *CategoryTree) Nested() []Category {
+	return r.nest(r.roots)
+}
+
+func (r *CategoryTree) nest(ids []uuid.UUID) []Category {
+	categories := make([]Category, 0, len(ids))
+	for _, id := range ids {
+		category := r.byId[id]
+		category.Children = r.nest(r.children[id])
+		categories = append(categories, category)
+	}
+	slices.SortFunc(categories, func(a, b Category) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+
+	return categories
+}
+
+// Subtree returns id followed by the ids of all its descendants.
+func (r *CategoryTree) Subtree(id uuid.UUID) []uuid.UUID {
+	ids := []uuid.UUID{id}
+	for i := 0; i < len(ids); i++ {
+		ids = append(ids, r.children[ids[i]]...)
+	}
+
+	return ids
+}
+
+// Path returns the categories from the root down to id.
+func (r *CategoryTree) Path(id uuid.UUID) []CategoryRef {
+	var path []CategoryRef
+	for {
+		category, ok := r.byId[id]
+		if !ok || slices.ContainsFunc(path, func(ref CategoryRef) bool { return ref.ID == id }) {
+			break
+		}
+		path = append(path, CategoryRef{ID: category.ID, Name: category.Name})
+		if category.ParentId == nil {
+			break
+		}
+		id = *category.ParentId
+	}
+	slices.Reverse(path)
+
+	return path
+}
+
+// CanMove reports whether id can be placed under parentId without creating a cycle.
+func (r *CategoryTree) CanMove(id uuid.UUID, parentId *uuid.UUID) bool {
+	if parentId == nil {
+		return true
+	}
+
+	return !slices.Contains(r.Subtree(id), *parentId)
+}
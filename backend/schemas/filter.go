@@ -0,0 +1,60 @@
+package schemas
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+const (
+	CategoryMatchAny = "any"
+	CategoryMatchAll = "all"
+)
+
+// BookFilter narrows book listings. Unset fields do not filter.
+type BookFilter struct {
+	MinPrice *int `json:"minPrice,omitempty"`
+	MaxPrice *int `json:"maxPrice,omitempty"`
+	// CategoryIds match books in the categories or their subcategories, in any of them or in all of
+	// them depending on CategoryMatch.
+	CategoryIds   []uuid.UUID `json:"categoryIds,omitempty"`
+	CategoryMatch string      `json:"categoryMatch,omitempty"`
+	// AuthorIds match books with any of the authors.
+	AuthorIds   []uuid.UUID `json:"authorIds,omitempty"`
+	CreatedFrom *time.Time  `json:"createdFrom,omitempty"`
+	// CreatedTo is exclusive.
+	CreatedTo *time.Time `json:"createdTo,omitempty"`
+	Phrase    string     `json:"phrase,omitempty"`
+
+	// CategoryGroups and BookIds are resolved by the search service from CategoryIds and Phrase.
+	// Books must be in one category of every group. A nil BookIds does not filter.
+	CategoryGroups [][]uuid.UUID `json:"-"`
+	BookIds        []uuid.UUID   `json:"-"`
+}
+
+// BookFacets counts the books matching a filter by category, author and price bucket. Each facet
+// ignores its own part of the filter, so that it shows what choosing another value would give.
+// Category facets ignore the categories only when any of them may match.
+type BookFacets struct {
+	Categories []FacetCount  `json:"categories"`
+	Authors    []FacetCount  `json:"authors"`
+	Prices     []PriceBucket `json:"prices"`
+}
+
+type FacetCount struct {
+	ID    uuid.UUID `json:"id"`
+	Name  string    `json:"name"`
+	Count int64     `json:"count"`
+}
+
+// PriceBucket counts books priced from Min up to, but excluding, Max. The last bucket has no Max.
+type PriceBucket struct {
+	Min   int   `json:"min"`
+	Max   *int  `json:"max,omitempty"`
+	Count int64 `json:"count"`
+}
+
+// Empty reports whether the filter lets every book through.
+func (r *BookFilter) Empty() bool {
+	return r.MinPrice == nil && r.MaxPrice == nil && len(r.CategoryIds) == 0 && len(r.AuthorIds) == 0 &&
+		r.CreatedFrom == nil && r.CreatedTo == nil && r.Phrase == "" && len(r.CategoryGroups) == 0 && r.BookIds == nil
+}
@@ -0,0 +1,41 @@
+package schemas
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+// UserIdentity links an account at an OpenID Connect provider to a local user.
+type UserIdentity struct {
+	ID          uuid.UUID `json:"id" gorm:"primaryKey"`
+	UserId      uuid.UUID `json:"userId" gorm:"index"`
+	Issuer      string    `json:"issuer" gorm:"size:255;uniqueIndex:idx_identity_subject"`
+	Subject     string    `json:"subject" gorm:"size:255;uniqueIndex:idx_identity_subject"`
+	Email       string    `json:"email,omitempty" gorm:"size:255"`
+	CreatedAt   time.Time `json:"createdAt"`
+	LastLoginAt time.Time `json:"lastLoginAt" gorm:"default:NULL"`
+}
+
+// OidcState remembers an authorization request until the provider redirects back.
+// LinkUserId is set when a signed-in user links an identity instead of logging in.
+type OidcState struct {
+	StateHash    string `gorm:"type:CHAR(64);primaryKey"`
+	Nonce        string `gorm:"size:64"`
+	CodeVerifier string `gorm:"size:128"`
+	LinkUserId   uuid.UUID
+	ExpiresAt    time.Time `gorm:"index"`
+	CreatedAt    time.Time
+}
+
+// OidcLoginCode is handed to the frontend after a successful callback in place of the tokens,
+// which it exchanges once for them. Only the hash of the code is stored.
+type OidcLoginCode struct {
+	CodeHash  string `gorm:"type:CHAR(64);primaryKey"`
+	UserId    uuid.UUID
+	ExpiresAt time.Time `gorm:"index"`
+	CreatedAt time.Time
+}
+
+type OidcExchangeRequest struct {
+	Code string `json:"code" validate:"required"`
+}
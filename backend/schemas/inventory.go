@@ -0,0 +1,59 @@
+package schemas
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+// Reasons of a stock adjustment.
+const (
+	StockReceived   = "received"
+	StockSold       = "sold"
+	StockDamaged    = "damaged"
+	StockCorrection = "correction"
+)
+
+// Availability of a book as shown in listings.
+const (
+	AvailabilityInStock    = "in_stock"
+	AvailabilityLowStock   = "low_stock"
+	AvailabilityOutOfStock = "out_of_stock"
+)
+
+// LowStockThreshold is the stock at or below which a book is reported as low on stock.
+const LowStockThreshold = 5
+
+// StockAdjustment is one entry of a book's stock ledger. Quantity is the signed change
+// and Balance the stock right after it.
+type StockAdjustment struct {
+	ID        uuid.UUID `json:"id" gorm:"primaryKey"`
+	BookId    uuid.UUID `json:"bookId" gorm:"index"`
+	Reason    string    `json:"reason" gorm:"size:16"`
+	Quantity  int       `json:"quantity"`
+	Balance   int       `json:"balance"`
+	Note      string    `json:"note,omitempty"`
+	ActorId   uuid.UUID `json:"actorId"`
+	CreatedAt time.Time `json:"createdAt" gorm:"index"`
+
+	Book *Book `json:"-" gorm:"foreignKey:BookId;constraint:OnDelete:CASCADE"`
+}
+
+// StockAdjustmentRequest posts an adjustment. Quantity counts copies and must be positive,
+// except for corrections where it is the signed change.
+type StockAdjustmentRequest struct {
+	Reason   string `json:"reason" validate:"required,oneof=received sold damaged correction"`
+	Quantity int    `json:"quantity" validate:"required"`
+	Note     string `json:"note" validate:"max=500"`
+}
+
+// Availability describes stock for listings. Books whose stock is not tracked are always in stock.
+// Copies sitting in carts are not reserved and still count as in stock.
+func Availability(stock *int) string {
+	switch {
+	case stock == nil || *stock > LowStockThreshold:
+		return AvailabilityInStock
+	case *stock > 0:
+		return AvailabilityLowStock
+	}
+	return AvailabilityOutOfStock
+}
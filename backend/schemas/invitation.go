@@ -0,0 +1,25 @@
+package schemas
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+// Invitation grants a role to whoever registers with its code. Only the code hash is stored.
+type Invitation struct {
+	ID         uuid.UUID `json:"id" gorm:"primaryKey"`
+	CodeHash   string    `json:"-" gorm:"type:CHAR(64);uniqueIndex"`
+	RoleId     uuid.UUID `json:"roleId"`
+	RoleName   string    `json:"role" gorm:"->;-:migration"`
+	CreatedBy  uuid.UUID `json:"createdBy"`
+	ExpiresAt  time.Time `json:"expiresAt"`
+	RedeemedBy uuid.UUID `json:"redeemedBy,omitempty"`
+	RedeemedAt time.Time `json:"redeemedAt,omitempty" gorm:"default:NULL"`
+	RevokedAt  time.Time `json:"revokedAt,omitempty" gorm:"default:NULL"`
+	CreatedAt  time.Time `json:"createdAt"`
+}
+
+type InvitationRequest struct {
+	Role string `json:"role" validate:"required"`
+	Ttl  string `json:"ttl,omitempty"`
+}
@@ -0,0 +1,11 @@
+package schemas
+
+import "time"
+
+// LoginAttempt tracks failed logins for a limiter key such as "user:alice" or "ip:10.0.0.1".
+type LoginAttempt struct {
+	Key           string    `json:"key" gorm:"primaryKey;size:320"`
+	Failures      int       `json:"failures"`
+	BlockedUntil  time.Time `json:"blockedUntil,omitempty" gorm:"default:NULL"`
+	LastFailureAt time.Time `json:"lastFailureAt" gorm:"index"`
+}
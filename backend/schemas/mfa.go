@@ -0,0 +1,47 @@
+package schemas
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+// TokenTypeMfaPending marks tokens that only allow completing a login with a second factor.
+const TokenTypeMfaPending = "mfa_pending"
+
+// RecoveryCode is a single-use replacement for a TOTP code. Only its hash is stored.
+type RecoveryCode struct {
+	ID        uuid.UUID `json:"id" gorm:"primaryKey"`
+	UserId    uuid.UUID `json:"userId" gorm:"index"`
+	CodeHash  string    `json:"-" gorm:"type:CHAR(64);uniqueIndex"`
+	UsedAt    time.Time `json:"usedAt" gorm:"default:NULL"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type TotpEnrollment struct {
+	Secret string `json:"secret"`
+	Uri    string `json:"uri"`
+}
+
+type MfaCodeRequest struct {
+	Code string `json:"code" validate:"required,max=32"`
+}
+
+type MfaLoginRequest struct {
+	MfaToken string `json:"mfaToken" validate:"required"`
+	Code     string `json:"code" validate:"required,max=32"`
+}
+
+type MfaDisableRequest struct {
+	Password string `json:"password" validate:"required"`
+	Code     string `json:"code" validate:"required,max=32"`
+}
+
+// MfaChallenge is returned by login instead of tokens when the user has 2FA enabled.
+type MfaChallenge struct {
+	MfaRequired bool   `json:"mfaRequired"`
+	MfaToken    string `json:"mfaToken"`
+}
+
+type RecoveryCodes struct {
+	RecoveryCodes []string `json:"recoveryCodes"`
+}
@@ -0,0 +1,94 @@
+package schemas
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"strconv"
+)
+
+// Total modes of PageRequest.
+const (
+	TotalExact = "exact"
+	// TotalEstimate counts exactly up to a threshold and estimates beyond it.
+	TotalEstimate = "estimate"
+	TotalNone     = "none"
+)
+
+// Cursor points right after the last book of a page by its sort value and id, so that the next
+// page does not shift when books are added or removed before it. It only holds for its sort.
+type Cursor struct {
+	SortBy  string    `json:"s"`
+	OrderBy string    `json:"o"`
+	Value   string    `json:"v"`
+	ID      uuid.UUID `json:"i"`
+}
+
+// Encode makes the opaque form of the cursor handed to clients.
+func (r *Cursor) Encode() string {
+	data, _ := json.Marshal(r)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// Arg returns the sort value typed like the column it is compared with.
+func (r *Cursor) Arg() any {
+	if r.SortBy == "price" {
+		price, _ := strconv.Atoi(r.Value)
+		return price
+	}
+	return r.Value
+}
+
+// ParseCursor decodes a cursor made by Encode.
+func ParseCursor(value string) (*Cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, errors.Wrap(err, "parse cursor")
+	}
+
+	var cursor Cursor
+	err = json.Unmarshal(data, &cursor)
+	if err != nil {
+		return nil, errors.Wrap(err, "parse cursor")
+	}
+	if cursor.ID == uuid.Nil || cursor.OrderBy != "ASC" && cursor.OrderBy != "DESC" {
+		return nil, errors.New("parse cursor: incomplete cursor")
+	}
+	if cursor.SortBy == "price" {
+		_, err = strconv.Atoi(cursor.Value)
+		if err != nil {
+			return nil, errors.Wrap(err, "parse cursor")
+		}
+	}
+
+	return &cursor, nil
+}
+
+// PageRequest selects a page of a listing: the one after Cursor, or without a cursor the
+// Page-th one of PageSize books.
+type PageRequest struct {
+	Cursor   *Cursor
+	Page     int
+	PageSize int
+	SortBy   string
+	OrderBy  string
+	// Total is TotalExact, TotalEstimate or TotalNone.
+	Total string
+}
+
+type Sort struct {
+	By    string `json:"by"`
+	Order string `json:"order"`
+}
+
+// BookPage is the envelope of book listings. NextCursor is empty on the last page.
+type BookPage struct {
+	Books          []Book      `json:"books"`
+	NextCursor     string      `json:"nextCursor,omitempty"`
+	Total          *int64      `json:"total,omitempty"`
+	TotalEstimated bool        `json:"totalEstimated,omitempty"`
+	Sort           Sort        `json:"sort"`
+	PageSize       int         `json:"pageSize"`
+	Facets         *BookFacets `json:"facets,omitempty"`
+}
@@ -0,0 +1,69 @@
+package schemas
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+const (
+	PermCatalogWrite = "catalog:write"
+	PermOrdersRead   = "orders:read"
+	PermOrdersWrite  = "orders:write"
+	PermUsersRead    = "users:read"
+	PermUsersWrite   = "users:write"
+	PermRolesWrite   = "roles:write"
+	PermInvitesWrite = "invites:write"
+	PermAuditRead    = "audit:read"
+	PermKeysWrite    = "keys:write"
+)
+
+var Permissions = []string{
+	PermCatalogWrite,
+	PermOrdersRead,
+	PermOrdersWrite,
+	PermUsersRead,
+	PermUsersWrite,
+	PermRolesWrite,
+	PermInvitesWrite,
+	PermAuditRead,
+	PermKeysWrite,
+}
+
+const (
+	RoleAdmin         = "admin"
+	RoleCatalogEditor = "catalog_editor"
+	RoleOrderManager  = "order_manager"
+	RoleSupport       = "support"
+)
+
+// DefaultRoles are created on startup. The admin role is always reset to hold every permission.
+var DefaultRoles = []Role{
+	{Name: RoleAdmin, Description: "Full access", Permissions: Permissions},
+	{Name: RoleCatalogEditor, Description: "Manages books and categories", Permissions: []string{PermCatalogWrite}},
+	{Name: RoleOrderManager, Description: "Manages carts and orders", Permissions: []string{PermOrdersRead, PermOrdersWrite}},
+	{Name: RoleSupport, Description: "Looks up customers and their orders", Permissions: []string{PermUsersRead, PermOrdersRead}},
+}
+
+type Role struct {
+	ID          uuid.UUID `json:"id" gorm:"primaryKey"`
+	Name        string    `json:"name" gorm:"uniqueIndex;size:64" validate:"required,max=64"`
+	Description string    `json:"description"`
+	Permissions []string  `json:"permissions" gorm:"serializer:json"`
+	CreatedAt   time.Time `json:"createdAt"`
+	UpdatedAt   time.Time `json:"updatedAt"`
+}
+
+type UserRole struct {
+	UserId    uuid.UUID `json:"userId" gorm:"primaryKey"`
+	RoleId    uuid.UUID `json:"roleId" gorm:"primaryKey;index"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type UserRolesRequest struct {
+	Roles []string `json:"roles" validate:"required"`
+}
+
+type UserStatusRequest struct {
+	Status string `json:"status" validate:"required,oneof=active disabled banned"`
+	Reason string `json:"reason,omitempty" validate:"max=255"`
+}
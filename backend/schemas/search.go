@@ -0,0 +1,36 @@
+package schemas
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+// SearchDocument holds the analyzed text of a book, as produced by the search service.
+// The FULLTEXT indexes are only used by the MySQL index.
+type SearchDocument struct {
+	BookId      uuid.UUID `json:"bookId" gorm:"primaryKey"`
+	Title       string    `json:"title" gorm:"type:text;index:ft_search_title,class:FULLTEXT;index:ft_search_all,class:FULLTEXT,priority:1"`
+	Authors     string    `json:"authors" gorm:"type:text;index:ft_search_authors,class:FULLTEXT;index:ft_search_all,class:FULLTEXT,priority:2"`
+	Description string    `json:"description" gorm:"type:text;index:ft_search_all,class:FULLTEXT,priority:3"`
+	// Version is the analyzer version that produced the document.
+	Version   int       `json:"version"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+type SearchHit struct {
+	BookId uuid.UUID
+	Score  float64
+}
+
+const (
+	SuggestionTitle    = "title"
+	SuggestionAuthor   = "author"
+	SuggestionCategory = "category"
+)
+
+// Suggestion completes a search prefix with a book title, an author or a category.
+type Suggestion struct {
+	Kind string    `json:"kind"`
+	ID   uuid.UUID `json:"id"`
+	Text string    `json:"text"`
+}
@@ -0,0 +1,14 @@
+package schemas
+
+import "time"
+
+// SigningKey is a token signing key shared by all backend instances. A key signs from
+// NotBefore until a newer key starts, and verifies until ExpiresAt.
+type SigningKey struct {
+	Kid        string    `json:"kid" gorm:"primaryKey;size:64"`
+	Algorithm  string    `json:"algorithm" gorm:"size:16"`
+	PrivateKey string    `json:"-" gorm:"type:TEXT"`
+	NotBefore  time.Time `json:"notBefore" gorm:"index"`
+	ExpiresAt  time.Time `json:"expiresAt,omitempty" gorm:"default:NULL"`
+	CreatedAt  time.Time `json:"createdAt"`
+}
@@ -0,0 +1,74 @@
+package schemas
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+type RevokedToken struct {
+	Jti       uuid.UUID `json:"jti" gorm:"primaryKey"`
+	UserId    uuid.UUID `json:"userId" gorm:"index"`
+	ExpiresAt time.Time `json:"expiresAt" gorm:"index"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type LogoutAllRequest struct {
+	Before *time.Time `json:"before,omitempty"`
+}
+
+// RefreshToken is a single-use opaque token. Tokens rotated from the same login share a FamilyId.
+type RefreshToken struct {
+	ID        uuid.UUID `json:"id" gorm:"primaryKey"`
+	UserId    uuid.UUID `json:"userId" gorm:"index"`
+	FamilyId  uuid.UUID `json:"familyId" gorm:"index"`
+	TokenHash string    `json:"-" gorm:"type:CHAR(64);uniqueIndex"`
+	ExpiresAt time.Time `json:"expiresAt"`
+	UsedAt    time.Time `json:"usedAt,omitempty" gorm:"default:NULL"`
+	RevokedAt time.Time `json:"revokedAt,omitempty" gorm:"default:NULL"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refreshToken" validate:"required"`
+}
+
+type TokenPair struct {
+	Token        string `json:"token"`
+	RefreshToken string `json:"refreshToken"`
+}
+
+type PasswordResetToken struct {
+	ID        uuid.UUID `json:"id" gorm:"primaryKey"`
+	UserId    uuid.UUID `json:"userId" gorm:"index"`
+	TokenHash string    `json:"-" gorm:"type:CHAR(64);uniqueIndex"`
+	ExpiresAt time.Time `json:"expiresAt"`
+	UsedAt    time.Time `json:"usedAt,omitempty" gorm:"default:NULL"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type PasswordResetRequest struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+type PasswordResetConfirmRequest struct {
+	Token       string `json:"token" validate:"required"`
+	NewPassword string `json:"newPassword" validate:"required,min=8,max=128"`
+}
+
+// Session is one login on one device. Its ID is the refresh token family and the sid claim
+// of every access token issued in it, so revoking it ends both.
+type Session struct {
+	ID         uuid.UUID `json:"id" gorm:"primaryKey"`
+	UserId     uuid.UUID `json:"userId" gorm:"index"`
+	Jti        uuid.UUID `json:"jti"`
+	Ip         string    `json:"ip" gorm:"size:64"`
+	UserAgent  string    `json:"userAgent" gorm:"size:512"`
+	RequestId  string    `json:"requestId" gorm:"size:64"`
+	CreatedAt  time.Time `json:"createdAt"`
+	LastSeenAt time.Time `json:"lastSeenAt"`
+	ExpiresAt  time.Time `json:"expiresAt" gorm:"index"`
+	RevokedAt  time.Time `json:"revokedAt,omitempty" gorm:"default:NULL"`
+
+	// Current marks the session of the token the list was requested with.
+	Current bool `json:"current" gorm:"-"`
+}
@@ -1,50 +1,150 @@
 package schemas
 
 import (
-	"crypto/sha256"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 	"github.com/pkg/errors"
-	"main.go/utils/settings_utils"
+	"main.go/utils/password_utils"
+	"main.go/utils/signing_utils"
+	"slices"
 	"time"
 )
 
+const (
+	UserStatusActive   = "active"
+	UserStatusDisabled = "disabled"
+	UserStatusBanned   = "banned"
+)
+
 type User struct {
-	ID          uuid.UUID `json:"id" gorm:"primary_key"`
-	Username    string    `json:"username" gorm:"uniqueIndex,length:256" validate:"len=256"`
-	PWDSalt     string    `json:"-"`
-	PWDHash     string    `json:"-" gorm:"type:BINARY(32)"`
-	Admin       bool      `json:"admin"`
-	CreatedAt   time.Time `json:"createdAt"`
-	UpdatedAt   time.Time `json:"updatedAt"`
-	LastLoginAt time.Time `json:"lastLoginAt"`
-	DeletedAt   time.Time `gorm:"default:NULL"`
-}
-
-func (r *User) GenerateTokenJWT() (string, error) {
+	ID       uuid.UUID `json:"id" gorm:"primary_key"`
+	Username string    `json:"username" gorm:"uniqueIndex,length:256" validate:"len=256"`
+	Email    string    `json:"email,omitempty" gorm:"size:255;index"`
+	// PWDSalt and PWDHash hold legacy salted SHA-256 hashes until the user logs in and gets rehashed.
+	PWDSalt      string    `json:"-"`
+	PWDHash      string    `json:"-" gorm:"type:BINARY(32)"`
+	PasswordHash string    `json:"-" gorm:"type:VARCHAR(255)"`
+	CreatedAt    time.Time `json:"createdAt"`
+	UpdatedAt    time.Time `json:"updatedAt"`
+	LastLoginAt  time.Time `json:"lastLoginAt"`
+	Status       string    `json:"status" gorm:"size:16;default:active;index"`
+	StatusReason string    `json:"statusReason,omitempty"`
+	// TokensRevokedAt invalidates every token issued to the user before this moment.
+	TokensRevokedAt time.Time `json:"-" gorm:"default:NULL"`
+	DeletedAt       time.Time `json:"deletedAt,omitempty" gorm:"default:NULL"`
+	// TotpSecret is stored on enrollment but only trusted once TotpEnabledAt is set.
+	// TotpLastCounter is the last accepted time step, so that a code cannot be used twice.
+	TotpSecret      string    `json:"-" gorm:"size:64"`
+	TotpEnabledAt   time.Time `json:"totpEnabledAt,omitempty" gorm:"default:NULL"`
+	TotpLastCounter int64     `json:"-"`
+
+	// Roles and Permissions are loaded from user_role before issuing a token.
+	Roles       []string `json:"roles,omitempty" gorm:"-"`
+	Permissions []string `json:"permissions,omitempty" gorm:"-"`
+	// MfaEnrollmentRequired strips roles from issued tokens until the user enrolls in 2FA.
+	MfaEnrollmentRequired bool `json:"mfaEnrollmentRequired,omitempty" gorm:"-"`
+}
+
+// IsActive reports whether the user may log in and use issued tokens.
+func (r *User) IsActive() bool {
+	return r.DeletedAt.IsZero() && (r.Status == "" || r.Status == UserStatusActive)
+}
+
+// HasPassword reports whether the user can log in locally. Users created through
+// OpenID Connect have no password until they reset one.
+func (r *User) HasPassword() bool {
+	return r.PasswordHash != "" || r.PWDHash != ""
+}
+
+// MfaEnabled reports whether logging in requires a second factor.
+func (r *User) MfaEnabled() bool {
+	return !r.TotpEnabledAt.IsZero()
+}
+
+// HasAdminRights reports whether any loaded role grants a permission. Every permission
+// is a staff one, so this is what FORCE_ADMIN_MFA applies to.
+func (r *User) HasAdminRights() bool {
+	return len(r.Permissions) > 0
+}
+
+// EncodedPassword returns the stored hash, wrapping legacy SHA-256 columns into the encoded format.
+func (r *User) EncodedPassword() string {
+	if r.PasswordHash != "" {
+		return r.PasswordHash
+	}
+
+	return password_utils.EncodeLegacy(r.PWDSalt, r.PWDHash)
+}
+
+// SetRoles flattens the permissions of the given roles onto the user.
+func (r *User) SetRoles(roles []Role) {
+	r.Roles = make([]string, 0, len(roles))
+	r.Permissions = make([]string, 0)
+	for _, role := range roles {
+		r.Roles = append(r.Roles, role.Name)
+		for _, permission := range role.Permissions {
+			if !slices.Contains(r.Permissions, permission) {
+				r.Permissions = append(r.Permissions, permission)
+			}
+		}
+	}
+}
+
+// issuedAt returns the iat of a new token: now, or the first millisecond after TokensRevokedAt when the
+// tokens were revoked within the current millisecond, so that a token issued right after a revocation
+// is not revoked with the tokens before it.
+func (r *User) issuedAt() time.Time {
 	now := time.Now().UTC()
-	exp := now.Add(settings_utils.Settings.JwtTtl)
+	if !r.TokensRevokedAt.IsZero() && !now.Truncate(time.Millisecond).After(r.TokensRevokedAt) {
+		return r.TokensRevokedAt.Truncate(time.Millisecond).Add(time.Millisecond).UTC()
+	}
+
+	return now
+}
+
+// GenerateTokenJWT carries roles and permissions in the token. The admin claim is kept
+// for clients that only toggle admin UI; authorization is done on perms.
+// The token takes its jti from the session, names it in the sid claim and expires after ttl.
+func (r *User) GenerateTokenJWT(keyring *signing_utils.Keyring, session *Session, ttl time.Duration) (string, error) {
+	now := r.issuedAt()
+	exp := now.Add(ttl)
 	claims := jwt.MapClaims{
 		"sub":      r.ID.String(),
 		"username": r.Username,
 		"exp":      exp.Unix(),
-		"iat":      now.Unix(),
-		"jti":      uuid.New(),
-		"admin":    false,
+		"iat":      float64(now.UnixMilli()) / 1000,
+		"jti":      session.Jti,
+		"sid":      session.ID,
+		"roles":    r.Roles,
+		"perms":    r.Permissions,
+		"admin":    slices.Contains(r.Roles, RoleAdmin),
 	}
-	if r.Admin {
-		claims["admin"] = true
+	if r.MfaEnrollmentRequired {
+		claims["mfaEnrollmentRequired"] = true
 	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	t, err := token.SignedString([]byte(settings_utils.Settings.SigningKey))
+	t, err := keyring.Sign(claims)
 	if err != nil {
 		return "", errors.Wrap(err, "failed to generate JWT token")
 	}
 	return t, nil
 }
 
-func (r *User) VerifyPassword(pwd string) bool {
-	h := sha256.New()
-	h.Write([]byte(r.PWDSalt + pwd))
-	return r.PWDHash == string(h.Sum(nil))
+// GenerateMfaTokenJWT issues the short-lived token proving the password step of a login.
+// Its typ claim keeps it from being accepted anywhere but the second login step.
+func (r *User) GenerateMfaTokenJWT(keyring *signing_utils.Keyring, ttl time.Duration) (string, error) {
+	now := r.issuedAt()
+	exp := now.Add(ttl)
+	claims := jwt.MapClaims{
+		"sub":      r.ID.String(),
+		"username": r.Username,
+		"exp":      exp.Unix(),
+		"iat":      float64(now.UnixMilli()) / 1000,
+		"jti":      uuid.New(),
+		"typ":      TokenTypeMfaPending,
+	}
+	t, err := keyring.Sign(claims)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to generate JWT token")
+	}
+	return t, nil
 }
@@ -0,0 +1,216 @@
+package api_key_service
+
+import (
+	"context"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+	"gorm.io/gorm"
+	"main.go/repositories/api_key_repository"
+	"main.go/repositories/audit_repository"
+	"main.go/repositories/role_repository"
+	"main.go/repositories/user_repository"
+	"main.go/schemas"
+	"main.go/utils/secret_utils"
+	"main.go/utils/settings_utils"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	maxApiKeys        = 20
+	maxApiKeyTtl      = 365 * 24 * time.Hour
+	lastUsedPrecision = time.Minute
+	// prefixLength characters of the key are stored in clear to identify it in listings.
+	prefixLength = len(schemas.ApiKeyPrefix) + 6
+)
+
+type Service struct {
+	repository      *api_key_repository.Repository
+	userRepository  *user_repository.Repository
+	roleRepository  *role_repository.Repository
+	auditRepository *audit_repository.Repository
+}
+
+func NewService(repository *api_key_repository.Repository, userRepository *user_repository.Repository,
+	roleRepository *role_repository.Repository, auditRepository *audit_repository.Repository) *Service {
+	return &Service{repository: repository, userRepository: userRepository,
+		roleRepository: roleRepository, auditRepository: auditRepository}
+}
+
+// CreateApiKey mints a key limited to the given scopes, which must be permissions the user
+// holds. The plain key is returned only here; the database keeps its hash.
+func (r *Service) CreateApiKey(ctx context.Context, userId uuid.UUID, req *schemas.ApiKeyRequest) (string, *schemas.ApiKey, error) {
+	var expiresAt time.Time
+	now := time.Now().UTC()
+	if req.Ttl != "" {
+		ttl, err := time.ParseDuration(req.Ttl)
+		if err != nil || ttl <= 0 || ttl > maxApiKeyTtl {
+			return "", nil, ErrInvalidTtl
+		}
+		expiresAt = now.Add(ttl)
+	}
+
+	user, err := r.getUserWithRoles(ctx, userId)
+	if err != nil {
+		return "", nil, errors.Wrap(err, "create api key")
+	}
+
+	scopes := make([]string, 0, len(req.Scopes))
+	for _, scope := range req.Scopes {
+		if !slices.Contains(schemas.Permissions, scope) {
+			return "", nil, errors.Wrap(ErrUnknownScope, scope)
+		}
+		if !slices.Contains(user.Permissions, scope) {
+			return "", nil, errors.Wrap(ErrScopeNotGranted, scope)
+		}
+		if !slices.Contains(scopes, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+
+	count, err := r.repository.CountApiKeys(ctx, userId)
+	if err != nil {
+		return "", nil, errors.Wrap(err, "create api key")
+	}
+	if count >= maxApiKeys {
+		return "", nil, ErrTooManyApiKeys
+	}
+
+	key := schemas.ApiKeyPrefix + strings.ToLower(secret_utils.NewSecret())
+	apiKey := &schemas.ApiKey{
+		ID:        uuid.New(),
+		UserId:    userId,
+		Name:      req.Name,
+		Prefix:    key[:prefixLength],
+		KeyHash:   secret_utils.HashSecret(key),
+		Scopes:    scopes,
+		ExpiresAt: expiresAt,
+		CreatedAt: now,
+	}
+	err = r.repository.SaveApiKey(ctx, apiKey)
+	if err != nil {
+		return "", nil, errors.Wrap(err, "create api key")
+	}
+
+	err = r.auditRepository.Record(ctx, userId, schemas.AuditApiKeyCreated, apiKey.ID,
+		map[string]interface{}{"name": apiKey.Name, "scopes": scopes})
+	if err != nil {
+		return "", nil, errors.Wrap(err, "create api key")
+	}
+
+	zerolog.Ctx(ctx).Info().Str("userId", userId.String()).
+		Str("apiKeyId", apiKey.ID.String()).
+		Msg("api.key.created")
+	return key, apiKey, nil
+}
+
+func (r *Service) ListApiKeys(ctx context.Context, userId uuid.UUID) (*[]schemas.ApiKey, error) {
+	apiKeys, err := r.repository.GetApiKeys(ctx, userId)
+	if err != nil {
+		return nil, errors.Wrap(err, "list api keys")
+	}
+
+	return apiKeys, nil
+}
+
+func (r *Service) RevokeApiKey(ctx context.Context, userId, id uuid.UUID) error {
+	revoked, err := r.repository.RevokeApiKey(ctx, id, userId, time.Now().UTC())
+	if err != nil {
+		return errors.Wrap(err, "revoke api key")
+	}
+	if !revoked {
+		return gorm.ErrRecordNotFound
+	}
+
+	err = r.auditRepository.Record(ctx, userId, schemas.AuditApiKeyRevoked, id, nil)
+	if err != nil {
+		return errors.Wrap(err, "revoke api key")
+	}
+
+	zerolog.Ctx(ctx).Info().Str("userId", userId.String()).
+		Str("apiKeyId", id.String()).
+		Msg("api.key.revoked")
+	return nil
+}
+
+// Authenticate resolves a key into a token shaped like an access token, so that handlers
+// and requirePermission work unchanged. Permissions are the key's scopes the user still holds.
+func (r *Service) Authenticate(ctx context.Context, key string) (*jwt.Token, error) {
+	if !strings.HasPrefix(key, schemas.ApiKeyPrefix) {
+		return nil, ErrInvalidApiKey
+	}
+
+	apiKey, err := r.repository.GetApiKeyByHash(ctx, secret_utils.HashSecret(key))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvalidApiKey
+		}
+		return nil, errors.Wrap(err, "authenticate api key")
+	}
+
+	now := time.Now().UTC()
+	if !apiKey.RevokedAt.IsZero() || (!apiKey.ExpiresAt.IsZero() && now.After(apiKey.ExpiresAt)) {
+		return nil, ErrInvalidApiKey
+	}
+
+	user, err := r.getUserWithRoles(ctx, apiKey.UserId)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvalidApiKey
+		}
+		return nil, errors.Wrap(err, "authenticate api key")
+	}
+	if !user.IsActive() {
+		return nil, ErrInvalidApiKey
+	}
+
+	perms := make([]interface{}, 0, len(apiKey.Scopes))
+	if !settings_utils.Settings.ForceAdminMfa || !user.HasAdminRights() || user.MfaEnabled() {
+		for _, scope := range apiKey.Scopes {
+			if slices.Contains(user.Permissions, scope) {
+				perms = append(perms, scope)
+			}
+		}
+	}
+
+	err = r.repository.TouchApiKey(ctx, apiKey.ID, now, lastUsedPrecision)
+	if err != nil {
+		return nil, errors.Wrap(err, "authenticate api key")
+	}
+
+	return &jwt.Token{
+		Claims: jwt.MapClaims{
+			"sub":      user.ID.String(),
+			"username": user.Username,
+			"jti":      apiKey.ID.String(),
+			"iat":      float64(now.UnixMilli()) / 1000,
+			"typ":      schemas.TokenTypeApiKey,
+			"perms":    perms,
+		},
+		Valid: true,
+	}, nil
+}
+
+func (r *Service) getUserWithRoles(ctx context.Context, userId uuid.UUID) (*schemas.User, error) {
+	user, err := r.userRepository.GetUserById(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	roles, err := r.roleRepository.GetUserRoles(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+	user.SetRoles(*roles)
+
+	return user, nil
+}
+
+var ErrInvalidApiKey = errors.New("invalid api key")
+var ErrInvalidTtl = errors.New("invalid ttl")
+var ErrUnknownScope = errors.New("unknown scope")
+var ErrScopeNotGranted = errors.New("scope not granted to the user")
+var ErrTooManyApiKeys = errors.New("too many api keys")
@@ -0,0 +1,27 @@
+package audit_service
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+	"main.go/repositories/audit_repository"
+	"main.go/schemas"
+)
+
+type Service struct {
+	repository *audit_repository.Repository
+}
+
+func NewService(repository *audit_repository.Repository) *Service {
+	return &Service{repository: repository}
+}
+
+func (r *Service) ListAuditLogs(ctx context.Context, page int, pageSize int, action string) (*[]schemas.AuditLog, error) {
+	logs, err := r.repository.GetAuditLogs(ctx, page, pageSize, action)
+	if err != nil {
+		return nil, errors.Wrap(err, "list audit logs")
+	}
+
+	zerolog.Ctx(ctx).Info().Int("amount", len(*logs)).Msg("audit.logs.listed")
+	return logs, nil
+}
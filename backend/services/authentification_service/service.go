@@ -2,72 +2,128 @@ package authentification_service
 
 import (
 	"context"
-	"crypto/rand"
-	"crypto/sha256"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 	"github.com/pkg/errors"
 	"github.com/rs/zerolog"
+	"gorm.io/gorm"
+	"main.go/repositories/audit_repository"
+	"main.go/repositories/invitation_repository"
+	"main.go/repositories/role_repository"
+	"main.go/repositories/token_repository"
 	"main.go/repositories/user_repository"
 	"main.go/schemas"
+	"main.go/utils/client_utils"
+	"main.go/utils/jwt_utils"
+	"main.go/utils/password_utils"
+	"main.go/utils/secret_utils"
 	"main.go/utils/settings_utils"
+	"main.go/utils/signing_utils"
+	"strings"
 	"time"
 )
 
+// sessionTouchPrecision limits how often a session's last_seen_at is written.
+const sessionTouchPrecision = time.Minute
+
 type Service struct {
-	repository *user_repository.Repository
+	repository           *user_repository.Repository
+	tokenRepository      *token_repository.Repository
+	roleRepository       *role_repository.Repository
+	invitationRepository *invitation_repository.Repository
+	auditRepository      *audit_repository.Repository
+	hasher               password_utils.Hasher
+	keyring              *signing_utils.Keyring
 }
 
-func NewService(repository *user_repository.Repository) *Service {
-	return &Service{repository: repository}
+func NewService(repository *user_repository.Repository, tokenRepository *token_repository.Repository,
+	roleRepository *role_repository.Repository, invitationRepository *invitation_repository.Repository,
+	auditRepository *audit_repository.Repository, hasher password_utils.Hasher,
+	keyring *signing_utils.Keyring) *Service {
+	return &Service{repository: repository, tokenRepository: tokenRepository,
+		roleRepository: roleRepository, invitationRepository: invitationRepository,
+		auditRepository: auditRepository, hasher: hasher, keyring: keyring}
 }
 
-func (r *Service) RegisterUser(ctx context.Context, req *schemas.LoginRequest) (string, error) {
+func (r *Service) RegisterUser(ctx context.Context, req *schemas.LoginRequest) (*schemas.TokenPair, error) {
 	userFound, err := r.repository.GetUserByUsername(ctx, req.Username)
 	if err != nil {
-		return "", errors.Wrap(err, "register user")
+		return nil, errors.Wrap(err, "register user")
 	}
 	if userFound.ID != uuid.Nil {
-		return "", ErrAlreadyTaken
+		return nil, ErrAlreadyTaken
+	}
+
+	email := strings.ToLower(strings.TrimSpace(req.Email))
+	if email != "" {
+		emailFound, err := r.repository.GetUserByEmail(ctx, email)
+		if err != nil {
+			return nil, errors.Wrap(err, "register user")
+		}
+		if emailFound.ID != uuid.Nil {
+			return nil, ErrEmailTaken
+		}
 	}
 
-	salt, hashSum, err := saltPassword(req.Password)
+	passwordHash, err := r.hasher.Hash(req.Password)
 	if err != nil {
-		return "", errors.Wrap(err, "failed to salt password")
+		return nil, errors.Wrap(err, "failed to hash password")
 	}
 
 	now := time.Now().UTC()
 	user := schemas.User{
-		ID:          uuid.New(),
-		Username:    req.Username,
-		PWDSalt:     salt,
-		PWDHash:     hashSum,
-		Admin:       false,
-		CreatedAt:   now,
-		UpdatedAt:   now,
-		LastLoginAt: now,
-	}
-	if req.Key == settings_utils.Settings.AdminKey {
-		user.Admin = true
+		ID:           uuid.New(),
+		Username:     req.Username,
+		Email:        email,
+		PasswordHash: passwordHash,
+		Status:       schemas.UserStatusActive,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+		LastLoginAt:  now,
 	}
+
+	var invitation *schemas.Invitation
+	if req.InviteCode != "" {
+		invitation, err = r.claimInvitation(ctx, req.InviteCode, user.ID, now)
+		if err != nil {
+			return nil, errors.Wrap(err, "register user")
+		}
+	}
+
 	err = r.repository.CreateUser(ctx, &user)
 	if err != nil {
-		return "", errors.Wrap(err, "register user")
+		if invitation != nil {
+			releaseErr := r.invitationRepository.Release(ctx, invitation.ID)
+			if releaseErr != nil {
+				zerolog.Ctx(ctx).Error().Err(releaseErr).Msg("invitation.release.failed")
+			}
+		}
+		return nil, errors.Wrap(err, "register user")
+	}
+
+	if invitation != nil {
+		err = r.roleRepository.AssignRole(ctx, user.ID, invitation.RoleId)
+		if err != nil {
+			return nil, errors.Wrap(err, "register user")
+		}
+
+		err = r.auditRepository.Record(ctx, user.ID, schemas.AuditInvitationRedeemed, invitation.ID,
+			map[string]interface{}{"roleId": invitation.RoleId, "issuedBy": invitation.CreatedBy})
+		if err != nil {
+			return nil, errors.Wrap(err, "register user")
+		}
 	}
 
 	zerolog.Ctx(ctx).
 		Info().Str("username", req.Username).
 		Msg("new.user.registration.request.successful")
 
-	token, err := user.GenerateTokenJWT()
+	tokens, err := r.IssueTokens(ctx, &user, uuid.Nil)
 	if err != nil {
-		return "", errors.Wrap(err, "failed to generate JWT token")
+		return nil, errors.Wrap(err, "register user")
 	}
 
-	zerolog.Ctx(ctx).
-		Info().Str("token", token).
-		Msg("new.token.generated")
-	return token, nil
+	return tokens, nil
 }
 
 func (r *Service) LoginUser(ctx context.Context, req *schemas.LoginRequest) (*schemas.User, error) {
@@ -76,10 +132,29 @@ func (r *Service) LoginUser(ctx context.Context, req *schemas.LoginRequest) (*sc
 		return nil, errors.Wrap(err, "failed to get user")
 	}
 
-	if !user.VerifyPassword(req.Password) {
+	if user.ID == uuid.Nil {
+		// Hash anyway so that response time does not reveal whether the username exists.
+		_, _ = r.hasher.Hash(req.Password)
 		return nil, ErrWrongPassword
 	}
 
+	encoded := user.EncodedPassword()
+
+	ok, err := r.hasher.Verify(req.Password, encoded)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to verify password")
+	}
+	if !ok {
+		return nil, ErrWrongPassword
+	}
+	if !user.IsActive() {
+		return nil, ErrUserDisabled
+	}
+
+	if r.hasher.NeedsRehash(encoded) {
+		r.rehashPassword(ctx, user, req.Password)
+	}
+
 	err = r.repository.UpdateLastLoginAt(ctx, user.ID)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to update lastLoginAt")
@@ -93,24 +168,414 @@ func (r *Service) LoginUser(ctx context.Context, req *schemas.LoginRequest) (*sc
 	return user, nil
 }
 
-func (r *Service) LogoutUser(user *jwt.Token) (string, error) {
-	claims := user.Claims.(jwt.MapClaims)
-	claims["exp"] = time.Now().UTC()
-	user.Claims = claims
-	token, err := user.SignedString(settings_utils.Settings.SigningKey)
+func (r *Service) LogoutUser(ctx context.Context, token *jwt.Token) error {
+	jti, err := jwt_utils.GetJti(token)
+	if err != nil {
+		return errors.Wrap(err, "logout user")
+	}
+	userId, err := jwt_utils.GetUserId(token)
+	if err != nil {
+		return errors.Wrap(err, "logout user")
+	}
+	exp, err := jwt_utils.GetExpiresAt(token)
+	if err != nil {
+		return errors.Wrap(err, "logout user")
+	}
+
+	err = r.tokenRepository.RevokeToken(ctx, &schemas.RevokedToken{
+		Jti:       jti,
+		UserId:    userId,
+		ExpiresAt: exp,
+		CreatedAt: time.Now().UTC(),
+	})
+	if err != nil {
+		return errors.Wrap(err, "logout user")
+	}
+
+	sessionId, err := jwt_utils.GetSessionId(token)
+	if err == nil {
+		err = r.revokeSession(ctx, userId, sessionId, time.Now().UTC())
+		if err != nil {
+			return errors.Wrap(err, "logout user")
+		}
+	}
+
+	zerolog.Ctx(ctx).Info().Str("jti", jti.String()).Msg("token.revoked")
+	return nil
+}
+
+// claimInvitation reserves the invitation for userId so that it cannot be redeemed twice.
+func (r *Service) claimInvitation(ctx context.Context, code string, userId uuid.UUID, now time.Time) (*schemas.Invitation, error) {
+	invitation, err := r.invitationRepository.GetInvitationByCodeHash(ctx, secret_utils.HashSecret(code))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvalidInvitation
+		}
+		return nil, errors.Wrap(err, "claim invitation")
+	}
+
+	claimed, err := r.invitationRepository.Claim(ctx, invitation.ID, userId, now)
 	if err != nil {
-		return "", errors.Wrap(err, "logout user")
+		return nil, errors.Wrap(err, "claim invitation")
+	}
+	if !claimed {
+		return nil, ErrInvalidInvitation
 	}
 
-	return token, nil
+	return invitation, nil
 }
 
-func saltPassword(password string) (salt string, hashSum string, err error) {
-	salt = rand.Text()
-	h := sha256.New()
-	_, err = h.Write([]byte(salt + password))
-	return salt, string(h.Sum(nil)), err
+// IssueTokens generates an access token and a refresh token for the user.
+// Passing uuid.Nil as familyId starts a new refresh token family.
+func (r *Service) IssueTokens(ctx context.Context, user *schemas.User, familyId uuid.UUID) (*schemas.TokenPair, error) {
+	roles, err := r.roleRepository.GetUserRoles(ctx, user.ID)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load roles")
+	}
+	user.SetRoles(*roles)
+	if settings_utils.Settings.ForceAdminMfa && user.HasAdminRights() && !user.MfaEnabled() {
+		user.MfaEnrollmentRequired = true
+		user.SetRoles(nil)
+	}
+
+	if familyId == uuid.Nil {
+		familyId = uuid.New()
+	}
+	now := time.Now().UTC()
+	client := client_utils.FromContext(ctx)
+	session := &schemas.Session{
+		ID:         familyId,
+		UserId:     user.ID,
+		Jti:        uuid.New(),
+		Ip:         client.Ip,
+		UserAgent:  client.UserAgent,
+		RequestId:  client.RequestId,
+		CreatedAt:  now,
+		LastSeenAt: now,
+		ExpiresAt:  now.Add(settings_utils.Settings.RefreshTtl),
+	}
+
+	token, err := user.GenerateTokenJWT(r.keyring, session, settings_utils.Settings.JwtTtl)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to generate JWT token")
+	}
+
+	err = r.tokenRepository.SaveSession(ctx, session)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to save session")
+	}
+
+	refreshToken := secret_utils.NewSecret()
+	err = r.tokenRepository.SaveRefreshToken(ctx, &schemas.RefreshToken{
+		ID:        uuid.New(),
+		UserId:    user.ID,
+		FamilyId:  familyId,
+		TokenHash: secret_utils.HashSecret(refreshToken),
+		ExpiresAt: now.Add(settings_utils.Settings.RefreshTtl),
+		CreatedAt: now,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to save refresh token")
+	}
+
+	zerolog.Ctx(ctx).
+		Info().Str("userId", user.ID.String()).
+		Str("familyId", familyId.String()).
+		Msg("new.token.generated")
+	return &schemas.TokenPair{Token: token, RefreshToken: refreshToken}, nil
+}
+
+// IssueMfaChallenge is issued instead of tokens when the password was right but the user has 2FA enabled.
+func (r *Service) IssueMfaChallenge(ctx context.Context, user *schemas.User) (*schemas.MfaChallenge, error) {
+	token, err := user.GenerateMfaTokenJWT(r.keyring, settings_utils.Settings.MfaTokenTtl)
+	if err != nil {
+		return nil, errors.Wrap(err, "issue mfa challenge")
+	}
+
+	zerolog.Ctx(ctx).Info().Str("userId", user.ID.String()).Msg("mfa.challenge.issued")
+	return &schemas.MfaChallenge{MfaRequired: true, MfaToken: token}, nil
+}
+
+// VerifyMfaToken returns the user who passed the password step the token was issued for.
+func (r *Service) VerifyMfaToken(ctx context.Context, mfaToken string) (*schemas.User, error) {
+	token, err := jwt.Parse(mfaToken, r.keyring.Keyfunc, jwt.WithExpirationRequired())
+	if err != nil || jwt_utils.GetType(token) != schemas.TokenTypeMfaPending {
+		return nil, ErrInvalidMfaToken
+	}
+
+	userId, err := jwt_utils.GetUserId(token)
+	if err != nil {
+		return nil, ErrInvalidMfaToken
+	}
+	iat, err := jwt_utils.GetIssuedAt(token)
+	if err != nil {
+		return nil, ErrInvalidMfaToken
+	}
+
+	user, err := r.repository.GetUserById(ctx, userId)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvalidMfaToken
+		}
+		return nil, errors.Wrap(err, "verify mfa token")
+	}
+	if !user.IsActive() || !user.MfaEnabled() {
+		return nil, ErrInvalidMfaToken
+	}
+	if !user.TokensRevokedAt.IsZero() && !iat.After(user.TokensRevokedAt) {
+		return nil, ErrInvalidMfaToken
+	}
+
+	return user, nil
+}
+
+// RefreshTokens rotates a refresh token. Presenting a token that was already rotated
+// means it leaked, so the whole family is revoked and the legitimate holder has to log in again.
+func (r *Service) RefreshTokens(ctx context.Context, refreshToken string) (*schemas.TokenPair, error) {
+	stored, err := r.tokenRepository.GetRefreshToken(ctx, secret_utils.HashSecret(refreshToken))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvalidRefreshToken
+		}
+		return nil, errors.Wrap(err, "refresh tokens")
+	}
+
+	now := time.Now().UTC()
+	if !stored.RevokedAt.IsZero() || now.After(stored.ExpiresAt) {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	fresh, err := r.tokenRepository.MarkRefreshTokenUsed(ctx, stored.ID, now)
+	if err != nil {
+		return nil, errors.Wrap(err, "refresh tokens")
+	}
+	if !fresh {
+		err = r.revokeSession(ctx, stored.UserId, stored.FamilyId, now)
+		if err != nil {
+			return nil, errors.Wrap(err, "refresh tokens")
+		}
+
+		zerolog.Ctx(ctx).Warn().Str("userId", stored.UserId.String()).
+			Str("familyId", stored.FamilyId.String()).
+			Msg("refresh.token.reuse.detected")
+		return nil, ErrRefreshTokenReused
+	}
+
+	user, err := r.repository.GetUserById(ctx, stored.UserId)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvalidRefreshToken
+		}
+		return nil, errors.Wrap(err, "refresh tokens")
+	}
+	if !user.IsActive() {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	tokens, err := r.IssueTokens(ctx, user, stored.FamilyId)
+	if err != nil {
+		return nil, errors.Wrap(err, "refresh tokens")
+	}
+
+	return tokens, nil
+}
+
+// RevokeRefreshToken ends the refresh token family the given token belongs to.
+func (r *Service) RevokeRefreshToken(ctx context.Context, userId uuid.UUID, refreshToken string) error {
+	stored, err := r.tokenRepository.GetRefreshToken(ctx, secret_utils.HashSecret(refreshToken))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return errors.Wrap(err, "revoke refresh token")
+	}
+	if stored.UserId != userId {
+		return nil
+	}
+
+	err = r.revokeSession(ctx, userId, stored.FamilyId, time.Now().UTC())
+	if err != nil {
+		return errors.Wrap(err, "revoke refresh token")
+	}
+
+	return nil
+}
+
+// revokeSession ends the session together with its refresh token family.
+func (r *Service) revokeSession(ctx context.Context, userId, sessionId uuid.UUID, now time.Time) error {
+	err := r.tokenRepository.RevokeRefreshTokenFamily(ctx, sessionId, now)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.tokenRepository.RevokeSession(ctx, sessionId, userId, now)
+	return err
+}
+
+// LogoutEverywhere invalidates all tokens issued to the user before the given moment.
+// Moments in the future are clamped to now so that tokens issued later keep working.
+func (r *Service) LogoutEverywhere(ctx context.Context, userId uuid.UUID, before time.Time) error {
+	now := time.Now().UTC()
+	if before.IsZero() || before.After(now) {
+		before = now
+	}
+
+	_, err := r.repository.RevokeTokensBefore(ctx, userId, before.UTC())
+	if err != nil {
+		return errors.Wrap(err, "logout everywhere")
+	}
+
+	err = r.tokenRepository.RevokeUserRefreshTokens(ctx, userId, now)
+	if err != nil {
+		return errors.Wrap(err, "logout everywhere")
+	}
+
+	err = r.tokenRepository.RevokeUserSessions(ctx, userId, now)
+	if err != nil {
+		return errors.Wrap(err, "logout everywhere")
+	}
+
+	zerolog.Ctx(ctx).Info().Str("userId", userId.String()).
+		Time("before", before).
+		Msg("user.tokens.revoked")
+	return nil
+}
+
+func (r *Service) CheckToken(ctx context.Context, token *jwt.Token) error {
+	if jwt_utils.GetType(token) != "" {
+		return ErrTokenRevoked
+	}
+
+	jti, err := jwt_utils.GetJti(token)
+	if err != nil {
+		return errors.Wrap(err, "check token")
+	}
+	userId, err := jwt_utils.GetUserId(token)
+	if err != nil {
+		return errors.Wrap(err, "check token")
+	}
+	iat, err := jwt_utils.GetIssuedAt(token)
+	if err != nil {
+		return errors.Wrap(err, "check token")
+	}
+
+	revoked, err := r.tokenRepository.IsRevoked(ctx, jti)
+	if err != nil {
+		return errors.Wrap(err, "check token")
+	}
+	if revoked {
+		return ErrTokenRevoked
+	}
+
+	user, err := r.repository.GetUserById(ctx, userId)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrTokenRevoked
+		}
+		return errors.Wrap(err, "check token")
+	}
+	if !user.IsActive() {
+		return ErrTokenRevoked
+	}
+	if !user.TokensRevokedAt.IsZero() && !iat.After(user.TokensRevokedAt) {
+		return ErrTokenRevoked
+	}
+
+	// Tokens issued before sessions were recorded carry no sid and are only checked as above.
+	sessionId, err := jwt_utils.GetSessionId(token)
+	if err != nil {
+		return nil
+	}
+	session, err := r.tokenRepository.GetSession(ctx, sessionId)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrTokenRevoked
+		}
+		return errors.Wrap(err, "check token")
+	}
+	if !session.RevokedAt.IsZero() {
+		return ErrTokenRevoked
+	}
+
+	err = r.tokenRepository.TouchSession(ctx, sessionId, time.Now().UTC(), sessionTouchPrecision)
+	if err != nil {
+		zerolog.Ctx(ctx).Warn().Err(err).Msg("session.touch.failed")
+	}
+
+	return nil
+}
+
+// PruneRevokedTokens periodically drops revocation entries, refresh and password reset tokens that have expired anyway.
+// It blocks until ctx is cancelled.
+func (r *Service) PruneRevokedTokens(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			now := time.Now().UTC()
+			deleted, err := r.tokenRepository.DeleteExpired(ctx, now)
+			if err != nil {
+				zerolog.Ctx(ctx).Error().Err(err).Msg("revoked.tokens.prune.failed")
+				continue
+			}
+
+			zerolog.Ctx(ctx).Info().Int64("amount", deleted).Msg("revoked.tokens.pruned")
+
+			deleted, err = r.tokenRepository.DeleteExpiredRefreshTokens(ctx, now)
+			if err != nil {
+				zerolog.Ctx(ctx).Error().Err(err).Msg("refresh.tokens.prune.failed")
+				continue
+			}
+
+			zerolog.Ctx(ctx).Info().Int64("amount", deleted).Msg("refresh.tokens.pruned")
+
+			deleted, err = r.tokenRepository.DeleteExpiredPasswordResetTokens(ctx, now)
+			if err != nil {
+				zerolog.Ctx(ctx).Error().Err(err).Msg("password.reset.tokens.prune.failed")
+				continue
+			}
+
+			zerolog.Ctx(ctx).Info().Int64("amount", deleted).Msg("password.reset.tokens.pruned")
+
+			deleted, err = r.tokenRepository.DeleteExpiredSessions(ctx, now)
+			if err != nil {
+				zerolog.Ctx(ctx).Error().Err(err).Msg("sessions.prune.failed")
+				continue
+			}
+
+			zerolog.Ctx(ctx).Info().Int64("amount", deleted).Msg("sessions.pruned")
+		}
+	}
+}
+
+// rehashPassword upgrades a legacy or outdated hash. Failing to do so must not fail the login.
+func (r *Service) rehashPassword(ctx context.Context, user *schemas.User, password string) {
+	passwordHash, err := r.hasher.Hash(password)
+	if err != nil {
+		zerolog.Ctx(ctx).Error().Err(err).Str("userId", user.ID.String()).Msg("password.rehash.failed")
+		return
+	}
+
+	err = r.repository.UpdatePasswordHash(ctx, user.ID, passwordHash)
+	if err != nil {
+		zerolog.Ctx(ctx).Error().Err(err).Str("userId", user.ID.String()).Msg("password.rehash.failed")
+		return
+	}
+
+	user.PasswordHash = passwordHash
+	zerolog.Ctx(ctx).Info().Str("userId", user.ID.String()).Msg("password.rehashed")
 }
 
 var ErrWrongPassword = errors.New("wrong password")
 var ErrAlreadyTaken = errors.New("username already taken")
+var ErrEmailTaken = errors.New("email already taken")
+var ErrTokenRevoked = errors.New("token revoked")
+var ErrUserDisabled = errors.New("user is disabled")
+var ErrInvalidInvitation = errors.New("invalid invitation code")
+var ErrInvalidRefreshToken = errors.New("invalid refresh token")
+var ErrRefreshTokenReused = errors.New("refresh token reused")
+var ErrInvalidMfaToken = errors.New("invalid or expired mfa token")
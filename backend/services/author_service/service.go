@@ -0,0 +1,121 @@
+package author_service
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+	"gorm.io/gorm"
+	"main.go/repositories/author_repository"
+	"main.go/repositories/book_repository"
+	"main.go/schemas"
+	"main.go/services/search_service"
+	"slices"
+	"time"
+)
+
+type Service struct {
+	repository     *author_repository.Repository
+	bookRepository *book_repository.Repository
+	searchService  *search_service.Service
+}
+
+func NewService(repository *author_repository.Repository, bookRepository *book_repository.Repository,
+	searchService *search_service.Service) *Service {
+	return &Service{repository: repository, bookRepository: bookRepository, searchService: searchService}
+}
+
+// GetAuthor returns the author together with a page of the books they took part in, in any role.
+func (r *Service) GetAuthor(ctx context.Context, id uuid.UUID, request *schemas.PageRequest) (*schemas.Author, *schemas.BookPage, error) {
+	author, err := r.repository.GetAuthor(ctx, id)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "get author")
+	}
+
+	page, err := r.bookRepository.PageBooks(ctx, &schemas.BookFilter{AuthorIds: []uuid.UUID{id}}, request)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "get author")
+	}
+
+	zerolog.Ctx(ctx).Info().Str("authorId", id.String()).Int("books", len(page.Books)).Msg("author.found")
+	return author, page, nil
+}
+
+// UpdateAuthor changes the name, biography and aliases. Neither the name nor an alias
+// may already identify another author, or saving books by that name would be ambiguous.
+func (r *Service) UpdateAuthor(ctx context.Context, id uuid.UUID, req *schemas.AuthorRequest) (*schemas.Author, error) {
+	author, err := r.repository.GetAuthor(ctx, id)
+	if err != nil {
+		return nil, errors.Wrap(err, "update author")
+	}
+
+	author.Name = schemas.NormalizeAuthorName(req.Name)
+	author.Biography = req.Biography
+	author.Aliases = []string{}
+	for _, alias := range req.Aliases {
+		alias = schemas.NormalizeAuthorName(alias)
+		if alias != "" && alias != author.Name && !slices.Contains(author.Aliases, alias) {
+			author.Aliases = append(author.Aliases, alias)
+		}
+	}
+	author.UpdatedAt = time.Now().UTC()
+
+	for _, name := range append([]string{author.Name}, author.Aliases...) {
+		other, err := r.repository.FindAuthor(ctx, name)
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.Wrap(err, "update author")
+		}
+		if err == nil && other.ID != id {
+			return nil, ErrAuthorNameTaken
+		}
+	}
+
+	err = r.repository.UpdateAuthor(ctx, id, author)
+	if err != nil {
+		return nil, errors.Wrap(err, "update author")
+	}
+	r.searchService.IndexAuthorBooks(ctx, id)
+
+	zerolog.Ctx(ctx).Info().Str("authorId", id.String()).Msg("author.updated")
+	return author, nil
+}
+
+// MergeAuthor folds the duplicate sourceId into the author id: its books move over and its
+// name and aliases become aliases of id, so books saved under them later resolve to id.
+func (r *Service) MergeAuthor(ctx context.Context, id uuid.UUID, sourceId uuid.UUID) (*schemas.Author, error) {
+	if id == sourceId {
+		return nil, ErrAuthorMergeSelf
+	}
+
+	author, err := r.repository.GetAuthor(ctx, id)
+	if err != nil {
+		return nil, errors.Wrap(err, "merge author")
+	}
+
+	source, err := r.repository.GetAuthor(ctx, sourceId)
+	if err != nil {
+		return nil, errors.Wrap(err, "merge author")
+	}
+
+	for _, alias := range append([]string{source.Name}, source.Aliases...) {
+		if alias != author.Name && !slices.Contains(author.Aliases, alias) {
+			author.Aliases = append(author.Aliases, alias)
+		}
+	}
+	author.UpdatedAt = time.Now().UTC()
+
+	merged, err := r.repository.MergeAuthor(ctx, author, sourceId)
+	if err != nil {
+		return nil, errors.Wrap(err, "merge author")
+	}
+	if !merged {
+		return nil, gorm.ErrRecordNotFound
+	}
+	r.searchService.IndexAuthorBooks(ctx, id)
+
+	zerolog.Ctx(ctx).Info().Str("authorId", id.String()).Str("mergedId", sourceId.String()).Msg("author.merged")
+	return author, nil
+}
+
+var ErrAuthorNameTaken = errors.New("name already belongs to another author")
+var ErrAuthorMergeSelf = errors.New("an author cannot be merged into itself")
@@ -5,37 +5,52 @@ import (
 	"github.com/google/uuid"
 	"github.com/pkg/errors"
 	"github.com/rs/zerolog"
+	"gorm.io/gorm"
+	"main.go/repositories/author_repository"
 	"main.go/repositories/book_repository"
+	"main.go/repositories/category_repository"
 	"main.go/schemas"
+	"main.go/services/search_service"
+	"slices"
 	"time"
 )
 
 type Service struct {
-	repository *book_repository.Repository
+	repository         *book_repository.Repository
+	categoryRepository *category_repository.Repository
+	authorRepository   *author_repository.Repository
+	searchService      *search_service.Service
 }
 
-func NewService(repo *book_repository.Repository) *Service {
-	return &Service{repository: repo}
+func NewService(repo *book_repository.Repository, categoryRepository *category_repository.Repository,
+	authorRepository *author_repository.Repository, searchService *search_service.Service) *Service {
+	return &Service{repository: repo, categoryRepository: categoryRepository, authorRepository: authorRepository,
+		searchService: searchService}
 }
 
-func (r *Service) GetBooks(ctx context.Context, page int, pageSize int, sortBy, orderBy string) (*[]schemas.Book, error) {
-	books, err := r.repository.GetBooks(ctx, page, pageSize, sortBy, orderBy)
+// GetBooksByCategory lists the books of the category and of all its subcategories.
+func (r *Service) GetBooksByCategory(ctx context.Context, categoryName string, request *schemas.PageRequest) (*schemas.BookPage, error) {
+	categories, err := r.categoryRepository.GetCategories(ctx)
 	if err != nil {
 		return nil, errors.Wrap(err, "get books service")
 	}
 
-	zerolog.Ctx(ctx).Info().Msg("books.found")
-	return books, nil
-}
+	index := slices.IndexFunc(*categories, func(category schemas.Category) bool {
+		return category.Name == categoryName
+	})
+	if index < 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
 
-func (r *Service) GetBooksByCategory(ctx context.Context, page int, pageSize int, categoryName string, sortBy, orderBy string) (*[]schemas.Book, error) {
-	books, err := r.repository.GetBooksByCategory(ctx, page, pageSize, categoryName, sortBy, orderBy)
+	categoryIds := schemas.NewCategoryTree(*categories).Subtree((*categories)[index].ID)
+	filter := schemas.BookFilter{CategoryGroups: [][]uuid.UUID{categoryIds}}
+	page, err := r.repository.PageBooks(ctx, &filter, request)
 	if err != nil {
 		return nil, errors.Wrap(err, "get books service")
 	}
 
 	zerolog.Ctx(ctx).Info().Str("category", categoryName).Msg("books.found")
-	return books, nil
+	return page, nil
 }
 
 func (r *Service) BookInfo(ctx context.Context, id uuid.UUID) (*schemas.Book, error) {
@@ -44,6 +59,15 @@ func (r *Service) BookInfo(ctx context.Context, id uuid.UUID) (*schemas.Book, er
 		return nil, errors.Wrap(err, "book info")
 	}
 
+	categories, err := r.categoryRepository.GetCategories(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "book info")
+	}
+	tree := schemas.NewCategoryTree(*categories)
+	for _, category := range book.Categories {
+		book.Breadcrumbs = append(book.Breadcrumbs, tree.Path(category.ID))
+	}
+
 	zerolog.Ctx(ctx).Info().Str("bookId", id.String()).Msg("book.info.found")
 	return book, nil
 }
@@ -55,21 +79,43 @@ func (r *Service) SaveBook(ctx context.Context, book *schemas.Book) error {
 	book.CreatedAt = now
 	book.UpdatedAt = now
 
-	err := r.repository.SaveBook(ctx, book)
+	err := r.checkCategories(ctx, book)
+	if err != nil {
+		return errors.Wrap(err, "save book")
+	}
+
+	err = r.resolveContributors(ctx, book, now)
 	if err != nil {
 		return errors.Wrap(err, "save book")
 	}
 
+	err = r.repository.SaveBook(ctx, book)
+	if err != nil {
+		return errors.Wrap(err, "save book")
+	}
+	r.searchService.IndexBook(ctx, id)
+
 	zerolog.Ctx(ctx).Info().Interface("book", book).Msg("book.saved")
 	return nil
 }
 
 func (r *Service) UpdateBook(ctx context.Context, id uuid.UUID, book *schemas.Book) error {
 	book.UpdatedAt = time.Now().UTC()
-	err := r.repository.UpdateBook(ctx, id, book)
+	err := r.checkCategories(ctx, book)
+	if err != nil {
+		return errors.Wrap(err, "update book")
+	}
+
+	err = r.resolveContributors(ctx, book, book.UpdatedAt)
+	if err != nil {
+		return errors.Wrap(err, "update book")
+	}
+
+	err = r.repository.UpdateBook(ctx, id, book)
 	if err != nil {
 		return errors.Wrap(err, "update book")
 	}
+	r.searchService.IndexBook(ctx, id)
 
 	zerolog.Ctx(ctx).Info().Str("id", id.String()).Msg("book.updated.successfully")
 	return nil
@@ -80,17 +126,95 @@ func (r *Service) DeleteBook(ctx context.Context, id uuid.UUID) error {
 	if err != nil {
 		return errors.Wrap(err, "delete book")
 	}
+	r.searchService.IndexBook(ctx, id)
 
 	zerolog.Ctx(ctx).Info().Str("id", id.String()).Msg("book.deleted")
 	return nil
 }
 
-func (r *Service) SearchBooks(ctx context.Context, page int, pageSize int, phrase string, sortBy, orderBy string) (*[]schemas.Book, error) {
-	books, err := r.repository.SearchBooks(ctx, page, pageSize, phrase, sortBy, orderBy)
+// Bootstrap moves category links and author names still stored on the book rows into their tables.
+func (r *Service) Bootstrap(ctx context.Context) error {
+	err := r.repository.MigrateCategoryColumn(ctx)
 	if err != nil {
-		return nil, errors.Wrap(err, "search book")
+		return errors.Wrap(err, "migrate book categories")
 	}
 
-	zerolog.Ctx(ctx).Info().Str("phrase", phrase).Int("amount", len(*books)).Msg("books.found")
-	return books, nil
+	err = r.repository.MigrateAuthorColumn(ctx)
+	if err != nil {
+		return errors.Wrap(err, "migrate book authors")
+	}
+
+	return nil
+}
+
+// resolveContributors sets AuthorId of every contributor, creating authors for unknown names,
+// defaults the role to author and drops repeated author and role pairs.
+func (r *Service) resolveContributors(ctx context.Context, book *schemas.Book, now time.Time) error {
+	if book.Contributors == nil {
+		return nil
+	}
+
+	resolved := make([]schemas.BookContributor, 0, len(book.Contributors))
+	for _, contributor := range book.Contributors {
+		if contributor.Role == "" {
+			contributor.Role = schemas.AuthorRoleAuthor
+		}
+
+		if contributor.AuthorId != uuid.Nil {
+			_, err := r.authorRepository.GetAuthor(ctx, contributor.AuthorId)
+			if err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					return ErrUnknownAuthor
+				}
+				return err
+			}
+		} else {
+			name := schemas.NormalizeAuthorName(contributor.Name)
+			if name == "" {
+				return ErrUnknownAuthor
+			}
+			author, err := r.authorRepository.FindOrCreateAuthor(ctx, name, now)
+			if err != nil {
+				return err
+			}
+			contributor.AuthorId = author.ID
+		}
+
+		if !slices.ContainsFunc(resolved, func(c schemas.BookContributor) bool {
+			return c.AuthorId == contributor.AuthorId && c.Role == contributor.Role
+		}) {
+			resolved = append(resolved, contributor)
+		}
+	}
+	book.Contributors = resolved
+
+	return nil
 }
+
+// checkCategories drops duplicate category ids and rejects ids of missing categories.
+func (r *Service) checkCategories(ctx context.Context, book *schemas.Book) error {
+	if len(book.CategoryIds) == 0 {
+		return nil
+	}
+
+	unique := make([]uuid.UUID, 0, len(book.CategoryIds))
+	for _, id := range book.CategoryIds {
+		if !slices.Contains(unique, id) {
+			unique = append(unique, id)
+		}
+	}
+	book.CategoryIds = unique
+
+	count, err := r.repository.CountCategories(ctx, unique)
+	if err != nil {
+		return err
+	}
+	if count != int64(len(unique)) {
+		return ErrUnknownCategory
+	}
+
+	return nil
+}
+
+var ErrUnknownCategory = errors.New("unknown category")
+var ErrUnknownAuthor = errors.New("unknown author")
@@ -9,10 +9,12 @@ import (
 	"main.go/repositories/book_repository"
 	"main.go/repositories/cart_repository"
 	"main.go/schemas"
-	"slices"
 	"time"
 )
 
+// maxQuantity bounds the copies of one book in a cart.
+const maxQuantity = 1000
+
 type Service struct {
 	cartRepository *cart_repository.Repository
 	bookRepository *book_repository.Repository
@@ -22,65 +24,47 @@ func NewService(cartRepo *cart_repository.Repository, bookRepo *book_repository.
 	return &Service{cartRepository: cartRepo, bookRepository: bookRepo}
 }
 
-func (r *Service) CreateCart(ctx context.Context, userId, bookId uuid.UUID) error {
-	book, err := r.bookRepository.BookInfo(ctx, bookId)
+// Bootstrap moves carts still stored as a list of book ids into cart lines.
+func (r *Service) Bootstrap(ctx context.Context) error {
+	err := r.cartRepository.MigrateBookIdsColumn(ctx)
 	if err != nil {
-		return errors.Wrap(err, "create cart")
+		return errors.Wrap(err, "migrate cart book ids")
 	}
 
-	now := time.Now().UTC()
-	cart := &schemas.Cart{
-		ID:         uuid.New(),
-		UserId:     userId,
-		BookIds:    []uuid.UUID{book.ID},
-		TotalPrice: book.Price,
-		CreatedAt:  now,
-		UpdatedAt:  now,
-	}
+	return nil
+}
 
-	err = r.cartRepository.SaveCart(ctx, cart)
+// Add puts quantity more copies of the book into the user's cart, creating the cart if needed.
+func (r *Service) Add(ctx context.Context, userId, bookId uuid.UUID, quantity int) error {
+	cart, err := r.getOrCreateCart(ctx, userId)
 	if err != nil {
-		return errors.Wrap(err, "create cart")
+		return errors.Wrap(err, "add book to cart")
 	}
 
-	zerolog.Ctx(ctx).Info().Interface("cart", cart).Msg("cart.created")
+	err = r.setLine(ctx, cart, bookId, quantity, true)
+	if err != nil {
+		return errors.Wrap(err, "add book to cart")
+	}
 
 	return nil
 }
 
-func (r *Service) Add(ctx context.Context, userId, bookId uuid.UUID) error {
-	cart, err := r.cartRepository.GetCart(ctx, userId)
-	if err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			err = r.CreateCart(ctx, userId, bookId)
-			if err != nil {
-				return errors.Wrap(err, "add book to cart")
-			}
-
-			return nil
-
-		} else {
-			return errors.Wrap(err, "add book to cart")
-		}
+// SetQuantity replaces the number of copies of a book already in the cart. Zero removes the book.
+func (r *Service) SetQuantity(ctx context.Context, userId, bookId uuid.UUID, quantity int) error {
+	if quantity == 0 {
+		return r.DeleteBook(ctx, userId, bookId)
 	}
 
-	zerolog.Ctx(ctx).Info().Str("userId", cart.UserId.String()).
-		Str("cartId", cart.ID.String()).
-		Msg("cart.found")
-
-	book, err := r.bookRepository.BookInfo(ctx, bookId)
+	cart, err := r.cartRepository.GetCart(ctx, userId)
 	if err != nil {
-		return errors.Wrap(err, "add book to cart")
+		return errors.Wrap(err, "set cart quantity")
 	}
-	cart.BookIds = append(cart.BookIds, book.ID)
-	cart.TotalPrice += book.Price
 
-	err = r.cartRepository.UpdateCart(ctx, cart)
+	err = r.setLine(ctx, cart, bookId, quantity, false)
 	if err != nil {
-		return errors.Wrap(err, "add book to cart")
+		return errors.Wrap(err, "set cart quantity")
 	}
 
-	zerolog.Ctx(ctx).Info().Interface("cart", cart).Msg("cart.updated")
 	return nil
 }
 
@@ -92,7 +76,11 @@ func (r *Service) Get(ctx context.Context, userId uuid.UUID) (*schemas.Cart, *[]
 
 	zerolog.Ctx(ctx).Info().Interface("cart", cart).Msg("cart.found")
 
-	books, err := r.bookRepository.GetBooksInCart(ctx, cart.BookIds)
+	bookIds := make([]uuid.UUID, 0, len(cart.Lines))
+	for _, line := range cart.Lines {
+		bookIds = append(bookIds, line.BookId)
+	}
+	books, err := r.bookRepository.GetBooksInCart(ctx, bookIds)
 	if err != nil {
 		return nil, nil, errors.Wrap(err, "get cart")
 	}
@@ -104,31 +92,91 @@ func (r *Service) Get(ctx context.Context, userId uuid.UUID) (*schemas.Cart, *[]
 	return cart, books, nil
 }
 
+// DeleteBook removes every copy of the book from the cart.
 func (r *Service) DeleteBook(ctx context.Context, userId, bookId uuid.UUID) error {
 	cart, err := r.cartRepository.GetCart(ctx, userId)
 	if err != nil {
 		return errors.Wrap(err, "delete book")
 	}
 
-	bookIdx := slices.Index(cart.BookIds, bookId)
-	if bookIdx == -1 {
-		return errors.Wrap(err, "cart does not contain book")
+	deleted, err := r.cartRepository.DeleteLine(ctx, cart.ID, bookId, time.Now().UTC())
+	if err != nil {
+		return errors.Wrap(err, "delete book")
+	}
+	if !deleted {
+		return ErrNotInCart
+	}
+
+	zerolog.Ctx(ctx).Info().Str("cartId", cart.ID.String()).
+		Str("bookId", bookId.String()).
+		Msg("cart.line.deleted")
+	return nil
+}
+
+func (r *Service) getOrCreateCart(ctx context.Context, userId uuid.UUID) (*schemas.Cart, error) {
+	cart, err := r.cartRepository.GetCart(ctx, userId)
+	if err == nil {
+		return cart, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
 	}
 
-	bookPrice, err := r.bookRepository.GetBookPrice(ctx, bookId)
+	now := time.Now().UTC()
+	cart = &schemas.Cart{
+		ID:        uuid.New(),
+		UserId:    &userId,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	// A concurrent request may create the cart first. The unique user_id keeps a single cart,
+	// which both requests read back.
+	created, err := r.cartRepository.CreateCart(ctx, cart)
 	if err != nil {
-		return errors.Wrap(err, "delete book")
+		return nil, err
 	}
-	cart.BookIds = slices.Delete(cart.BookIds, bookIdx, bookIdx+1)
-	cart.TotalPrice -= bookPrice
-	if cart.TotalPrice < 0 {
-		return errors.New("invalid book price")
+	if created {
+		zerolog.Ctx(ctx).Info().Interface("cart", cart).Msg("cart.created")
 	}
 
-	err = r.cartRepository.UpdateCart(ctx, cart)
+	return r.cartRepository.GetCart(ctx, userId)
+}
+
+// setLine adds quantity copies to the line of the book, or with add false replaces the copies of a line
+// already in the cart, after checking that the book is on sale and has enough copies in stock.
+// The check runs under the lock the repository takes on the book, so concurrent calls cannot
+// lose copies or put more copies into the cart than are in stock.
+func (r *Service) setLine(ctx context.Context, cart *schemas.Cart, bookId uuid.UUID, quantity int, add bool) error {
+	var total int
+	err := r.cartRepository.SetLine(ctx, cart.ID, bookId, quantity, add, time.Now().UTC(), func(stock *int, current, next int) error {
+		switch {
+		case !add && current == 0:
+			return ErrNotInCart
+		case next > maxQuantity:
+			return ErrInvalidQuantity
+		case stock != nil && *stock < next:
+			return ErrOutOfStock
+		}
+		total = next
+		return nil
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrUnknownBook
+	}
 	if err != nil {
-		return errors.Wrap(err, "delete book")
+		return err
 	}
 
+	zerolog.Ctx(ctx).Info().Str("cartId", cart.ID.String()).
+		Str("bookId", bookId.String()).
+		Int("quantity", total).
+		Msg("cart.line.set")
 	return nil
 }
+
+// ErrOutOfStock is returned when one cart would hold more copies than are in stock. Carts do not reserve
+// copies, so several carts may each hold the whole stock.
+var ErrOutOfStock = errors.New("not enough copies in stock")
+var ErrUnknownBook = errors.New("unknown book")
+var ErrNotInCart = errors.New("cart does not contain book")
+var ErrInvalidQuantity = errors.New("invalid quantity")
@@ -5,27 +5,40 @@ import (
 	"github.com/google/uuid"
 	"github.com/pkg/errors"
 	"github.com/rs/zerolog"
+	"gorm.io/gorm"
 	"main.go/repositories/category_repository"
 	"main.go/schemas"
+	"main.go/services/search_service"
 	"time"
 )
 
 type Service struct {
-	repository *category_repository.Repository
+	repository    *category_repository.Repository
+	searchService *search_service.Service
 }
 
-func NewService(repository *category_repository.Repository) *Service {
-	return &Service{repository: repository}
+func NewService(repository *category_repository.Repository, searchService *search_service.Service) *Service {
+	return &Service{repository: repository, searchService: searchService}
 }
 
-func (r *Service) ListCategories(ctx context.Context) (*[]schemas.Category, error) {
+// ListCategories returns the top level categories with their subcategories nested in Children.
+// withCounts adds live book counts to every category.
+func (r *Service) ListCategories(ctx context.Context, withCounts bool) (*[]schemas.Category, error) {
 	categories, err := r.repository.GetCategories(ctx)
 	if err != nil {
 		return nil, errors.Wrap(err, "list categories")
 	}
 
+	if withCounts {
+		err = r.countBooks(ctx, *categories)
+		if err != nil {
+			return nil, errors.Wrap(err, "list categories")
+		}
+	}
+
+	tree := schemas.NewCategoryTree(*categories).Nested()
 	zerolog.Ctx(ctx).Info().Int("amount", len(*categories)).Msg("categories.listed")
-	return categories, nil
+	return &tree, nil
 }
 
 func (r *Service) SaveCategory(ctx context.Context, category *schemas.Category) error {
@@ -35,10 +48,21 @@ func (r *Service) SaveCategory(ctx context.Context, category *schemas.Category)
 	category.CreatedAt = now
 	category.UpdatedAt = now
 
+	if category.ParentId != nil {
+		categories, err := r.repository.GetCategories(ctx)
+		if err != nil {
+			return errors.Wrap(err, "save category")
+		}
+		if !schemas.NewCategoryTree(*categories).Contains(*category.ParentId) {
+			return ErrUnknownParent
+		}
+	}
+
 	err := r.repository.SaveCategory(ctx, category)
 	if err != nil {
 		return errors.Wrap(err, "save category")
 	}
+	r.searchService.IndexCategory(ctx, id)
 
 	zerolog.Ctx(ctx).Info().Interface("category", &category).Msg("category.saved")
 	return nil
@@ -50,17 +74,137 @@ func (r *Service) UpdateCategory(ctx context.Context, id uuid.UUID, category *sc
 	if err != nil {
 		return errors.Wrap(err, "update category")
 	}
+	r.searchService.IndexCategory(ctx, id)
 
 	zerolog.Ctx(ctx).Info().Str("id", id.String()).Str("new.name", category.Name).Msg("category.updated")
 	return nil
 }
 
-func (r *Service) DeleteCategory(ctx context.Context, id uuid.UUID) error {
-	err := r.repository.DeleteCategory(ctx, id)
+// MoveCategory places the category and its subtree under parentId, or at the top level if it is nil.
+func (r *Service) MoveCategory(ctx context.Context, id uuid.UUID, parentId *uuid.UUID) error {
+	err := r.repository.MoveCategory(ctx, id, parentId, time.Now().UTC(), func(categories []schemas.Category) error {
+		tree := schemas.NewCategoryTree(categories)
+		if !tree.Contains(id) {
+			return gorm.ErrRecordNotFound
+		}
+		if parentId != nil && !tree.Contains(*parentId) {
+			return ErrUnknownParent
+		}
+		if !tree.CanMove(id, parentId) {
+			return ErrCategoryCycle
+		}
+		return nil
+	})
+	if err != nil {
+		return errors.Wrap(err, "move category")
+	}
+
+	zerolog.Ctx(ctx).Info().Str("id", id.String()).Interface("parentId", parentId).Msg("category.moved")
+	return nil
+}
+
+// DeleteCategory removes the category and moves its subcategories up a level. The strategy decides
+// what happens to its books: refuse fails unless the category has neither books nor subcategories,
+// detach unlinks the books and reassign links them to targetId instead.
+func (r *Service) DeleteCategory(ctx context.Context, id uuid.UUID, strategy string, targetId *uuid.UUID) error {
+	switch strategy {
+	case schemas.CategoryDeleteRefuse, schemas.CategoryDeleteDetach:
+		targetId = nil
+	case schemas.CategoryDeleteReassign:
+		if targetId == nil || *targetId == id {
+			return ErrInvalidTarget
+		}
+	default:
+		return ErrUnknownStrategy
+	}
+
+	// The books lose their link to the category, so they are read before it goes away. Books linked
+	// in the meantime are caught up by the periodic rebuild of the search index.
+	bookIds, err := r.repository.GetCategoryBookIds(ctx, id)
 	if err != nil {
 		return errors.Wrap(err, "delete category")
 	}
 
-	zerolog.Ctx(ctx).Info().Str("id", id.String()).Msg("category.deleted")
+	err = r.repository.DeleteCategory(ctx, id, targetId, time.Now().UTC(), func(categories []schemas.Category, books int64) error {
+		tree := schemas.NewCategoryTree(categories)
+		if !tree.Contains(id) {
+			return gorm.ErrRecordNotFound
+		}
+		if targetId != nil && !tree.Contains(*targetId) {
+			return ErrInvalidTarget
+		}
+		if strategy == schemas.CategoryDeleteRefuse && (books > 0 || len(tree.Subtree(id)) > 1) {
+			return ErrCategoryNotEmpty
+		}
+		return nil
+	})
+	if err != nil {
+		return errors.Wrap(err, "delete category")
+	}
+	r.searchService.IndexCategory(ctx, id)
+	if targetId != nil {
+		r.searchService.IndexCategory(ctx, *targetId)
+	}
+	r.searchService.IndexBooks(ctx, bookIds)
+
+	zerolog.Ctx(ctx).Info().Str("id", id.String()).Str("strategy", strategy).Msg("category.deleted")
 	return nil
 }
+
+// MergeCategory moves the books and subcategories of category id into targetId and deletes it.
+func (r *Service) MergeCategory(ctx context.Context, id, targetId uuid.UUID) error {
+	if id == targetId {
+		return ErrInvalidTarget
+	}
+
+	// See DeleteCategory.
+	bookIds, err := r.repository.GetCategoryBookIds(ctx, id)
+	if err != nil {
+		return errors.Wrap(err, "merge category")
+	}
+
+	err = r.repository.MergeCategory(ctx, id, targetId, time.Now().UTC(), func(categories []schemas.Category) error {
+		tree := schemas.NewCategoryTree(categories)
+		if !tree.Contains(id) {
+			return gorm.ErrRecordNotFound
+		}
+		if !tree.Contains(targetId) {
+			return ErrInvalidTarget
+		}
+		if !tree.CanMove(id, &targetId) {
+			return ErrCategoryCycle
+		}
+		return nil
+	})
+	if err != nil {
+		return errors.Wrap(err, "merge category")
+	}
+	r.searchService.IndexCategory(ctx, id)
+	r.searchService.IndexCategory(ctx, targetId)
+	r.searchService.IndexBooks(ctx, bookIds)
+
+	zerolog.Ctx(ctx).Info().Str("id", id.String()).Str("targetId", targetId.String()).Msg("category.merged")
+	return nil
+}
+
+// countBooks fills BookCount and TotalBookCount.
+func (r *Service) countBooks(ctx context.Context, categories []schemas.Category) error {
+	direct, totals, err := r.repository.CountBooks(ctx)
+	if err != nil {
+		return err
+	}
+
+	for i := range categories {
+		count, total := direct[categories[i].ID], totals[categories[i].ID]
+		categories[i].BookCount = &count
+		categories[i].TotalBookCount = &total
+	}
+
+	return nil
+}
+
+var ErrUnknownParent = errors.New("unknown parent category")
+var ErrCategoryCycle = errors.New("category cannot be moved into its own subtree")
+var ErrUnknownStrategy = errors.New("unknown delete strategy")
+var ErrInvalidTarget = errors.New("invalid target category")
+var ErrCategoryNotEmpty = errors.New("category still has books or subcategories")
@@ -0,0 +1,86 @@
+package inventory_service
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+	"main.go/repositories/book_repository"
+	"main.go/repositories/inventory_repository"
+	"main.go/schemas"
+	"time"
+)
+
+type Service struct {
+	repository     *inventory_repository.Repository
+	bookRepository *book_repository.Repository
+}
+
+func NewService(repository *inventory_repository.Repository, bookRepository *book_repository.Repository) *Service {
+	return &Service{repository: repository, bookRepository: bookRepository}
+}
+
+// Adjust posts an adjustment to the book's stock. Received copies are added, sold and damaged
+// ones taken away and corrections applied as given. The first adjustment starts tracking the
+// stock of a book from zero.
+func (r *Service) Adjust(ctx context.Context, actorId, bookId uuid.UUID, req *schemas.StockAdjustmentRequest) (*schemas.StockAdjustment, error) {
+	quantity := req.Quantity
+	switch req.Reason {
+	case schemas.StockReceived, schemas.StockSold, schemas.StockDamaged:
+		if quantity <= 0 {
+			return nil, ErrInvalidQuantity
+		}
+		if req.Reason != schemas.StockReceived {
+			quantity = -quantity
+		}
+	case schemas.StockCorrection:
+		if quantity == 0 {
+			return nil, ErrInvalidQuantity
+		}
+	default:
+		return nil, ErrUnknownReason
+	}
+
+	adjustment := &schemas.StockAdjustment{
+		ID:        uuid.New(),
+		BookId:    bookId,
+		Reason:    req.Reason,
+		Quantity:  quantity,
+		Note:      req.Note,
+		ActorId:   actorId,
+		CreatedAt: time.Now().UTC(),
+	}
+	applied, err := r.repository.Adjust(ctx, adjustment)
+	if err != nil {
+		return nil, errors.Wrap(err, "adjust stock")
+	}
+	if !applied {
+		return nil, ErrInsufficientStock
+	}
+
+	zerolog.Ctx(ctx).Info().Str("bookId", bookId.String()).
+		Str("reason", adjustment.Reason).
+		Int("quantity", adjustment.Quantity).
+		Int("balance", adjustment.Balance).
+		Msg("stock.adjusted")
+	return adjustment, nil
+}
+
+// ListAdjustments returns the book with its current stock and a page of its ledger, newest first.
+func (r *Service) ListAdjustments(ctx context.Context, bookId uuid.UUID, page, pageSize int) (*schemas.Book, *[]schemas.StockAdjustment, error) {
+	book, err := r.bookRepository.BookInfo(ctx, bookId)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "list stock adjustments")
+	}
+
+	adjustments, err := r.repository.GetAdjustments(ctx, bookId, page, pageSize)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "list stock adjustments")
+	}
+
+	return book, adjustments, nil
+}
+
+var ErrUnknownReason = errors.New("unknown stock adjustment reason")
+var ErrInvalidQuantity = errors.New("invalid stock adjustment quantity")
+var ErrInsufficientStock = errors.New("not enough stock")
@@ -0,0 +1,143 @@
+package invitation_service
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+	"gorm.io/gorm"
+	"main.go/repositories/audit_repository"
+	"main.go/repositories/invitation_repository"
+	"main.go/repositories/role_repository"
+	"main.go/schemas"
+	"main.go/utils/secret_utils"
+	"main.go/utils/settings_utils"
+	"time"
+)
+
+const maxInvitationTtl = 30 * 24 * time.Hour
+
+type Service struct {
+	repository      *invitation_repository.Repository
+	roleRepository  *role_repository.Repository
+	auditRepository *audit_repository.Repository
+}
+
+func NewService(repository *invitation_repository.Repository, roleRepository *role_repository.Repository,
+	auditRepository *audit_repository.Repository) *Service {
+	return &Service{repository: repository, roleRepository: roleRepository, auditRepository: auditRepository}
+}
+
+// IssueInvitation creates a single-use code for the given role. The plain code is
+// returned only here; the database keeps its hash.
+func (r *Service) IssueInvitation(ctx context.Context, actorId uuid.UUID, req *schemas.InvitationRequest) (string, *schemas.Invitation, error) {
+	ttl := settings_utils.Settings.InvitationTtl
+	if req.Ttl != "" {
+		var err error
+		ttl, err = time.ParseDuration(req.Ttl)
+		if err != nil || ttl <= 0 || ttl > maxInvitationTtl {
+			return "", nil, ErrInvalidTtl
+		}
+	}
+
+	role, err := r.roleRepository.GetRoleByName(ctx, req.Role)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", nil, ErrUnknownRole
+		}
+		return "", nil, errors.Wrap(err, "issue invitation")
+	}
+
+	code := secret_utils.NewSecret()
+	now := time.Now().UTC()
+	invitation := &schemas.Invitation{
+		ID:        uuid.New(),
+		CodeHash:  secret_utils.HashSecret(code),
+		RoleId:    role.ID,
+		RoleName:  role.Name,
+		CreatedBy: actorId,
+		ExpiresAt: now.Add(ttl),
+		CreatedAt: now,
+	}
+	err = r.repository.SaveInvitation(ctx, invitation)
+	if err != nil {
+		return "", nil, errors.Wrap(err, "issue invitation")
+	}
+
+	err = r.auditRepository.Record(ctx, actorId, schemas.AuditInvitationIssued, invitation.ID,
+		map[string]interface{}{"role": role.Name, "expiresAt": invitation.ExpiresAt})
+	if err != nil {
+		return "", nil, errors.Wrap(err, "issue invitation")
+	}
+
+	zerolog.Ctx(ctx).Info().Str("invitationId", invitation.ID.String()).
+		Str("role", role.Name).
+		Msg("invitation.issued")
+	return code, invitation, nil
+}
+
+// BootstrapAdmin issues an admin invitation when nobody holds the admin role yet and no
+// admin invitation is pending, so that the first administrator can register without a
+// shared key. It returns an empty code otherwise; the code of a pending invitation
+// cannot be shown again since only its hash is stored.
+func (r *Service) BootstrapAdmin(ctx context.Context) (string, *schemas.Invitation, error) {
+	role, err := r.roleRepository.GetRoleByName(ctx, schemas.RoleAdmin)
+	if err != nil {
+		return "", nil, errors.Wrap(err, "bootstrap admin")
+	}
+
+	admins, err := r.roleRepository.CountUsersWithRole(ctx, role.ID)
+	if err != nil {
+		return "", nil, errors.Wrap(err, "bootstrap admin")
+	}
+	if admins > 0 {
+		return "", nil, nil
+	}
+
+	pending, err := r.repository.CountPending(ctx, role.ID, time.Now().UTC())
+	if err != nil {
+		return "", nil, errors.Wrap(err, "bootstrap admin")
+	}
+	if pending > 0 {
+		return "", nil, nil
+	}
+
+	code, invitation, err := r.IssueInvitation(ctx, uuid.Nil, &schemas.InvitationRequest{Role: role.Name})
+	if err != nil {
+		return "", nil, errors.Wrap(err, "bootstrap admin")
+	}
+
+	return code, invitation, nil
+}
+
+func (r *Service) ListInvitations(ctx context.Context, page int, pageSize int) (*[]schemas.Invitation, error) {
+	invitations, err := r.repository.GetInvitations(ctx, page, pageSize)
+	if err != nil {
+		return nil, errors.Wrap(err, "list invitations")
+	}
+
+	zerolog.Ctx(ctx).Info().Int("amount", len(*invitations)).Msg("invitations.listed")
+	return invitations, nil
+}
+
+func (r *Service) RevokeInvitation(ctx context.Context, actorId, id uuid.UUID) error {
+	revoked, err := r.repository.Revoke(ctx, id, time.Now().UTC())
+	if err != nil {
+		return errors.Wrap(err, "revoke invitation")
+	}
+	if !revoked {
+		return ErrNotPending
+	}
+
+	err = r.auditRepository.Record(ctx, actorId, schemas.AuditInvitationRevoked, id, nil)
+	if err != nil {
+		return errors.Wrap(err, "revoke invitation")
+	}
+
+	zerolog.Ctx(ctx).Info().Str("invitationId", id.String()).Msg("invitation.revoked")
+	return nil
+}
+
+var ErrInvalidTtl = errors.New("invalid invitation ttl")
+var ErrUnknownRole = errors.New("unknown role")
+var ErrNotPending = errors.New("invitation is not pending")
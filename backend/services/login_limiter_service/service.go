@@ -0,0 +1,171 @@
+package login_limiter_service
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+	"strings"
+	"time"
+)
+
+// Policy describes how failures on one key are throttled. The first FreeAttempts failures
+// cost nothing, every further one doubles the wait starting at BaseDelay up to MaxDelay,
+// and reaching LockoutThreshold locks the key for LockoutDuration.
+type Policy struct {
+	FreeAttempts     int
+	BaseDelay        time.Duration
+	MaxDelay         time.Duration
+	LockoutThreshold int
+	LockoutDuration  time.Duration
+	// Window is how long a failure is remembered.
+	Window time.Duration
+}
+
+func (r Policy) blockFor(failures int) (time.Duration, bool) {
+	if failures >= r.LockoutThreshold {
+		return r.LockoutDuration, true
+	}
+	if failures <= r.FreeAttempts {
+		return 0, false
+	}
+
+	delay := r.BaseDelay << (failures - r.FreeAttempts - 1)
+	if delay <= 0 || delay > r.MaxDelay {
+		delay = r.MaxDelay
+	}
+
+	return delay, false
+}
+
+type Service struct {
+	store      Store
+	userPolicy Policy
+	ipPolicy   Policy
+}
+
+func NewService(store Store, userPolicy, ipPolicy Policy) *Service {
+	return &Service{store: store, userPolicy: userPolicy, ipPolicy: ipPolicy}
+}
+
+// DefaultPolicies throttles usernames after a few failures and addresses, which may be
+// shared behind NAT, only after ten times as many.
+func DefaultPolicies(lockoutThreshold int, lockoutDuration time.Duration) (Policy, Policy) {
+	userPolicy := Policy{
+		FreeAttempts:     3,
+		BaseDelay:        time.Second,
+		MaxDelay:         time.Minute,
+		LockoutThreshold: lockoutThreshold,
+		LockoutDuration:  lockoutDuration,
+		Window:           lockoutDuration,
+	}
+	ipPolicy := Policy{
+		FreeAttempts:     lockoutThreshold,
+		BaseDelay:        time.Second,
+		MaxDelay:         time.Minute,
+		LockoutThreshold: lockoutThreshold * 10,
+		LockoutDuration:  lockoutDuration,
+		Window:           lockoutDuration,
+	}
+
+	return userPolicy, ipPolicy
+}
+
+// Check returns how long the caller has to wait before trying to log in again, zero if not blocked.
+func (r *Service) Check(ctx context.Context, username, ip string) (time.Duration, error) {
+	now := time.Now().UTC()
+	var retryAfter time.Duration
+	for _, key := range []string{userKey(username), ipKey(ip)} {
+		attempt, err := r.store.Get(ctx, key)
+		if err != nil {
+			return 0, errors.Wrap(err, "check login limiter")
+		}
+
+		if wait := attempt.BlockedUntil.Sub(now); wait > retryAfter {
+			retryAfter = wait
+		}
+	}
+
+	return retryAfter, nil
+}
+
+func (r *Service) Failure(ctx context.Context, username, ip string) error {
+	err := r.failure(ctx, userKey(username), r.userPolicy)
+	if err != nil {
+		return err
+	}
+
+	return r.failure(ctx, ipKey(ip), r.ipPolicy)
+}
+
+// Success forgets failures for the username. The address counter is kept, otherwise an
+// attacker owning one account could reset it between guesses at others.
+func (r *Service) Success(ctx context.Context, username string) error {
+	err := r.store.Reset(ctx, userKey(username))
+	if err != nil {
+		return errors.Wrap(err, "reset login limiter")
+	}
+
+	return nil
+}
+
+// Prune periodically drops stale counters. It blocks until ctx is cancelled.
+func (r *Service) Prune(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			window := max(r.userPolicy.Window, r.ipPolicy.Window)
+			deleted, err := r.store.Prune(ctx, time.Now().UTC().Add(-window))
+			if err != nil {
+				zerolog.Ctx(ctx).Error().Err(err).Msg("login.attempts.prune.failed")
+				continue
+			}
+
+			zerolog.Ctx(ctx).Info().Int64("amount", deleted).Msg("login.attempts.pruned")
+		}
+	}
+}
+
+func (r *Service) failure(ctx context.Context, key string, policy Policy) error {
+	now := time.Now().UTC()
+	attempt, err := r.store.Increment(ctx, key, now, policy.Window)
+	if err != nil {
+		return errors.Wrap(err, "register login failure")
+	}
+
+	delay, lockout := policy.blockFor(attempt.Failures)
+	if delay == 0 {
+		return nil
+	}
+
+	err = r.store.Block(ctx, key, now.Add(delay))
+	if err != nil {
+		return errors.Wrap(err, "register login failure")
+	}
+
+	if lockout {
+		zerolog.Ctx(ctx).Warn().Str("key", key).
+			Int("failures", attempt.Failures).
+			Dur("duration", delay).
+			Msg("login.lockout")
+	} else {
+		zerolog.Ctx(ctx).Info().Str("key", key).
+			Int("failures", attempt.Failures).
+			Dur("delay", delay).
+			Msg("login.backoff")
+	}
+
+	return nil
+}
+
+func userKey(username string) string {
+	return "user:" + strings.ToLower(strings.TrimSpace(username))
+}
+
+func ipKey(ip string) string {
+	return "ip:" + ip
+}
@@ -0,0 +1,93 @@
+package login_limiter_service
+
+import (
+	"context"
+	"main.go/schemas"
+	"sync"
+	"time"
+)
+
+// Store keeps failure counters. Implementations must make Increment atomic per key.
+type Store interface {
+	// Get returns an empty attempt if the key has no failures.
+	Get(ctx context.Context, key string) (*schemas.LoginAttempt, error)
+	// Increment adds a failure, starting over if the previous one is older than window.
+	Increment(ctx context.Context, key string, now time.Time, window time.Duration) (*schemas.LoginAttempt, error)
+	Block(ctx context.Context, key string, until time.Time) error
+	Reset(ctx context.Context, key string) error
+	// Prune drops keys whose last failure happened before the given moment.
+	Prune(ctx context.Context, before time.Time) (int64, error)
+}
+
+// MemoryStore keeps counters in process. Counters are lost on restart and not shared between instances.
+type MemoryStore struct {
+	mu       sync.Mutex
+	attempts map[string]schemas.LoginAttempt
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{attempts: make(map[string]schemas.LoginAttempt)}
+}
+
+func (r *MemoryStore) Get(_ context.Context, key string) (*schemas.LoginAttempt, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	attempt, ok := r.attempts[key]
+	if !ok {
+		return &schemas.LoginAttempt{Key: key}, nil
+	}
+
+	return &attempt, nil
+}
+
+func (r *MemoryStore) Increment(_ context.Context, key string, now time.Time, window time.Duration) (*schemas.LoginAttempt, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	attempt := r.attempts[key]
+	attempt.Key = key
+	if now.Sub(attempt.LastFailureAt) > window {
+		attempt.Failures = 0
+	}
+	attempt.Failures++
+	attempt.LastFailureAt = now
+	r.attempts[key] = attempt
+
+	return &attempt, nil
+}
+
+func (r *MemoryStore) Block(_ context.Context, key string, until time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	attempt := r.attempts[key]
+	attempt.Key = key
+	attempt.BlockedUntil = until
+	r.attempts[key] = attempt
+
+	return nil
+}
+
+func (r *MemoryStore) Reset(_ context.Context, key string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.attempts, key)
+	return nil
+}
+
+func (r *MemoryStore) Prune(_ context.Context, before time.Time) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var deleted int64
+	for key, attempt := range r.attempts {
+		if attempt.LastFailureAt.Before(before) && attempt.BlockedUntil.Before(before) {
+			delete(r.attempts, key)
+			deleted++
+		}
+	}
+
+	return deleted, nil
+}
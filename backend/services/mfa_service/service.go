@@ -0,0 +1,319 @@
+package mfa_service
+
+import (
+	"context"
+	"crypto/rand"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+	"gorm.io/gorm"
+	"main.go/repositories/audit_repository"
+	"main.go/repositories/mfa_repository"
+	"main.go/repositories/role_repository"
+	"main.go/repositories/user_repository"
+	"main.go/schemas"
+	"main.go/utils/password_utils"
+	"main.go/utils/secret_utils"
+	"main.go/utils/settings_utils"
+	"main.go/utils/totp_utils"
+	"strings"
+	"time"
+)
+
+const (
+	recoveryCodeCount = 10
+	// totpSkew accepts codes one step before and after the current one to tolerate clock drift.
+	totpSkew = 1
+)
+
+type Service struct {
+	userRepository  *user_repository.Repository
+	mfaRepository   *mfa_repository.Repository
+	roleRepository  *role_repository.Repository
+	auditRepository *audit_repository.Repository
+	hasher          password_utils.Hasher
+}
+
+func NewService(userRepository *user_repository.Repository, mfaRepository *mfa_repository.Repository,
+	roleRepository *role_repository.Repository, auditRepository *audit_repository.Repository,
+	hasher password_utils.Hasher) *Service {
+	return &Service{userRepository: userRepository, mfaRepository: mfaRepository,
+		roleRepository: roleRepository, auditRepository: auditRepository, hasher: hasher}
+}
+
+// BeginEnrollment generates a secret for the user. It is not used for logins until ConfirmEnrollment.
+func (r *Service) BeginEnrollment(ctx context.Context, userId uuid.UUID) (*schemas.TotpEnrollment, error) {
+	user, err := r.getLiveUser(ctx, userId)
+	if err != nil {
+		return nil, errors.Wrap(err, "begin totp enrollment")
+	}
+	if user.MfaEnabled() {
+		return nil, ErrMfaAlreadyEnabled
+	}
+
+	secret := totp_utils.NewSecret()
+	err = r.userRepository.SetTotpSecret(ctx, user.ID, secret)
+	if err != nil {
+		return nil, errors.Wrap(err, "begin totp enrollment")
+	}
+
+	zerolog.Ctx(ctx).Info().Str("userId", user.ID.String()).Msg("totp.enrollment.started")
+	return &schemas.TotpEnrollment{
+		Secret: secret,
+		Uri:    totp_utils.ProvisioningUri(settings_utils.Settings.MfaIssuer, user.Username, secret),
+	}, nil
+}
+
+// ConfirmEnrollment enables 2FA once the user proves their app produces valid codes,
+// and returns the recovery codes, which are shown only this once.
+func (r *Service) ConfirmEnrollment(ctx context.Context, userId uuid.UUID, code string) (*schemas.User, []string, error) {
+	user, err := r.getLiveUser(ctx, userId)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "confirm totp enrollment")
+	}
+	if user.MfaEnabled() {
+		return nil, nil, ErrMfaAlreadyEnabled
+	}
+	if user.TotpSecret == "" {
+		return nil, nil, ErrMfaNotStarted
+	}
+
+	now := time.Now().UTC()
+	counter, ok, err := totp_utils.Validate(user.TotpSecret, code, now, totpSkew)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "confirm totp enrollment")
+	}
+	if !ok {
+		return nil, nil, ErrInvalidMfaCode
+	}
+
+	codes, err := r.replaceRecoveryCodes(ctx, user.ID, now)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "confirm totp enrollment")
+	}
+
+	err = r.userRepository.EnableTotp(ctx, user.ID, counter, now)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "confirm totp enrollment")
+	}
+	user.TotpEnabledAt = now
+
+	err = r.auditRepository.Record(ctx, user.ID, schemas.AuditMfaEnabled, user.ID, nil)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "confirm totp enrollment")
+	}
+
+	zerolog.Ctx(ctx).Info().Str("userId", user.ID.String()).Msg("totp.enabled")
+	return user, codes, nil
+}
+
+// Disable turns 2FA off after checking both factors. Users it is forced on cannot turn it off.
+func (r *Service) Disable(ctx context.Context, userId uuid.UUID, req *schemas.MfaDisableRequest) error {
+	user, err := r.getLiveUser(ctx, userId)
+	if err != nil {
+		return errors.Wrap(err, "disable totp")
+	}
+	if !user.MfaEnabled() {
+		return ErrMfaNotEnabled
+	}
+
+	ok, err := r.hasher.Verify(req.Password, user.EncodedPassword())
+	if err != nil {
+		return errors.Wrap(err, "disable totp")
+	}
+	if !ok {
+		return ErrWrongPassword
+	}
+
+	err = r.Verify(ctx, user, req.Code)
+	if err != nil {
+		return err
+	}
+
+	if settings_utils.Settings.ForceAdminMfa {
+		roles, err := r.roleRepository.GetUserRoles(ctx, user.ID)
+		if err != nil {
+			return errors.Wrap(err, "disable totp")
+		}
+		user.SetRoles(*roles)
+		if user.HasAdminRights() {
+			return ErrMfaRequired
+		}
+	}
+
+	err = r.disable(ctx, user.ID)
+	if err != nil {
+		return errors.Wrap(err, "disable totp")
+	}
+
+	err = r.auditRepository.Record(ctx, user.ID, schemas.AuditMfaDisabled, user.ID, nil)
+	if err != nil {
+		return errors.Wrap(err, "disable totp")
+	}
+
+	zerolog.Ctx(ctx).Info().Str("userId", user.ID.String()).Msg("totp.disabled")
+	return nil
+}
+
+// Reset lets an administrator turn 2FA off for a user who lost both the app and the recovery codes.
+func (r *Service) Reset(ctx context.Context, actorId, userId uuid.UUID) error {
+	if actorId == userId {
+		return ErrSelfModification
+	}
+
+	user, err := r.getLiveUser(ctx, userId)
+	if err != nil {
+		return errors.Wrap(err, "reset totp")
+	}
+	if !user.MfaEnabled() && user.TotpSecret == "" {
+		return nil
+	}
+
+	err = r.disable(ctx, user.ID)
+	if err != nil {
+		return errors.Wrap(err, "reset totp")
+	}
+
+	err = r.auditRepository.Record(ctx, actorId, schemas.AuditMfaReset, user.ID, nil)
+	if err != nil {
+		return errors.Wrap(err, "reset totp")
+	}
+
+	zerolog.Ctx(ctx).Info().Str("userId", user.ID.String()).
+		Str("actorId", actorId.String()).
+		Msg("totp.reset")
+	return nil
+}
+
+// RegenerateRecoveryCodes invalidates the remaining recovery codes and issues new ones.
+func (r *Service) RegenerateRecoveryCodes(ctx context.Context, userId uuid.UUID, code string) ([]string, error) {
+	user, err := r.getLiveUser(ctx, userId)
+	if err != nil {
+		return nil, errors.Wrap(err, "regenerate recovery codes")
+	}
+	if !user.MfaEnabled() {
+		return nil, ErrMfaNotEnabled
+	}
+
+	err = r.Verify(ctx, user, code)
+	if err != nil {
+		return nil, err
+	}
+
+	codes, err := r.replaceRecoveryCodes(ctx, user.ID, time.Now().UTC())
+	if err != nil {
+		return nil, errors.Wrap(err, "regenerate recovery codes")
+	}
+
+	err = r.auditRepository.Record(ctx, user.ID, schemas.AuditMfaRecoveryCodes, user.ID, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "regenerate recovery codes")
+	}
+
+	return codes, nil
+}
+
+// Verify accepts a current TOTP code or an unused recovery code. Each one works only once.
+func (r *Service) Verify(ctx context.Context, user *schemas.User, code string) error {
+	if !user.MfaEnabled() {
+		return ErrMfaNotEnabled
+	}
+
+	now := time.Now().UTC()
+	counter, ok, err := totp_utils.Validate(user.TotpSecret, code, now, totpSkew)
+	if err != nil {
+		return errors.Wrap(err, "verify mfa code")
+	}
+	if ok {
+		fresh, err := r.userRepository.UseTotpCounter(ctx, user.ID, counter)
+		if err != nil {
+			return errors.Wrap(err, "verify mfa code")
+		}
+		if !fresh {
+			return ErrInvalidMfaCode
+		}
+
+		return nil
+	}
+
+	used, err := r.mfaRepository.UseRecoveryCode(ctx, user.ID, secret_utils.HashSecret(normalizeRecoveryCode(code)), now)
+	if err != nil {
+		return errors.Wrap(err, "verify mfa code")
+	}
+	if !used {
+		return ErrInvalidMfaCode
+	}
+
+	remaining, err := r.mfaRepository.CountUnusedRecoveryCodes(ctx, user.ID)
+	if err != nil {
+		return errors.Wrap(err, "verify mfa code")
+	}
+
+	zerolog.Ctx(ctx).Warn().Str("userId", user.ID.String()).
+		Int64("remaining", remaining).
+		Msg("recovery.code.used")
+	return nil
+}
+
+func (r *Service) disable(ctx context.Context, userId uuid.UUID) error {
+	err := r.userRepository.DisableTotp(ctx, userId)
+	if err != nil {
+		return err
+	}
+
+	return r.mfaRepository.DeleteRecoveryCodes(ctx, userId)
+}
+
+func (r *Service) replaceRecoveryCodes(ctx context.Context, userId uuid.UUID, now time.Time) ([]string, error) {
+	codes := make([]string, 0, recoveryCodeCount)
+	stored := make([]schemas.RecoveryCode, 0, recoveryCodeCount)
+	for range recoveryCodeCount {
+		code := newRecoveryCode()
+		codes = append(codes, code)
+		stored = append(stored, schemas.RecoveryCode{
+			ID:        uuid.New(),
+			UserId:    userId,
+			CodeHash:  secret_utils.HashSecret(normalizeRecoveryCode(code)),
+			CreatedAt: now,
+		})
+	}
+
+	err := r.mfaRepository.ReplaceRecoveryCodes(ctx, userId, stored)
+	if err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+func (r *Service) getLiveUser(ctx context.Context, userId uuid.UUID) (*schemas.User, error) {
+	user, err := r.userRepository.GetUserById(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+	if !user.DeletedAt.IsZero() {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	return user, nil
+}
+
+// newRecoveryCode returns ten lowercase base32 characters split in two for readability.
+func newRecoveryCode() string {
+	code := strings.ToLower(rand.Text()[:10])
+	return code[:5] + "-" + code[5:]
+}
+
+// normalizeRecoveryCode lets users type codes with any case and without the dash.
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	return strings.ReplaceAll(code, "-", "")
+}
+
+var ErrMfaAlreadyEnabled = errors.New("two-factor authentication already enabled")
+var ErrMfaNotEnabled = errors.New("two-factor authentication not enabled")
+var ErrMfaNotStarted = errors.New("two-factor enrollment not started")
+var ErrMfaRequired = errors.New("two-factor authentication is required for accounts with admin rights")
+var ErrInvalidMfaCode = errors.New("invalid two-factor code")
+var ErrWrongPassword = errors.New("wrong password")
+var ErrSelfModification = errors.New("own two-factor settings can only be changed with a code")
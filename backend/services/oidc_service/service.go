@@ -0,0 +1,523 @@
+package oidc_service
+
+import (
+	"context"
+	"crypto/subtle"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+	"gorm.io/gorm"
+	"main.go/repositories/audit_repository"
+	"main.go/repositories/identity_repository"
+	"main.go/repositories/role_repository"
+	"main.go/repositories/user_repository"
+	"main.go/schemas"
+	"main.go/utils/oidc_utils"
+	"main.go/utils/secret_utils"
+	"main.go/utils/settings_utils"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+// StateTtl bounds how long an authorization request, and the cookie binding it to the browser, lives.
+const StateTtl = 10 * time.Minute
+
+// loginCodeTtl bounds how long the frontend has to exchange a login code for tokens.
+const loginCodeTtl = time.Minute
+
+var usernameCleaner = regexp.MustCompile(`[^a-zA-Z0-9._-]+`)
+
+type Service struct {
+	provider        *oidc_utils.Provider
+	repository      *identity_repository.Repository
+	userRepository  *user_repository.Repository
+	roleRepository  *role_repository.Repository
+	auditRepository *audit_repository.Repository
+}
+
+// NewService returns a service with OIDC disabled if provider is nil.
+func NewService(provider *oidc_utils.Provider, repository *identity_repository.Repository,
+	userRepository *user_repository.Repository, roleRepository *role_repository.Repository,
+	auditRepository *audit_repository.Repository) *Service {
+	return &Service{provider: provider, repository: repository, userRepository: userRepository,
+		roleRepository: roleRepository, auditRepository: auditRepository}
+}
+
+func (r *Service) Enabled() bool {
+	return r.provider != nil
+}
+
+// AuthorizationUrl starts a login, or a link to linkUserId's account when it is not uuid.Nil.
+// The returned binding must be kept by the browser that starts the flow and handed to Callback,
+// so that an authorization response cannot be replayed in another browser.
+func (r *Service) AuthorizationUrl(ctx context.Context, linkUserId uuid.UUID) (url string, binding string, err error) {
+	if !r.Enabled() {
+		return "", "", ErrOidcDisabled
+	}
+
+	state := secret_utils.NewSecret()
+	nonce := secret_utils.NewSecret()
+	verifier := oidc_utils.NewVerifier()
+	now := time.Now().UTC()
+
+	err = r.repository.SaveState(ctx, &schemas.OidcState{
+		StateHash:    secret_utils.HashSecret(state),
+		Nonce:        nonce,
+		CodeVerifier: verifier,
+		LinkUserId:   linkUserId,
+		ExpiresAt:    now.Add(StateTtl),
+		CreatedAt:    now,
+	})
+	if err != nil {
+		return "", "", errors.Wrap(err, "start oidc login")
+	}
+
+	url, err = r.provider.AuthCodeUrl(ctx, state, nonce, verifier)
+	if err != nil {
+		return "", "", errors.Wrap(err, "start oidc login")
+	}
+
+	return url, secret_utils.HashSecret(state), nil
+}
+
+// Callback finishes the authorization code flow. binding is what AuthorizationUrl returned to the
+// browser that started it. It returns the user to issue tokens for, and linked is true when the
+// identity was attached to a signed-in user instead.
+func (r *Service) Callback(ctx context.Context, state, binding, code string) (user *schemas.User, linked bool, err error) {
+	if !r.Enabled() {
+		return nil, false, ErrOidcDisabled
+	}
+
+	stateHash := secret_utils.HashSecret(state)
+	if subtle.ConstantTimeCompare([]byte(stateHash), []byte(binding)) != 1 {
+		return nil, false, ErrInvalidState
+	}
+
+	stored, err := r.repository.TakeState(ctx, stateHash)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, false, ErrInvalidState
+		}
+		return nil, false, errors.Wrap(err, "oidc callback")
+	}
+	now := time.Now().UTC()
+	if now.After(stored.ExpiresAt) {
+		return nil, false, ErrInvalidState
+	}
+
+	claims, err := r.provider.Exchange(ctx, code, stored.CodeVerifier, stored.Nonce)
+	if err != nil {
+		if errors.Is(err, oidc_utils.ErrInvalidIdToken) {
+			zerolog.Ctx(ctx).Warn().Err(err).Msg("oidc.id.token.rejected")
+			return nil, false, ErrInvalidIdToken
+		}
+		return nil, false, errors.Wrap(err, "oidc callback")
+	}
+
+	if stored.LinkUserId != uuid.Nil {
+		user, err = r.link(ctx, stored.LinkUserId, claims, now)
+		if err != nil {
+			return nil, false, err
+		}
+
+		return user, true, nil
+	}
+
+	user, err = r.resolve(ctx, claims, now)
+	if err != nil {
+		return nil, false, err
+	}
+	if !user.IsActive() {
+		return nil, false, ErrUserDisabled
+	}
+
+	err = r.syncRoles(ctx, user, claims.Groups)
+	if err != nil {
+		return nil, false, errors.Wrap(err, "oidc callback")
+	}
+
+	err = r.userRepository.UpdateLastLoginAt(ctx, user.ID)
+	if err != nil {
+		return nil, false, errors.Wrap(err, "oidc callback")
+	}
+
+	zerolog.Ctx(ctx).Info().Str("userId", user.ID.String()).
+		Str("subject", claims.Subject).
+		Msg("oidc.login.successful")
+	return user, false, nil
+}
+
+func (r *Service) ListIdentities(ctx context.Context, userId uuid.UUID) (*[]schemas.UserIdentity, error) {
+	identities, err := r.repository.GetUserIdentities(ctx, userId)
+	if err != nil {
+		return nil, errors.Wrap(err, "list identities")
+	}
+
+	return identities, nil
+}
+
+// Unlink removes an identity unless it is the only way the user can sign in.
+func (r *Service) Unlink(ctx context.Context, userId, id uuid.UUID) error {
+	user, err := r.userRepository.GetUserById(ctx, userId)
+	if err != nil {
+		return errors.Wrap(err, "unlink identity")
+	}
+
+	identities, err := r.repository.GetUserIdentities(ctx, userId)
+	if err != nil {
+		return errors.Wrap(err, "unlink identity")
+	}
+	if !user.HasPassword() && len(*identities) <= 1 {
+		return ErrLastCredential
+	}
+
+	err = r.repository.DeleteIdentity(ctx, id, userId)
+	if err != nil {
+		return errors.Wrap(err, "unlink identity")
+	}
+
+	err = r.auditRepository.Record(ctx, userId, schemas.AuditIdentityUnlinked, id, nil)
+	if err != nil {
+		return errors.Wrap(err, "unlink identity")
+	}
+
+	return nil
+}
+
+// IssueLoginCode returns a short-lived code the frontend exchanges once for the user's tokens,
+// so that the tokens never appear in a redirect URL.
+func (r *Service) IssueLoginCode(ctx context.Context, userId uuid.UUID) (string, error) {
+	code := secret_utils.NewSecret()
+	now := time.Now().UTC()
+	err := r.repository.SaveLoginCode(ctx, &schemas.OidcLoginCode{
+		CodeHash:  secret_utils.HashSecret(code),
+		UserId:    userId,
+		ExpiresAt: now.Add(loginCodeTtl),
+		CreatedAt: now,
+	})
+	if err != nil {
+		return "", errors.Wrap(err, "issue oidc login code")
+	}
+
+	return code, nil
+}
+
+// RedeemLoginCode consumes a code from IssueLoginCode and returns the user to issue tokens for.
+func (r *Service) RedeemLoginCode(ctx context.Context, code string) (*schemas.User, error) {
+	stored, err := r.repository.TakeLoginCode(ctx, secret_utils.HashSecret(code))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvalidLoginCode
+		}
+		return nil, errors.Wrap(err, "redeem oidc login code")
+	}
+	if time.Now().UTC().After(stored.ExpiresAt) {
+		return nil, ErrInvalidLoginCode
+	}
+
+	user, err := r.userRepository.GetUserById(ctx, stored.UserId)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvalidLoginCode
+		}
+		return nil, errors.Wrap(err, "redeem oidc login code")
+	}
+	if !user.IsActive() {
+		return nil, ErrUserDisabled
+	}
+
+	return user, nil
+}
+
+// PruneStates periodically drops abandoned authorization requests and login codes. It blocks until ctx is cancelled.
+func (r *Service) PruneStates(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			now := time.Now().UTC()
+			deleted, err := r.repository.DeleteExpiredStates(ctx, now)
+			if err != nil {
+				zerolog.Ctx(ctx).Error().Err(err).Msg("oidc.states.prune.failed")
+				continue
+			}
+
+			zerolog.Ctx(ctx).Info().Int64("amount", deleted).Msg("oidc.states.pruned")
+
+			deleted, err = r.repository.DeleteExpiredLoginCodes(ctx, now)
+			if err != nil {
+				zerolog.Ctx(ctx).Error().Err(err).Msg("oidc.login.codes.prune.failed")
+				continue
+			}
+
+			zerolog.Ctx(ctx).Info().Int64("amount", deleted).Msg("oidc.login.codes.pruned")
+		}
+	}
+}
+
+func (r *Service) link(ctx context.Context, userId uuid.UUID, claims *oidc_utils.Claims, now time.Time) (*schemas.User, error) {
+	user, err := r.userRepository.GetUserById(ctx, userId)
+	if err != nil {
+		return nil, errors.Wrap(err, "link identity")
+	}
+	if !user.IsActive() {
+		return nil, ErrUserDisabled
+	}
+
+	existing, err := r.repository.GetIdentity(ctx, r.provider.Issuer(), claims.Subject)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, errors.Wrap(err, "link identity")
+	}
+	if err == nil {
+		if existing.UserId != userId {
+			return nil, ErrIdentityTaken
+		}
+		return user, nil
+	}
+
+	err = r.saveIdentity(ctx, userId, claims, now)
+	if err != nil {
+		return nil, errors.Wrap(err, "link identity")
+	}
+
+	return user, nil
+}
+
+// resolve finds the user behind an identity, linking by verified email or signing up when allowed.
+func (r *Service) resolve(ctx context.Context, claims *oidc_utils.Claims, now time.Time) (*schemas.User, error) {
+	identity, err := r.repository.GetIdentity(ctx, r.provider.Issuer(), claims.Subject)
+	if err == nil {
+		err = r.repository.TouchIdentity(ctx, identity.ID, claims.Email, now)
+		if err != nil {
+			return nil, errors.Wrap(err, "resolve identity")
+		}
+
+		user, err := r.userRepository.GetUserById(ctx, identity.UserId)
+		if err != nil {
+			return nil, errors.Wrap(err, "resolve identity")
+		}
+
+		return user, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, errors.Wrap(err, "resolve identity")
+	}
+
+	email := strings.ToLower(strings.TrimSpace(claims.Email))
+	if settings_utils.Settings.OidcLinkByEmail && claims.EmailVerified && email != "" {
+		user, err := r.userRepository.GetUserByEmail(ctx, email)
+		if err != nil {
+			return nil, errors.Wrap(err, "resolve identity")
+		}
+		if user.ID != uuid.Nil {
+			err = r.saveIdentity(ctx, user.ID, claims, now)
+			if err != nil {
+				return nil, errors.Wrap(err, "resolve identity")
+			}
+
+			return user, nil
+		}
+	}
+
+	if !settings_utils.Settings.OidcAllowSignup {
+		return nil, ErrUnknownIdentity
+	}
+
+	user, err := r.signUp(ctx, claims, email, now)
+	if err != nil {
+		return nil, errors.Wrap(err, "resolve identity")
+	}
+
+	return user, nil
+}
+
+// signUp creates a passwordless user. The email is kept only if the IdP verified it
+// and nobody uses it yet, since it allows resetting a password.
+func (r *Service) signUp(ctx context.Context, claims *oidc_utils.Claims, email string, now time.Time) (*schemas.User, error) {
+	if !claims.EmailVerified {
+		email = ""
+	}
+	if email != "" {
+		owner, err := r.userRepository.GetUserByEmail(ctx, email)
+		if err != nil {
+			return nil, err
+		}
+		if owner.ID != uuid.Nil {
+			email = ""
+		}
+	}
+
+	username, err := r.freeUsername(ctx, claims)
+	if err != nil {
+		return nil, err
+	}
+
+	user := &schemas.User{
+		ID:          uuid.New(),
+		Username:    username,
+		Email:       email,
+		Status:      schemas.UserStatusActive,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+		LastLoginAt: now,
+	}
+	err = r.userRepository.CreateUser(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+
+	err = r.saveIdentity(ctx, user.ID, claims, now)
+	if err != nil {
+		return nil, err
+	}
+
+	zerolog.Ctx(ctx).Info().Str("username", username).Msg("new.user.oidc.registration.successful")
+	return user, nil
+}
+
+// freeUsername derives a username from the claims, adding a random suffix if it is taken.
+func (r *Service) freeUsername(ctx context.Context, claims *oidc_utils.Claims) (string, error) {
+	base := claims.PreferredUsername
+	if base == "" {
+		base, _, _ = strings.Cut(claims.Email, "@")
+	}
+	base = usernameCleaner.ReplaceAllString(base, "")
+	if len(base) < 3 {
+		base = "user"
+	}
+	if len(base) > 48 {
+		base = base[:48]
+	}
+
+	username := base
+	for range 5 {
+		found, err := r.userRepository.GetUserByUsername(ctx, username)
+		if err != nil {
+			return "", err
+		}
+		if found.ID == uuid.Nil {
+			return username, nil
+		}
+
+		username = base + "-" + strings.ToLower(secret_utils.NewSecret()[:6])
+	}
+
+	return "", errors.New("no free username")
+}
+
+func (r *Service) saveIdentity(ctx context.Context, userId uuid.UUID, claims *oidc_utils.Claims, now time.Time) error {
+	identity := &schemas.UserIdentity{
+		ID:          uuid.New(),
+		UserId:      userId,
+		Issuer:      r.provider.Issuer(),
+		Subject:     claims.Subject,
+		Email:       claims.Email,
+		CreatedAt:   now,
+		LastLoginAt: now,
+	}
+	err := r.repository.SaveIdentity(ctx, identity)
+	if err != nil {
+		return err
+	}
+
+	err = r.auditRepository.Record(ctx, userId, schemas.AuditIdentityLinked, identity.ID,
+		map[string]interface{}{"issuer": identity.Issuer, "subject": identity.Subject})
+	if err != nil {
+		return err
+	}
+
+	zerolog.Ctx(ctx).Info().Str("userId", userId.String()).
+		Str("subject", claims.Subject).
+		Msg("oidc.identity.linked")
+	return nil
+}
+
+// syncRoles grants the roles mapped from the user's groups and removes mapped roles
+// the groups no longer grant. Roles that no group maps to are managed locally only.
+func (r *Service) syncRoles(ctx context.Context, user *schemas.User, groups []string) error {
+	mapping := settings_utils.Settings.OidcGroupRoles
+	if len(mapping) == 0 {
+		return nil
+	}
+
+	managed := make([]string, 0)
+	wanted := make([]string, 0)
+	for group, roles := range mapping {
+		for _, role := range roles {
+			if !slices.Contains(managed, role) {
+				managed = append(managed, role)
+			}
+			if slices.Contains(groups, group) && !slices.Contains(wanted, role) {
+				wanted = append(wanted, role)
+			}
+		}
+	}
+
+	current, err := r.roleRepository.GetUserRoles(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+	has := make([]string, 0, len(*current))
+	for _, role := range *current {
+		has = append(has, role.Name)
+	}
+
+	added := make([]string, 0)
+	removed := make([]string, 0)
+	for _, name := range managed {
+		want, holds := slices.Contains(wanted, name), slices.Contains(has, name)
+		if want == holds {
+			continue
+		}
+
+		role, err := r.roleRepository.GetRoleByName(ctx, name)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				zerolog.Ctx(ctx).Warn().Str("role", name).Msg("oidc.group.role.unknown")
+				continue
+			}
+			return err
+		}
+
+		if want {
+			err = r.roleRepository.AssignRole(ctx, user.ID, role.ID)
+			added = append(added, name)
+		} else {
+			err = r.roleRepository.RemoveRole(ctx, user.ID, role.ID)
+			removed = append(removed, name)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(added) == 0 && len(removed) == 0 {
+		return nil
+	}
+
+	if len(removed) != 0 {
+		// The caller issues tokens to user next; they are stamped after the cutoff.
+		user.TokensRevokedAt, err = r.userRepository.RevokeTokensBefore(ctx, user.ID, time.Now().UTC())
+		if err != nil {
+			return err
+		}
+	}
+
+	return r.auditRepository.Record(ctx, user.ID, schemas.AuditUserRolesChanged, user.ID,
+		map[string]interface{}{"source": "oidc", "added": added, "removed": removed})
+}
+
+var ErrOidcDisabled = errors.New("oidc login is not configured")
+var ErrInvalidState = errors.New("invalid or expired oidc state")
+var ErrInvalidIdToken = errors.New("identity provider returned an invalid id token")
+var ErrUnknownIdentity = errors.New("no account is linked to this identity")
+var ErrIdentityTaken = errors.New("identity is linked to another account")
+var ErrLastCredential = errors.New("cannot unlink the only way to sign in")
+var ErrUserDisabled = errors.New("user is disabled")
+var ErrInvalidLoginCode = errors.New("invalid or expired oidc login code")
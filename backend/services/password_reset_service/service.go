@@ -0,0 +1,161 @@
+package password_reset_service
+
+import (
+	"context"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+	"gorm.io/gorm"
+	"main.go/repositories/token_repository"
+	"main.go/repositories/user_repository"
+	"main.go/schemas"
+	"main.go/utils/mail_utils"
+	"main.go/utils/password_utils"
+	"main.go/utils/secret_utils"
+	"main.go/utils/settings_utils"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const mailTimeout = 30 * time.Second
+
+type Service struct {
+	userRepository  *user_repository.Repository
+	tokenRepository *token_repository.Repository
+	hasher          password_utils.Hasher
+	mailer          mail_utils.Mailer
+}
+
+func NewService(userRepository *user_repository.Repository, tokenRepository *token_repository.Repository,
+	hasher password_utils.Hasher, mailer mail_utils.Mailer) *Service {
+	return &Service{userRepository: userRepository, tokenRepository: tokenRepository,
+		hasher: hasher, mailer: mailer}
+}
+
+// RequestReset mails a one-time reset link if an active account uses the email. It behaves
+// the same whether or not the account exists, and mail is sent in the background so that
+// response time does not tell the two cases apart either.
+func (r *Service) RequestReset(ctx context.Context, email string) error {
+	email = strings.ToLower(strings.TrimSpace(email))
+	user, err := r.userRepository.GetUserByEmail(ctx, email)
+	if err != nil {
+		return errors.Wrap(err, "request password reset")
+	}
+	if user.ID == uuid.Nil || !user.IsActive() {
+		zerolog.Ctx(ctx).Info().Msg("password.reset.requested.for.unknown.email")
+		return nil
+	}
+
+	now := time.Now().UTC()
+	err = r.tokenRepository.InvalidatePasswordResetTokens(ctx, user.ID, now)
+	if err != nil {
+		return errors.Wrap(err, "request password reset")
+	}
+
+	token := secret_utils.NewSecret()
+	resetToken := &schemas.PasswordResetToken{
+		ID:        uuid.New(),
+		UserId:    user.ID,
+		TokenHash: secret_utils.HashSecret(token),
+		ExpiresAt: now.Add(settings_utils.Settings.PasswordResetTtl),
+		CreatedAt: now,
+	}
+	err = r.tokenRepository.SavePasswordResetToken(ctx, resetToken)
+	if err != nil {
+		return errors.Wrap(err, "request password reset")
+	}
+
+	message := &mail_utils.Message{
+		To:      user.Email,
+		Subject: "Password reset",
+		Body: fmt.Sprintf("Hello, %s!\n\nFollow the link below to choose a new password. "+
+			"It is valid until %s UTC and can be used once.\n\n%s\n\n"+
+			"If you did not ask for a password reset, ignore this message.",
+			user.Username, resetToken.ExpiresAt.Format("2006-01-02 15:04"), resetLink(token)),
+	}
+	logger := zerolog.Ctx(ctx)
+	go func() {
+		mailCtx, cancel := context.WithTimeout(context.Background(), mailTimeout)
+		defer cancel()
+
+		err := r.mailer.Send(mailCtx, message)
+		if err != nil {
+			logger.Error().Err(err).Str("userId", user.ID.String()).Msg("password.reset.mail.failed")
+			return
+		}
+
+		logger.Info().Str("userId", user.ID.String()).Msg("password.reset.mail.sent")
+	}()
+
+	return nil
+}
+
+// ConfirmReset sets a new password using a reset token and signs the user out everywhere.
+func (r *Service) ConfirmReset(ctx context.Context, req *schemas.PasswordResetConfirmRequest) error {
+	resetToken, err := r.tokenRepository.GetPasswordResetToken(ctx, secret_utils.HashSecret(req.Token))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrInvalidResetToken
+		}
+		return errors.Wrap(err, "confirm password reset")
+	}
+
+	now := time.Now().UTC()
+	used, err := r.tokenRepository.UsePasswordResetToken(ctx, resetToken.ID, now)
+	if err != nil {
+		return errors.Wrap(err, "confirm password reset")
+	}
+	if !used {
+		return ErrInvalidResetToken
+	}
+
+	user, err := r.userRepository.GetUserById(ctx, resetToken.UserId)
+	if err != nil {
+		return errors.Wrap(err, "confirm password reset")
+	}
+	if !user.IsActive() {
+		return ErrInvalidResetToken
+	}
+
+	passwordHash, err := r.hasher.Hash(req.NewPassword)
+	if err != nil {
+		return errors.Wrap(err, "confirm password reset")
+	}
+
+	err = r.userRepository.UpdatePasswordHash(ctx, user.ID, passwordHash)
+	if err != nil {
+		return errors.Wrap(err, "confirm password reset")
+	}
+
+	_, err = r.userRepository.RevokeTokensBefore(ctx, user.ID, now)
+	if err != nil {
+		return errors.Wrap(err, "confirm password reset")
+	}
+
+	err = r.tokenRepository.RevokeUserRefreshTokens(ctx, user.ID, now)
+	if err != nil {
+		return errors.Wrap(err, "confirm password reset")
+	}
+
+	err = r.tokenRepository.RevokeUserSessions(ctx, user.ID, now)
+	if err != nil {
+		return errors.Wrap(err, "confirm password reset")
+	}
+
+	zerolog.Ctx(ctx).Info().Str("userId", user.ID.String()).Msg("password.reset")
+	return nil
+}
+
+func resetLink(token string) string {
+	link := settings_utils.Settings.PasswordResetUrl
+	separator := "?"
+	if strings.Contains(link, "?") {
+		separator = "&"
+	}
+
+	return link + separator + "token=" + url.QueryEscape(token)
+}
+
+var ErrInvalidResetToken = errors.New("invalid or expired reset token")
@@ -0,0 +1,114 @@
+package role_service
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+	"gorm.io/gorm"
+	"main.go/repositories/role_repository"
+	"main.go/schemas"
+	"slices"
+	"time"
+)
+
+type Service struct {
+	repository *role_repository.Repository
+}
+
+func NewService(repository *role_repository.Repository) *Service {
+	return &Service{repository: repository}
+}
+
+// Bootstrap seeds the default roles and migrates users from the old admin flag.
+func (r *Service) Bootstrap(ctx context.Context) error {
+	err := r.repository.SeedRoles(ctx, schemas.DefaultRoles)
+	if err != nil {
+		return errors.Wrap(err, "bootstrap roles")
+	}
+
+	err = r.repository.MigrateAdminFlag(ctx)
+	if err != nil {
+		return errors.Wrap(err, "bootstrap roles")
+	}
+
+	zerolog.Ctx(ctx).Info().Msg("roles.bootstrapped")
+	return nil
+}
+
+func (r *Service) ListRoles(ctx context.Context) (*[]schemas.Role, error) {
+	roles, err := r.repository.GetRoles(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "list roles")
+	}
+
+	zerolog.Ctx(ctx).Info().Int("amount", len(*roles)).Msg("roles.listed")
+	return roles, nil
+}
+
+func (r *Service) SaveRole(ctx context.Context, role *schemas.Role) error {
+	err := verifyPermissions(role.Permissions)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.repository.GetRoleByName(ctx, role.Name)
+	if err == nil {
+		return ErrRoleExists
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return errors.Wrap(err, "save role")
+	}
+
+	now := time.Now().UTC()
+	role.ID = uuid.New()
+	role.CreatedAt = now
+	role.UpdatedAt = now
+
+	err = r.repository.SaveRole(ctx, role)
+	if err != nil {
+		return errors.Wrap(err, "save role")
+	}
+
+	zerolog.Ctx(ctx).Info().Interface("role", role).Msg("role.saved")
+	return nil
+}
+
+func (r *Service) UpdateRole(ctx context.Context, name string, role *schemas.Role) error {
+	if name == schemas.RoleAdmin {
+		return ErrBuiltinRole
+	}
+
+	err := verifyPermissions(role.Permissions)
+	if err != nil {
+		return err
+	}
+
+	existing, err := r.repository.GetRoleByName(ctx, name)
+	if err != nil {
+		return errors.Wrap(err, "update role")
+	}
+
+	role.UpdatedAt = time.Now().UTC()
+	err = r.repository.UpdateRole(ctx, existing.ID, role)
+	if err != nil {
+		return errors.Wrap(err, "update role")
+	}
+
+	zerolog.Ctx(ctx).Info().Str("role", name).Strs("permissions", role.Permissions).Msg("role.updated")
+	return nil
+}
+
+func verifyPermissions(permissions []string) error {
+	for _, permission := range permissions {
+		if !slices.Contains(schemas.Permissions, permission) {
+			return errors.Wrap(ErrUnknownPermission, permission)
+		}
+	}
+
+	return nil
+}
+
+var ErrUnknownPermission = errors.New("unknown permission")
+var ErrRoleExists = errors.New("role already exists")
+var ErrBuiltinRole = errors.New("built-in role cannot be modified")
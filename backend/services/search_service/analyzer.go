@@ -0,0 +1,87 @@
+package search_service
+
+import (
+	"html"
+	"main.go/schemas"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// analyzerVersion changes whenever Analyze does, so that documents analyzed the old way get rebuilt.
+const analyzerVersion = 2
+
+var wordPattern = regexp.MustCompile(`[\p{L}\p{N}]+`)
+
+// Analyze splits text into the terms that are indexed and searched for.
+func Analyze(text string) []string {
+	words := wordPattern.FindAllString(text, -1)
+	terms := make([]string, 0, len(words))
+	for _, word := range words {
+		terms = append(terms, normalize(word)...)
+	}
+
+	return terms
+}
+
+// normalize folds case, diacritics and ё, romanizes Cyrillic and unifies romanization variants.
+// It yields the word both as is and stemmed: stems make word forms meet, while whole words
+// make Russian names meet their English spelling, which the two stemmers cut differently.
+func normalize(word string) []string {
+	lower := strings.ReplaceAll(strings.ToLower(word), "ё", "е")
+	whole := fold(word)
+	stem := foldLatin(transliterate(stemEnglish(foldDiacritics(stemRussian(lower)))))
+	if whole == "" {
+		return nil
+	}
+	if stem == "" || stem == whole {
+		return []string{whole}
+	}
+
+	return []string{whole, stem}
+}
+
+// fold normalizes a word without stemming it.
+func fold(word string) string {
+	return foldLatin(transliterate(foldDiacritics(strings.ReplaceAll(strings.ToLower(word), "ё", "е"))))
+}
+
+// NewDocument analyzes the searchable fields of a book. Its authors must be loaded.
+func NewDocument(book *schemas.Book) *schemas.SearchDocument {
+	return &schemas.SearchDocument{
+		BookId:      book.ID,
+		Title:       strings.Join(Analyze(book.Name), " "),
+		Authors:     strings.Join(Analyze(authorNames(book)), " "),
+		Description: strings.Join(Analyze(book.Description), " "),
+		Version:     analyzerVersion,
+		UpdatedAt:   book.UpdatedAt,
+	}
+}
+
+// Highlight escapes text for HTML and wraps the words whose term is among terms in <mark>.
+func Highlight(text string, terms []string) string {
+	var builder strings.Builder
+	last := 0
+	for _, match := range wordPattern.FindAllStringIndex(text, -1) {
+		builder.WriteString(html.EscapeString(text[last:match[0]]))
+		word := text[match[0]:match[1]]
+		if slices.ContainsFunc(normalize(word), func(term string) bool { return slices.Contains(terms, term) }) {
+			builder.WriteString("<mark>" + html.EscapeString(word) + "</mark>")
+		} else {
+			builder.WriteString(html.EscapeString(word))
+		}
+		last = match[1]
+	}
+	builder.WriteString(html.EscapeString(text[last:]))
+
+	return builder.String()
+}
+
+func authorNames(book *schemas.Book) string {
+	names := make([]string, 0, len(book.Authors))
+	for _, author := range book.Authors {
+		names = append(names, author.Name)
+	}
+
+	return strings.Join(names, ", ")
+}
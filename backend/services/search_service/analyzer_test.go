@@ -0,0 +1,65 @@
+package search_service
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestAnalyzeFoldsSpellings(t *testing.T) {
+	cases := []struct {
+		a, b string
+	}{
+		{"Tolstoy", "Толстой"},
+		{"Dostoevsky", "Достоевский"},
+		{"Dostoyevsky", "Dostoevsky"},
+		{"Crème brûlée", "creme brulee"},
+		{"Ёлка", "елка"},
+		{"running", "run"},
+	}
+	for _, c := range cases {
+		a, b := Analyze(c.a), Analyze(c.b)
+		if !slices.ContainsFunc(a, func(term string) bool { return slices.Contains(b, term) }) {
+			t.Errorf("Analyze(%q) = %q and Analyze(%q) = %q share no term", c.a, a, c.b, b)
+		}
+	}
+}
+
+func TestAnalyzeSplitsWords(t *testing.T) {
+	cases := []struct {
+		text string
+		want []string
+	}{
+		{"", []string{}},
+		{"  ,.!  ", []string{}},
+		{"War & Peace", []string{"war", "peace", "peac"}},
+		{"Лев Толстой", []string{"lev", "tolstoi", "tolst"}},
+		{"1984", []string{"1984"}},
+	}
+	for _, c := range cases {
+		got := Analyze(c.text)
+		if !slices.Equal(got, c.want) {
+			t.Errorf("Analyze(%q) = %q, want %q", c.text, got, c.want)
+		}
+	}
+}
+
+func TestHighlight(t *testing.T) {
+	cases := []struct {
+		text  string
+		query string
+		want  string
+	}{
+		{"War & Peace", "war", "<mark>War</mark> &amp; Peace"},
+		{"<b>War</b>", "war", "&lt;b&gt;<mark>War</mark>&lt;/b&gt;"},
+		{"Лев Толстой", "tolstoy", "Лев <mark>Толстой</mark>"},
+		{"Running wild", "run", "<mark>Running</mark> wild"},
+		{"Anna Karenina", "war", "Anna Karenina"},
+		{"", "war", ""},
+	}
+	for _, c := range cases {
+		got := Highlight(c.text, Analyze(c.query))
+		if got != c.want {
+			t.Errorf("Highlight(%q, %q) = %q, want %q", c.text, c.query, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package search_service
+
+import (
+	"github.com/google/uuid"
+	"main.go/schemas"
+	"slices"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// maxExpansions bounds how many vocabulary terms a single misspelled query term turns into.
+const maxExpansions = 8
+
+// Vocabulary holds the terms of book names and authors, which misspelled query terms are matched against.
+type Vocabulary struct {
+	mu    sync.RWMutex
+	terms map[string]int
+	// lengths groups the terms by their length in runes, so that Expand only compares a query term
+	// with terms whose length is within the allowed distance.
+	lengths map[int]map[string]struct{}
+	books   map[uuid.UUID][]string
+}
+
+func NewVocabulary() *Vocabulary {
+	return &Vocabulary{terms: make(map[string]int), lengths: make(map[int]map[string]struct{}),
+		books: make(map[uuid.UUID][]string)}
+}
+
+// Replace swaps in the terms of other, which must not be used afterwards.
+func (r *Vocabulary) Replace(other *Vocabulary) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.terms, r.lengths, r.books = other.terms, other.lengths, other.books
+}
+
+// Add replaces the terms contributed by the book with those of its document.
+func (r *Vocabulary) Add(document *schemas.SearchDocument) {
+	terms := uniqueTerms(strings.Fields(document.Title + " " + document.Authors))
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.remove(document.BookId)
+	for _, term := range terms {
+		r.terms[term]++
+		if r.terms[term] > 1 {
+			continue
+		}
+		length := utf8.RuneCountInString(term)
+		if r.lengths[length] == nil {
+			r.lengths[length] = make(map[string]struct{})
+		}
+		r.lengths[length][term] = struct{}{}
+	}
+	r.books[document.BookId] = terms
+}
+
+func (r *Vocabulary) Remove(bookId uuid.UUID) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.remove(bookId)
+}
+
+// Expand adds to the terms the vocabulary terms within the edit distance allowed for their length.
+// Exact terms come first so that they are also what gets highlighted first.
+func (r *Vocabulary) Expand(terms []string) []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	expanded := slices.Clone(terms)
+	for _, term := range terms {
+		distance := allowedDistance(term)
+		if distance == 0 {
+			continue
+		}
+
+		type candidate struct {
+			term     string
+			distance int
+			count    int
+		}
+		var candidates []candidate
+		length := utf8.RuneCountInString(term)
+		for l := length - distance; l <= length+distance; l++ {
+			for known := range r.lengths[l] {
+				if known == term {
+					continue
+				}
+				if d := editDistance(term, known, distance); d <= distance {
+					candidates = append(candidates, candidate{known, d, r.terms[known]})
+				}
+			}
+		}
+
+		slices.SortFunc(candidates, func(a, b candidate) int {
+			if a.distance != b.distance {
+				return a.distance - b.distance
+			}
+			if a.count != b.count {
+				return b.count - a.count
+			}
+			return strings.Compare(a.term, b.term)
+		})
+		for i := 0; i < len(candidates) && i < maxExpansions; i++ {
+			expanded = append(expanded, candidates[i].term)
+		}
+	}
+
+	return uniqueTerms(expanded)
+}
+
+// remove must be called with mu held.
+func (r *Vocabulary) remove(bookId uuid.UUID) {
+	for _, term := range r.books[bookId] {
+		r.terms[term]--
+		if r.terms[term] <= 0 {
+			delete(r.terms, term)
+			length := utf8.RuneCountInString(term)
+			delete(r.lengths[length], term)
+			if len(r.lengths[length]) == 0 {
+				delete(r.lengths, length)
+			}
+		}
+	}
+	delete(r.books, bookId)
+}
+
+// allowedDistance tolerates one typo in words of four to seven letters and two in longer ones.
+func allowedDistance(term string) int {
+	switch length := len([]rune(term)); {
+	case length < 4:
+		return 0
+	case length < 8:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// editDistance is the optimal string alignment distance of a and b, counting a swap of two
+// neighbouring letters as one edit. It gives up with max+1 once the distance exceeds max.
+func editDistance(a, b string, max int) int {
+	s, t := []rune(a), []rune(b)
+	previous2 := make([]int, len(t)+1)
+	previous := make([]int, len(t)+1)
+	current := make([]int, len(t)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+
+	for i := 1; i <= len(s); i++ {
+		current[0] = i
+		rowMin := current[0]
+		for j := 1; j <= len(t); j++ {
+			cost := 1
+			if s[i-1] == t[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+			if i > 1 && j > 1 && s[i-1] == t[j-2] && s[i-2] == t[j-1] {
+				current[j] = min(current[j], previous2[j-2]+1)
+			}
+			rowMin = min(rowMin, current[j])
+		}
+		if rowMin > max {
+			return max + 1
+		}
+		previous2, previous, current = previous, current, previous2
+	}
+
+	return previous[len(t)]
+}
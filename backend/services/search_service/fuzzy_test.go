@@ -0,0 +1,54 @@
+package search_service
+
+import (
+	"github.com/google/uuid"
+	"main.go/schemas"
+	"slices"
+	"testing"
+)
+
+func TestVocabularyExpand(t *testing.T) {
+	vocabulary := NewVocabulary()
+	vocabulary.Add(newTestDocument("Crime and Punishment", "Fyodor Dostoevsky", ""))
+	vocabulary.Add(newTestDocument("The Idiot", "Fyodor Dostoevsky", ""))
+
+	cases := []struct {
+		query string
+		want  string
+	}{
+		{"dostoevksy", "dostoevski"},
+		{"punishmnet", "punishment"},
+		{"crme", "crime"},
+	}
+	for _, c := range cases {
+		terms := vocabulary.Expand(Analyze(c.query))
+		if !slices.Contains(terms, c.want) {
+			t.Errorf("Expand(%q) = %q, want it to contain %q", c.query, terms, c.want)
+		}
+	}
+
+	terms := vocabulary.Expand(Analyze("war"))
+	if !slices.Equal(terms, []string{"war"}) {
+		t.Errorf("Expand(war) = %q, want short terms left alone", terms)
+	}
+}
+
+func TestVocabularyRemoveAndReplace(t *testing.T) {
+	vocabulary := NewVocabulary()
+	document := newTestDocument("Crime and Punishment", "", "")
+	vocabulary.Add(document)
+	vocabulary.Remove(document.BookId)
+	if terms := vocabulary.Expand([]string{"crme"}); slices.Contains(terms, "crime") {
+		t.Fatalf("Expand(crme) = %q after the book was removed", terms)
+	}
+	if len(vocabulary.lengths) != 0 {
+		t.Fatalf("removing the only book left %d term lengths behind", len(vocabulary.lengths))
+	}
+
+	rebuilt := NewVocabulary()
+	rebuilt.Add(&schemas.SearchDocument{BookId: uuid.New(), Title: "crime"})
+	vocabulary.Replace(rebuilt)
+	if terms := vocabulary.Expand([]string{"crme"}); !slices.Contains(terms, "crime") {
+		t.Fatalf("Expand(crme) = %q after Replace, want crime", terms)
+	}
+}
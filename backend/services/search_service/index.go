@@ -0,0 +1,162 @@
+package search_service
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"main.go/schemas"
+	"math"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Index stores analyzed book documents and ranks them against analyzed query terms.
+// A document matches if it contains any of the terms.
+type Index interface {
+	Upsert(ctx context.Context, document *schemas.SearchDocument) error
+	Delete(ctx context.Context, bookId uuid.UUID) error
+	// Search returns at most limit hits, best first.
+	Search(ctx context.Context, terms []string, limit int) ([]schemas.SearchHit, error)
+	// Outdated reports whether the index is empty or holds documents of another analyzer version.
+	Outdated(ctx context.Context, version int) (bool, error)
+}
+
+// Field weights shared by the indexes: a match in the title counts more than one in the description.
+const (
+	titleWeight       = 3
+	authorsWeight     = 2
+	descriptionWeight = 1
+)
+
+// MemoryIndex is an inverted index kept in process. It is rebuilt on every start and not shared
+// between instances, which makes it suited to tests and single-instance setups.
+type MemoryIndex struct {
+	mu        sync.RWMutex
+	documents map[uuid.UUID]schemas.SearchDocument
+	// postings maps a term to the weighted frequency of the term in every document containing it.
+	postings map[string]map[uuid.UUID]float64
+}
+
+func NewMemoryIndex() *MemoryIndex {
+	return &MemoryIndex{
+		documents: make(map[uuid.UUID]schemas.SearchDocument),
+		postings:  make(map[string]map[uuid.UUID]float64),
+	}
+}
+
+func (r *MemoryIndex) Upsert(_ context.Context, document *schemas.SearchDocument) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.remove(document.BookId)
+	r.documents[document.BookId] = *document
+	for _, field := range []struct {
+		text   string
+		weight float64
+	}{
+		{document.Title, titleWeight},
+		{document.Authors, authorsWeight},
+		{document.Description, descriptionWeight},
+	} {
+		for _, term := range strings.Fields(field.text) {
+			if r.postings[term] == nil {
+				r.postings[term] = make(map[uuid.UUID]float64)
+			}
+			r.postings[term][document.BookId] += field.weight
+		}
+	}
+
+	return nil
+}
+
+func (r *MemoryIndex) Delete(_ context.Context, bookId uuid.UUID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.remove(bookId)
+	return nil
+}
+
+// Search scores documents by the weighted frequency of each term times its inverse document frequency.
+func (r *MemoryIndex) Search(_ context.Context, terms []string, limit int) ([]schemas.SearchHit, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	scores := make(map[uuid.UUID]float64)
+	total := float64(len(r.documents))
+	for _, term := range uniqueTerms(terms) {
+		postings := r.postings[term]
+		if len(postings) == 0 {
+			continue
+		}
+		idf := math.Log(1 + total/float64(len(postings)))
+		for bookId, frequency := range postings {
+			scores[bookId] += idf * frequency
+		}
+	}
+
+	hits := make([]schemas.SearchHit, 0, len(scores))
+	for bookId, score := range scores {
+		hits = append(hits, schemas.SearchHit{BookId: bookId, Score: score})
+	}
+	sortHits(hits)
+	if len(hits) > limit {
+		hits = hits[:limit]
+	}
+
+	return hits, nil
+}
+
+func (r *MemoryIndex) Outdated(_ context.Context, version int) (bool, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if len(r.documents) == 0 {
+		return true, nil
+	}
+	for _, document := range r.documents {
+		if document.Version != version {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// remove must be called with mu held.
+func (r *MemoryIndex) remove(bookId uuid.UUID) {
+	document, ok := r.documents[bookId]
+	if !ok {
+		return
+	}
+
+	for _, term := range strings.Fields(document.Title + " " + document.Authors + " " + document.Description) {
+		delete(r.postings[term], bookId)
+		if len(r.postings[term]) == 0 {
+			delete(r.postings, term)
+		}
+	}
+	delete(r.documents, bookId)
+}
+
+func uniqueTerms(terms []string) []string {
+	unique := slices.Clone(terms)
+	slices.Sort(unique)
+	return slices.Compact(unique)
+}
+
+// sortHits orders by score, breaking ties by id so that pages are stable.
+func sortHits(hits []schemas.SearchHit) {
+	slices.SortFunc(hits, compareHits)
+}
+
+// compareHits is negative when a ranks before b.
+func compareHits(a, b schemas.SearchHit) int {
+	if a.Score != b.Score {
+		if a.Score > b.Score {
+			return -1
+		}
+		return 1
+	}
+	return strings.Compare(a.BookId.String(), b.BookId.String())
+}
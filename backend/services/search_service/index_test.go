@@ -0,0 +1,185 @@
+package search_service
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"main.go/schemas"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func newTestDocument(title, authors, description string) *schemas.SearchDocument {
+	return &schemas.SearchDocument{
+		BookId:      uuid.New(),
+		Title:       strings.Join(Analyze(title), " "),
+		Authors:     strings.Join(Analyze(authors), " "),
+		Description: strings.Join(Analyze(description), " "),
+		Version:     analyzerVersion,
+	}
+}
+
+func hitIds(hits []schemas.SearchHit) []uuid.UUID {
+	ids := make([]uuid.UUID, 0, len(hits))
+	for _, hit := range hits {
+		ids = append(ids, hit.BookId)
+	}
+	return ids
+}
+
+func TestMemoryIndexSearchOrdersByFieldWeight(t *testing.T) {
+	ctx := context.Background()
+	index := NewMemoryIndex()
+	inTitle := newTestDocument("Whale", "Herman Melville", "A voyage")
+	inAuthors := newTestDocument("Moby Dick", "Whale", "A voyage")
+	inDescription := newTestDocument("Moby Dick", "Herman Melville", "A whale")
+	unrelated := newTestDocument("Anna Karenina", "Leo Tolstoy", "A novel")
+	for _, document := range []*schemas.SearchDocument{inDescription, unrelated, inTitle, inAuthors} {
+		err := index.Upsert(ctx, document)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	hits, err := index.Search(ctx, Analyze("whale"), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []uuid.UUID{inTitle.BookId, inAuthors.BookId, inDescription.BookId}
+	if got := hitIds(hits); !slices.Equal(got, want) {
+		t.Fatalf("Search = %v, want %v", got, want)
+	}
+
+	hits, err = index.Search(ctx, Analyze("whale"), 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := hitIds(hits); !slices.Equal(got, want[:2]) {
+		t.Fatalf("Search with limit 2 = %v, want %v", got, want[:2])
+	}
+}
+
+func TestMemoryIndexSearchCountsRepeatedTermsOnce(t *testing.T) {
+	ctx := context.Background()
+	index := NewMemoryIndex()
+	err := index.Upsert(ctx, newTestDocument("War and Peace", "", ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	once, _ := index.Search(ctx, []string{"war"}, 10)
+	twice, _ := index.Search(ctx, []string{"war", "war"}, 10)
+	if len(once) != 1 || len(twice) != 1 || once[0].Score != twice[0].Score {
+		t.Fatalf("Search(war) = %v, Search(war war) = %v, want the same single hit", once, twice)
+	}
+}
+
+func TestMemoryIndexUpsertReplacesDocument(t *testing.T) {
+	ctx := context.Background()
+	index := NewMemoryIndex()
+	document := newTestDocument("War", "", "")
+	err := index.Upsert(ctx, document)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	document.Title = strings.Join(Analyze("Peace"), " ")
+	err = index.Upsert(ctx, document)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hits, _ := index.Search(ctx, Analyze("war"), 10)
+	if len(hits) != 0 {
+		t.Fatalf("Search(war) after replacing the title = %v, want no hits", hits)
+	}
+	hits, _ = index.Search(ctx, Analyze("peace"), 10)
+	if got := hitIds(hits); !slices.Equal(got, []uuid.UUID{document.BookId}) {
+		t.Fatalf("Search(peace) = %v, want %v", got, document.BookId)
+	}
+	if len(index.documents) != 1 {
+		t.Fatalf("index holds %d documents, want 1", len(index.documents))
+	}
+}
+
+func TestMemoryIndexDelete(t *testing.T) {
+	ctx := context.Background()
+	index := NewMemoryIndex()
+	kept := newTestDocument("War and Peace", "", "")
+	deleted := newTestDocument("War of the Worlds", "", "")
+	for _, document := range []*schemas.SearchDocument{kept, deleted} {
+		err := index.Upsert(ctx, document)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err := index.Delete(ctx, deleted.BookId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = index.Delete(ctx, uuid.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hits, _ := index.Search(ctx, Analyze("war"), 10)
+	if got := hitIds(hits); !slices.Equal(got, []uuid.UUID{kept.BookId}) {
+		t.Fatalf("Search(war) = %v, want %v", got, kept.BookId)
+	}
+	if _, ok := index.postings["worlds"]; ok {
+		t.Fatal("postings of the deleted document are left behind")
+	}
+
+	err = index.Delete(ctx, kept.BookId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	outdated, _ := index.Outdated(ctx, analyzerVersion)
+	if !outdated {
+		t.Fatal("an empty index is not outdated")
+	}
+}
+
+func TestMemoryIndexOutdated(t *testing.T) {
+	ctx := context.Background()
+	index := NewMemoryIndex()
+	document := newTestDocument("War and Peace", "", "")
+	err := index.Upsert(ctx, document)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	outdated, _ := index.Outdated(ctx, analyzerVersion)
+	if outdated {
+		t.Fatal("an index of the current version is outdated")
+	}
+	outdated, _ = index.Outdated(ctx, analyzerVersion+1)
+	if !outdated {
+		t.Fatal("an index of an older version is not outdated")
+	}
+}
+
+func TestSortHitsBreaksTiesById(t *testing.T) {
+	low := uuid.MustParse("00000000-0000-0000-0000-000000000001")
+	high := uuid.MustParse("ffffffff-0000-0000-0000-000000000000")
+	best := uuid.MustParse("80000000-0000-0000-0000-000000000000")
+	hits := []schemas.SearchHit{
+		{BookId: high, Score: 1},
+		{BookId: best, Score: 2},
+		{BookId: low, Score: 1},
+	}
+
+	sortHits(hits)
+	want := []uuid.UUID{best, low, high}
+	if got := hitIds(hits); !slices.Equal(got, want) {
+		t.Fatalf("sortHits = %v, want %v", got, want)
+	}
+
+	if compareHits(hits[1], hits[1]) != 0 {
+		t.Fatal("compareHits of a hit with itself is not 0")
+	}
+	if compareHits(hits[1], hits[2]) >= 0 || compareHits(hits[2], hits[1]) <= 0 {
+		t.Fatal("compareHits does not order equal scores by id")
+	}
+}
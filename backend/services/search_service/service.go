@@ -0,0 +1,455 @@
+package search_service
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+	"gorm.io/gorm"
+	"main.go/repositories/book_repository"
+	"main.go/repositories/category_repository"
+	"main.go/schemas"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// maxHits bounds how many matches a search considers before sorting and paging.
+	maxHits          = 1000
+	reindexBatchSize = 200
+	// maxAuthorFacets bounds the authors counted in facets to those with the most books.
+	maxAuthorFacets = 50
+)
+
+// priceBucketBounds splits prices, in cents, into the buckets counted in facets.
+var priceBucketBounds = []int{500, 1000, 2000, 5000}
+
+type Service struct {
+	index              Index
+	vocabulary         *Vocabulary
+	suggester          *Suggester
+	bookRepository     *book_repository.Repository
+	categoryRepository *category_repository.Repository
+}
+
+func NewService(index Index, bookRepository *book_repository.Repository,
+	categoryRepository *category_repository.Repository) *Service {
+	return &Service{index: index, vocabulary: NewVocabulary(), suggester: NewSuggester(),
+		bookRepository: bookRepository, categoryRepository: categoryRepository}
+}
+
+// Bootstrap loads the vocabulary and the suggestions from the catalog and rebuilds the index when it
+// is empty, which is always the case for the in-memory one, or was built by another analyzer version.
+func (r *Service) Bootstrap(ctx context.Context) error {
+	outdated, err := r.index.Outdated(ctx, analyzerVersion)
+	if err != nil {
+		return errors.Wrap(err, "bootstrap search index")
+	}
+
+	indexed, err := r.rebuild(ctx, outdated)
+	if err != nil {
+		return errors.Wrap(err, "bootstrap search index")
+	}
+
+	zerolog.Ctx(ctx).Info().Int("amount", indexed).Bool("rebuilt", outdated).Msg("search.index.built")
+	return nil
+}
+
+// Run rebuilds the vocabulary and the suggestions from the catalog every interval, so that they
+// also know the writes made through other instances. It blocks until ctx is cancelled.
+func (r *Service) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			_, err := r.rebuild(ctx, false)
+			if err != nil {
+				zerolog.Ctx(ctx).Error().Err(err).Msg("search.refresh.failed")
+			}
+		}
+	}
+}
+
+// rebuild reads the catalog into a new vocabulary and suggester, which then replace the current
+// ones. With reindex the documents are also written to the index. Writes committed while the
+// catalog is read may be missed until the next rebuild.
+func (r *Service) rebuild(ctx context.Context, reindex bool) (int, error) {
+	vocabulary, suggester := NewVocabulary(), NewSuggester()
+	categories, err := r.categoryRepository.GetCategories(ctx)
+	if err != nil {
+		return 0, err
+	}
+	for i := range *categories {
+		suggester.SetCategory(&(*categories)[i], nil)
+	}
+
+	indexed := 0
+	err = r.bookRepository.EachBook(ctx, reindexBatchSize, func(books []schemas.Book) error {
+		for i := range books {
+			document := NewDocument(&books[i])
+			vocabulary.Add(document)
+			suggester.SetBook(&books[i])
+			if !reindex {
+				continue
+			}
+			err := r.index.Upsert(ctx, document)
+			if err != nil {
+				return err
+			}
+		}
+		indexed += len(books)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	suggester.Build()
+
+	r.vocabulary.Replace(vocabulary)
+	r.suggester.Replace(suggester)
+	return indexed, nil
+}
+
+// SearchBooks finds books by title, authors and description. Misspelled words also match close
+// words of titles and author names. Sorting by relevance puts the best match first for DESC;
+// the other sort fields work as in plain listings.
+func (r *Service) SearchBooks(ctx context.Context, phrase string, request *schemas.PageRequest) (*schemas.BookPage, error) {
+	return r.FilterBooks(ctx, &schemas.BookFilter{Phrase: phrase}, request)
+}
+
+// FilterBooks lists the books matching filter. With a phrase the books are searched for as in
+// SearchBooks and may be sorted by relevance; without one relevance falls back to the name.
+func (r *Service) FilterBooks(ctx context.Context, filter *schemas.BookFilter, request *schemas.PageRequest) (*schemas.BookPage, error) {
+	resolved, hits, terms, err := r.resolve(ctx, filter)
+	if err != nil {
+		return nil, errors.Wrap(err, "filter books")
+	}
+
+	var page *schemas.BookPage
+	if request.SortBy == "relevance" && hits != nil {
+		var matching []uuid.UUID
+		matching, err = r.bookRepository.FilterBookIds(ctx, resolved)
+		if err == nil {
+			page, err = r.pageByRelevance(ctx, rankedHits(hits, matching), request)
+		}
+	} else {
+		page, err = r.bookRepository.PageBooks(ctx, resolved, request)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "filter books")
+	}
+
+	if len(hits) >= maxHits && page.Total != nil {
+		// Only the best maxHits matches are considered, so there may be more.
+		page.TotalEstimated = true
+	}
+
+	if hits != nil {
+		scores := make(map[uuid.UUID]float64, len(hits))
+		for _, hit := range hits {
+			scores[hit.BookId] = hit.Score
+		}
+		for i := range page.Books {
+			book := &page.Books[i]
+			book.Relevance = scores[book.ID]
+			book.Highlights = map[string]string{
+				"name":    Highlight(book.Name, terms),
+				"authors": Highlight(authorNames(book), terms),
+				"desc":    Highlight(book.Description, terms),
+			}
+		}
+	}
+
+	zerolog.Ctx(ctx).Info().Interface("filter", filter).Int("amount", len(page.Books)).Msg("books.found")
+	return page, nil
+}
+
+// Facets counts the books matching filter by category, author and price bucket. Category counts
+// include the books of subcategories, like category listings do.
+func (r *Service) Facets(ctx context.Context, filter *schemas.BookFilter) (*schemas.BookFacets, error) {
+	resolved, _, _, err := r.resolve(ctx, filter)
+	if err != nil {
+		return nil, errors.Wrap(err, "count facets")
+	}
+
+	withoutCategories := *resolved
+	if filter.CategoryMatch != schemas.CategoryMatchAll {
+		withoutCategories.CategoryGroups = nil
+	}
+	categories, err := r.countCategories(ctx, &withoutCategories)
+	if err != nil {
+		return nil, errors.Wrap(err, "count facets")
+	}
+
+	withoutAuthors := *resolved
+	withoutAuthors.AuthorIds = nil
+	authors, err := r.bookRepository.CountAuthors(ctx, &withoutAuthors, maxAuthorFacets)
+	if err != nil {
+		return nil, errors.Wrap(err, "count facets")
+	}
+
+	withoutPrices := *resolved
+	withoutPrices.MinPrice, withoutPrices.MaxPrice = nil, nil
+	counts, err := r.bookRepository.CountPrices(ctx, &withoutPrices, priceBucketBounds)
+	if err != nil {
+		return nil, errors.Wrap(err, "count facets")
+	}
+	prices := make([]schemas.PriceBucket, len(counts))
+	for i, count := range counts {
+		prices[i].Count = count
+		if i > 0 {
+			prices[i].Min = priceBucketBounds[i-1]
+		}
+		if i < len(priceBucketBounds) {
+			prices[i].Max = &priceBucketBounds[i]
+		}
+	}
+
+	zerolog.Ctx(ctx).Info().Interface("filter", filter).Msg("facets.counted")
+	return &schemas.BookFacets{Categories: categories, Authors: authors, Prices: prices}, nil
+}
+
+// IndexBook brings the book's document up to date once a write has committed; the catalog services
+// call it, so every write path reindexes. Deleted books leave the index. Failing to do so must
+// not fail the write, so errors are only logged.
+func (r *Service) IndexBook(ctx context.Context, id uuid.UUID) {
+	book, err := r.bookRepository.BookInfo(ctx, id)
+	if err == nil {
+		if book.DeletedAt.IsZero() {
+			document := NewDocument(book)
+			r.vocabulary.Add(document)
+			r.suggester.SetBook(book)
+			err = r.index.Upsert(ctx, document)
+		} else {
+			r.vocabulary.Remove(id)
+			r.suggester.RemoveBook(id)
+			err = r.index.Delete(ctx, id)
+		}
+	}
+	if err != nil {
+		zerolog.Ctx(ctx).Error().Err(err).Str("bookId", id.String()).Msg("search.index.failed")
+	}
+}
+
+// IndexBooks reindexes books whose category links changed, see IndexBook.
+func (r *Service) IndexBooks(ctx context.Context, ids []uuid.UUID) {
+	for _, id := range ids {
+		r.IndexBook(ctx, id)
+	}
+}
+
+// IndexAuthorBooks reindexes the books of an author whose name changed.
+func (r *Service) IndexAuthorBooks(ctx context.Context, authorId uuid.UUID) {
+	books, err := r.bookRepository.GetBooksByAuthor(ctx, authorId)
+	if err != nil {
+		zerolog.Ctx(ctx).Error().Err(err).Str("authorId", authorId.String()).Msg("search.index.failed")
+		return
+	}
+
+	for i := range *books {
+		document := NewDocument(&(*books)[i])
+		r.vocabulary.Add(document)
+		r.suggester.SetBook(&(*books)[i])
+		err = r.index.Upsert(ctx, document)
+		if err != nil {
+			zerolog.Ctx(ctx).Error().Err(err).Str("bookId", (*books)[i].ID.String()).Msg("search.index.failed")
+		}
+	}
+}
+
+// Suggest completes a prefix typed in the search bar with titles, authors and categories.
+func (r *Service) Suggest(ctx context.Context, prefix string, limit int) []schemas.Suggestion {
+	suggestions := r.suggester.Suggest(prefix, limit)
+
+	zerolog.Ctx(ctx).Info().Str("prefix", prefix).Int("amount", len(suggestions)).Msg("suggestions.found")
+	return suggestions
+}
+
+// IndexCategory brings the suggestion of the category and the books it counts up to date after
+// a category write. Deleted categories leave the suggestions. Like IndexBook it only logs errors.
+func (r *Service) IndexCategory(ctx context.Context, id uuid.UUID) {
+	category, err := r.categoryRepository.GetCategory(ctx, id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		r.suggester.RemoveCategory(id)
+		return
+	}
+	if err == nil {
+		var bookIds []uuid.UUID
+		bookIds, err = r.categoryRepository.GetCategoryBookIds(ctx, id)
+		if err == nil {
+			r.suggester.SetCategory(category, bookIds)
+		}
+	}
+	if err != nil {
+		zerolog.Ctx(ctx).Error().Err(err).Str("categoryId", id.String()).Msg("search.index.failed")
+	}
+}
+
+// resolve expands the categories of filter into their subtrees and searches for its phrase.
+// Without a phrase hits and terms are nil.
+func (r *Service) resolve(ctx context.Context, filter *schemas.BookFilter) (*schemas.BookFilter, []schemas.SearchHit, []string, error) {
+	resolved := *filter
+	if len(filter.CategoryIds) > 0 {
+		categories, err := r.categoryRepository.GetCategories(ctx)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+
+		tree := schemas.NewCategoryTree(*categories)
+		resolved.CategoryGroups = nil
+		for _, id := range filter.CategoryIds {
+			subtree := tree.Subtree(id)
+			if filter.CategoryMatch == schemas.CategoryMatchAll || len(resolved.CategoryGroups) == 0 {
+				resolved.CategoryGroups = append(resolved.CategoryGroups, subtree)
+			} else {
+				resolved.CategoryGroups[0] = append(resolved.CategoryGroups[0], subtree...)
+			}
+		}
+	}
+
+	if filter.Phrase == "" {
+		return &resolved, nil, nil, nil
+	}
+
+	terms := r.vocabulary.Expand(Analyze(filter.Phrase))
+	hits := []schemas.SearchHit{}
+	if len(terms) > 0 {
+		var err error
+		hits, err = r.index.Search(ctx, terms, maxHits)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+	}
+	resolved.BookIds = make([]uuid.UUID, 0, len(hits))
+	for _, hit := range hits {
+		resolved.BookIds = append(resolved.BookIds, hit.BookId)
+	}
+
+	return &resolved, hits, terms, nil
+}
+
+// countCategories counts the distinct books matching filter in every category and its subcategories.
+func (r *Service) countCategories(ctx context.Context, filter *schemas.BookFilter) ([]schemas.FacetCount, error) {
+	categories, err := r.categoryRepository.GetCategories(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	links, err := r.bookRepository.GetFilteredCategoryLinks(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	booksByCategory := make(map[uuid.UUID][]uuid.UUID)
+	for _, link := range *links {
+		booksByCategory[link.CategoryId] = append(booksByCategory[link.CategoryId], link.BookId)
+	}
+
+	tree := schemas.NewCategoryTree(*categories)
+	counts := make([]schemas.FacetCount, 0, len(*categories))
+	for _, category := range *categories {
+		books := make(map[uuid.UUID]struct{})
+		for _, categoryId := range tree.Subtree(category.ID) {
+			for _, bookId := range booksByCategory[categoryId] {
+				books[bookId] = struct{}{}
+			}
+		}
+		if len(books) > 0 {
+			counts = append(counts, schemas.FacetCount{ID: category.ID, Name: category.Name, Count: int64(len(books))})
+		}
+	}
+	slices.SortFunc(counts, func(a, b schemas.FacetCount) int {
+		if a.Count != b.Count {
+			return int(b.Count - a.Count)
+		}
+		return strings.Compare(a.Name, b.Name)
+	})
+
+	return counts, nil
+}
+
+// pageByRelevance pages the hits in rank order, then loads only the books of that page.
+// A cursor resumes after the hit it points at even if that book no longer matches.
+func (r *Service) pageByRelevance(ctx context.Context, hits []schemas.SearchHit, request *schemas.PageRequest) (*schemas.BookPage, error) {
+	if request.OrderBy == "ASC" {
+		hits = slices.Clone(hits)
+		slices.Reverse(hits)
+	}
+
+	start := min(request.Page*request.PageSize, len(hits))
+	if request.Cursor != nil {
+		score, err := strconv.ParseFloat(request.Cursor.Value, 64)
+		if err != nil {
+			return nil, ErrInvalidCursor
+		}
+		cursor := schemas.SearchHit{BookId: request.Cursor.ID, Score: score}
+		start = slices.IndexFunc(hits, func(hit schemas.SearchHit) bool {
+			if request.OrderBy == "ASC" {
+				return compareHits(hit, cursor) < 0
+			}
+			return compareHits(hit, cursor) > 0
+		})
+		if start < 0 {
+			start = len(hits)
+		}
+	}
+	end := min(start+request.PageSize, len(hits))
+
+	page := &schemas.BookPage{Books: []schemas.Book{}, Sort: schemas.Sort{By: request.SortBy, Order: request.OrderBy},
+		PageSize: request.PageSize}
+	if request.Total != schemas.TotalNone {
+		total := int64(len(hits))
+		page.Total = &total
+	}
+	if end < len(hits) {
+		last := hits[end-1]
+		cursor := schemas.Cursor{SortBy: request.SortBy, OrderBy: request.OrderBy,
+			Value: strconv.FormatFloat(last.Score, 'g', -1, 64), ID: last.BookId}
+		page.NextCursor = cursor.Encode()
+	}
+	if start >= end {
+		return page, nil
+	}
+
+	pageIds := make([]uuid.UUID, 0, end-start)
+	positions := make(map[uuid.UUID]int, end-start)
+	for i, hit := range hits[start:end] {
+		pageIds = append(pageIds, hit.BookId)
+		positions[hit.BookId] = i
+	}
+	books, err := r.bookRepository.GetBooksByIds(ctx, pageIds)
+	if err != nil {
+		return nil, err
+	}
+	slices.SortFunc(*books, func(a, b schemas.Book) int {
+		return positions[a.ID] - positions[b.ID]
+	})
+	page.Books = *books
+
+	return page, nil
+}
+
+// rankedHits keeps the hits of ids, in rank order.
+func rankedHits(hits []schemas.SearchHit, ids []uuid.UUID) []schemas.SearchHit {
+	matching := make(map[uuid.UUID]struct{}, len(ids))
+	for _, id := range ids {
+		matching[id] = struct{}{}
+	}
+
+	ranked := make([]schemas.SearchHit, 0, len(ids))
+	for _, hit := range hits {
+		if _, ok := matching[hit.BookId]; ok {
+			ranked = append(ranked, hit)
+		}
+	}
+
+	return ranked
+}
+
+var ErrInvalidCursor = errors.New("invalid cursor")
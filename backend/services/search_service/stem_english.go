@@ -0,0 +1,266 @@
+package search_service
+
+// stemEnglish implements the Porter stemming algorithm for lower-case ASCII words.
+// Other words are returned unchanged.
+func stemEnglish(word string) string {
+	for i := 0; i < len(word); i++ {
+		if word[i] < 'a' || word[i] > 'z' {
+			return word
+		}
+	}
+	if len(word) <= 2 {
+		return word
+	}
+
+	p := &porter{b: []byte(word), k: len(word) - 1}
+	p.step1ab()
+	if p.k > 0 {
+		p.step1c()
+		p.step2()
+		p.step3()
+		p.step4()
+		p.step5()
+	}
+
+	return string(p.b[:p.k+1])
+}
+
+// porter follows the reference implementation: b[0..k] is the word being stemmed
+// and j marks the end of the stem once ends has matched a suffix.
+type porter struct {
+	b    []byte
+	k, j int
+}
+
+func (p *porter) cons(i int) bool {
+	switch p.b[i] {
+	case 'a', 'e', 'i', 'o', 'u':
+		return false
+	case 'y':
+		return i == 0 || !p.cons(i-1)
+	}
+	return true
+}
+
+// m measures the number of consonant sequences between 0 and j.
+func (p *porter) m() int {
+	n, i := 0, 0
+	for {
+		if i > p.j {
+			return n
+		}
+		if !p.cons(i) {
+			break
+		}
+		i++
+	}
+	i++
+	for {
+		for {
+			if i > p.j {
+				return n
+			}
+			if p.cons(i) {
+				break
+			}
+			i++
+		}
+		i++
+		n++
+		for {
+			if i > p.j {
+				return n
+			}
+			if !p.cons(i) {
+				break
+			}
+			i++
+		}
+		i++
+	}
+}
+
+func (p *porter) vowelInStem() bool {
+	for i := 0; i <= p.j; i++ {
+		if !p.cons(i) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *porter) doubleCons(j int) bool {
+	return j >= 1 && p.b[j] == p.b[j-1] && p.cons(j)
+}
+
+// cvc reports whether i-2, i-1, i is consonant-vowel-consonant and the last one is not w, x or y.
+func (p *porter) cvc(i int) bool {
+	if i < 2 || !p.cons(i) || p.cons(i-1) || !p.cons(i-2) {
+		return false
+	}
+	ch := p.b[i]
+	return ch != 'w' && ch != 'x' && ch != 'y'
+}
+
+func (p *porter) ends(s string) bool {
+	l := len(s)
+	if l > p.k+1 || string(p.b[p.k-l+1:p.k+1]) != s {
+		return false
+	}
+	p.j = p.k - l
+	return true
+}
+
+func (p *porter) setTo(s string) {
+	p.b = append(p.b[:p.j+1], s...)
+	p.k = p.j + len(s)
+}
+
+func (p *porter) replace(s string) {
+	if p.m() > 0 {
+		p.setTo(s)
+	}
+}
+
+// replaceFirst replaces the first matching suffix of pairs, given as suffix and replacement.
+func (p *porter) replaceFirst(pairs ...string) {
+	for i := 0; i < len(pairs); i += 2 {
+		if p.ends(pairs[i]) {
+			p.replace(pairs[i+1])
+			return
+		}
+	}
+}
+
+// step1ab removes plurals and -ed or -ing.
+func (p *porter) step1ab() {
+	if p.b[p.k] == 's' {
+		if p.ends("sses") {
+			p.k -= 2
+		} else if p.ends("ies") {
+			p.setTo("i")
+		} else if p.b[p.k-1] != 's' {
+			p.k--
+		}
+	}
+	if p.ends("eed") {
+		if p.m() > 0 {
+			p.k--
+		}
+	} else if (p.ends("ed") || p.ends("ing")) && p.vowelInStem() {
+		p.k = p.j
+		switch {
+		case p.ends("at"):
+			p.setTo("ate")
+		case p.ends("bl"):
+			p.setTo("ble")
+		case p.ends("iz"):
+			p.setTo("ize")
+		case p.doubleCons(p.k):
+			p.k--
+			if ch := p.b[p.k]; ch == 'l' || ch == 's' || ch == 'z' {
+				p.k++
+			}
+		default:
+			p.j = p.k
+			if p.m() == 1 && p.cvc(p.k) {
+				p.setTo("e")
+			}
+		}
+	}
+}
+
+// step1c turns a terminal y into i when there is another vowel in the stem.
+func (p *porter) step1c() {
+	if p.ends("y") && p.vowelInStem() {
+		p.b[p.k] = 'i'
+	}
+}
+
+// step2 maps double suffixes to single ones.
+func (p *porter) step2() {
+	switch p.b[p.k-1] {
+	case 'a':
+		p.replaceFirst("ational", "ate", "tional", "tion")
+	case 'c':
+		p.replaceFirst("enci", "ence", "anci", "ance")
+	case 'e':
+		p.replaceFirst("izer", "ize")
+	case 'l':
+		p.replaceFirst("bli", "ble", "alli", "al", "entli", "ent", "eli", "e", "ousli", "ous")
+	case 'o':
+		p.replaceFirst("ization", "ize", "ation", "ate", "ator", "ate")
+	case 's':
+		p.replaceFirst("alism", "al", "iveness", "ive", "fulness", "ful", "ousness", "ous")
+	case 't':
+		p.replaceFirst("aliti", "al", "iviti", "ive", "biliti", "ble")
+	case 'g':
+		p.replaceFirst("logi", "log")
+	}
+}
+
+// step3 deals with -ic-, -full, -ness and similar.
+func (p *porter) step3() {
+	switch p.b[p.k] {
+	case 'e':
+		p.replaceFirst("icate", "ic", "ative", "", "alize", "al")
+	case 'i':
+		p.replaceFirst("iciti", "ic")
+	case 'l':
+		p.replaceFirst("ical", "ic", "ful", "")
+	case 's':
+		p.replaceFirst("ness", "")
+	}
+}
+
+// step4 removes -ant, -ence and similar in context <c>vcvc<v>.
+func (p *porter) step4() {
+	if p.k < 1 {
+		return
+	}
+
+	matched := false
+	switch p.b[p.k-1] {
+	case 'a':
+		matched = p.ends("al")
+	case 'c':
+		matched = p.ends("ance") || p.ends("ence")
+	case 'e':
+		matched = p.ends("er")
+	case 'i':
+		matched = p.ends("ic")
+	case 'l':
+		matched = p.ends("able") || p.ends("ible")
+	case 'n':
+		matched = p.ends("ant") || p.ends("ement") || p.ends("ment") || p.ends("ent")
+	case 'o':
+		matched = p.ends("ion") && p.j >= 0 && (p.b[p.j] == 's' || p.b[p.j] == 't') || p.ends("ou")
+	case 's':
+		matched = p.ends("ism")
+	case 't':
+		matched = p.ends("ate") || p.ends("iti")
+	case 'u':
+		matched = p.ends("ous")
+	case 'v':
+		matched = p.ends("ive")
+	case 'z':
+		matched = p.ends("ize")
+	}
+	if matched && p.m() > 1 {
+		p.k = p.j
+	}
+}
+
+// step5 removes a final -e and reduces -ll to -l where the stem is long enough.
+func (p *porter) step5() {
+	p.j = p.k
+	if p.b[p.k] == 'e' {
+		a := p.m()
+		if a > 1 || a == 1 && !p.cvc(p.k-1) {
+			p.k--
+		}
+	}
+	if p.b[p.k] == 'l' && p.doubleCons(p.k) && p.m() > 1 {
+		p.k--
+	}
+}
@@ -0,0 +1,128 @@
+package search_service
+
+import (
+	"slices"
+)
+
+// Endings of the Snowball Russian stemmer. The "preceded" groups only match after а or я,
+// which stay part of the stem.
+var (
+	perfectiveGerundPreceded = russianEndings("в", "вши", "вшись")
+	perfectiveGerund         = russianEndings("ив", "ивши", "ившись", "ыв", "ывши", "ывшись")
+	adjective                = russianEndings("ее", "ие", "ые", "ое", "ими", "ыми", "ей", "ий", "ый", "ой", "ем", "им",
+		"ым", "ом", "его", "ого", "ему", "ому", "их", "ых", "ую", "юю", "ая", "яя", "ою", "ею")
+	participlePreceded = russianEndings("ем", "нн", "вш", "ющ", "щ")
+	participle         = russianEndings("ивш", "ывш", "ующ")
+	reflexive          = russianEndings("ся", "сь")
+	verbPreceded       = russianEndings("ла", "на", "ете", "йте", "ли", "й", "л", "ем", "н", "ло", "но", "ет", "ют",
+		"ны", "ть", "ешь", "нно")
+	verb = russianEndings("ила", "ыла", "ена", "ейте", "уйте", "ите", "или", "ыли", "ей", "уй", "ил", "ыл", "им", "ым",
+		"ен", "ило", "ыло", "ено", "ят", "ует", "уют", "ит", "ыт", "ены", "ить", "ыть", "ишь", "ую", "ю")
+	noun = russianEndings("а", "ев", "ов", "ие", "ье", "е", "иями", "ями", "ами", "еи", "ии", "и", "ией", "ей", "ой",
+		"ий", "й", "иям", "ям", "ием", "ем", "ам", "ом", "о", "у", "ах", "иях", "ях", "ы", "ь", "ию", "ью", "ю", "ия",
+		"ья", "я")
+	superlative   = russianEndings("ейш", "ейше")
+	derivational  = russianEndings("ост", "ость")
+	russianVowels = []rune("аеиоуыэюя")
+)
+
+// stemRussian implements the Snowball Russian stemmer for lower-case words where ё is already е.
+// Words with other letters are returned unchanged.
+func stemRussian(word string) string {
+	w := []rune(word)
+	for _, ch := range w {
+		if ch < 'а' || ch > 'я' {
+			return word
+		}
+	}
+
+	rv := len(w)
+	for i, ch := range w {
+		if isRussianVowel(ch) {
+			rv = i + 1
+			break
+		}
+	}
+	r2 := russianRegion(w, russianRegion(w, 0))
+
+	// Step 1.
+	var ok bool
+	if w, ok = removeLongest(w, rv, perfectiveGerundPreceded, perfectiveGerund); !ok {
+		w, _ = removeLongest(w, rv, nil, reflexive)
+		if w, ok = removeLongest(w, rv, nil, adjective); ok {
+			w, _ = removeLongest(w, rv, participlePreceded, participle)
+		} else if w, ok = removeLongest(w, rv, verbPreceded, verb); !ok {
+			w, _ = removeLongest(w, rv, nil, noun)
+		}
+	}
+
+	// Step 2.
+	w, _ = removeLongest(w, rv, nil, russianEndings("и"))
+
+	// Step 3.
+	w, _ = removeLongest(w, r2, nil, derivational)
+
+	// Step 4.
+	if trimmed, ok := removeLongest(w, rv, nil, russianEndings("нн")); ok {
+		w = append(trimmed, 'н')
+	} else if trimmed, ok := removeLongest(w, rv, nil, superlative); ok {
+		w = trimmed
+		if trimmed, ok := removeLongest(w, rv, nil, russianEndings("нн")); ok {
+			w = append(trimmed, 'н')
+		}
+	} else {
+		w, _ = removeLongest(w, rv, nil, russianEndings("ь"))
+	}
+
+	return string(w)
+}
+
+// removeLongest removes the longest of the endings lying after position region. Endings of
+// preceded must also follow а or я within the region.
+func removeLongest(w []rune, region int, preceded, endings [][]rune) ([]rune, bool) {
+	longest := 0
+	for _, ending := range preceded {
+		start := len(w) - len(ending)
+		if len(ending) > longest && start-1 >= region && hasSuffix(w, ending) && (w[start-1] == 'а' || w[start-1] == 'я') {
+			longest = len(ending)
+		}
+	}
+	for _, ending := range endings {
+		if len(ending) > longest && len(w)-len(ending) >= region && hasSuffix(w, ending) {
+			longest = len(ending)
+		}
+	}
+	if longest == 0 {
+		return w, false
+	}
+
+	return w[:len(w)-longest], true
+}
+
+// russianRegion returns the position after the first non-vowel following a vowel, starting at from.
+func russianRegion(w []rune, from int) int {
+	for i := from + 1; i < len(w); i++ {
+		if !isRussianVowel(w[i]) && isRussianVowel(w[i-1]) {
+			return i + 1
+		}
+	}
+
+	return len(w)
+}
+
+func isRussianVowel(ch rune) bool {
+	return slices.Contains(russianVowels, ch)
+}
+
+func hasSuffix(w []rune, suffix []rune) bool {
+	return len(w) >= len(suffix) && slices.Equal(w[len(w)-len(suffix):], suffix)
+}
+
+func russianEndings(endings ...string) [][]rune {
+	result := make([][]rune, 0, len(endings))
+	for _, ending := range endings {
+		result = append(result, []rune(ending))
+	}
+
+	return result
+}
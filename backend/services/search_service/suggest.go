@@ -0,0 +1,316 @@
+package search_service
+
+import (
+	"github.com/google/uuid"
+	"main.go/schemas"
+	"slices"
+	"strings"
+	"sync"
+)
+
+const (
+	// minSuggestPrefix is the shortest folded prefix that gets completions. It is also the length
+	// of the bucket keys, so that writes only shift the keys sharing their first letters.
+	minSuggestPrefix = 2
+	// maxSuggestScan bounds how many keys of each kind are ranked for a short, common prefix.
+	maxSuggestScan = 500
+	// maxKeyWords bounds the words of a key, so that long titles do not blow up the structure.
+	maxKeyWords = 6
+)
+
+// suggestKey is the folded text of an entry from one of its words on.
+type suggestKey struct {
+	key string
+	id  uuid.UUID
+	// start tells that the key starts at the first word of the text.
+	start bool
+}
+
+type suggestEntry struct {
+	kind string
+	text string
+	keys []string
+}
+
+var suggestionKinds = []string{schemas.SuggestionTitle, schemas.SuggestionAuthor, schemas.SuggestionCategory}
+
+// Suggester completes prefixes of book titles, author names and category names. Every word
+// of an entry starts a key, so "peace" completes "War and Peace". Keys are kept sorted in
+// buckets by kind and first letters, which makes a lookup a binary search within a few buckets.
+//
+// Entries added before Build are only collected, so that loading the catalog does not pay
+// for keeping the buckets sorted.
+type Suggester struct {
+	mu      sync.RWMutex
+	built   bool
+	buckets map[string][]suggestKey
+	entries map[uuid.UUID]suggestEntry
+	// weights counts the books of authors and categories, which ranks them.
+	weights map[uuid.UUID]int
+	// books holds the authors and categories counted for every book.
+	books map[uuid.UUID][]uuid.UUID
+}
+
+func NewSuggester() *Suggester {
+	return &Suggester{
+		buckets: make(map[string][]suggestKey),
+		entries: make(map[uuid.UUID]suggestEntry),
+		weights: make(map[uuid.UUID]int),
+		books:   make(map[uuid.UUID][]uuid.UUID),
+	}
+}
+
+// Build sorts the entries added so far. Suggest must not be called before.
+func (r *Suggester) Build() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, bucket := range r.buckets {
+		slices.SortFunc(bucket, compareKeys)
+	}
+	r.built = true
+}
+
+// SetBook updates the title of the book and the authors and categories it counts for.
+// Its authors and categories must be loaded.
+func (r *Suggester) SetBook(book *schemas.Book) {
+	refs := make([]uuid.UUID, 0, len(book.Authors)+len(book.Categories))
+	for _, category := range book.Categories {
+		refs = append(refs, category.ID)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.unlink(book.ID)
+	r.set(book.ID, schemas.SuggestionTitle, book.Name)
+	for _, author := range book.Authors {
+		if !slices.Contains(refs, author.ID) {
+			refs = append(refs, author.ID)
+		}
+		r.set(author.ID, schemas.SuggestionAuthor, author.Name)
+	}
+	for _, id := range refs {
+		r.weights[id]++
+	}
+	r.books[book.ID] = refs
+}
+
+func (r *Suggester) RemoveBook(bookId uuid.UUID) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.unlink(bookId)
+	r.remove(bookId)
+	delete(r.books, bookId)
+}
+
+// SetCategory updates the name of the category and makes it count exactly the given books.
+// Books the suggester does not know are skipped.
+func (r *Suggester) SetCategory(category *schemas.Category, bookIds []uuid.UUID) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.set(category.ID, schemas.SuggestionCategory, category.Name)
+	linked := make(map[uuid.UUID]bool, len(bookIds))
+	for _, bookId := range bookIds {
+		linked[bookId] = true
+	}
+	for bookId, refs := range r.books {
+		counted := slices.Contains(refs, category.ID)
+		switch {
+		case linked[bookId] && !counted:
+			r.books[bookId] = append(refs, category.ID)
+			r.weights[category.ID]++
+		case !linked[bookId] && counted:
+			r.books[bookId] = slices.DeleteFunc(refs, func(id uuid.UUID) bool { return id == category.ID })
+			r.weights[category.ID]--
+		}
+	}
+	if r.weights[category.ID] <= 0 {
+		delete(r.weights, category.ID)
+	}
+}
+
+// RemoveCategory drops the category and stops counting books for it.
+func (r *Suggester) RemoveCategory(id uuid.UUID) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.remove(id)
+	for bookId, refs := range r.books {
+		r.books[bookId] = slices.DeleteFunc(refs, func(ref uuid.UUID) bool { return ref == id })
+	}
+	delete(r.weights, id)
+}
+
+// Replace swaps in the entries of other, which must be built and not be used afterwards.
+func (r *Suggester) Replace(other *Suggester) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.built, r.buckets, r.entries, r.weights, r.books = other.built, other.buckets, other.entries, other.weights, other.books
+}
+
+// Suggest returns at most limit entries completing prefix. Entries whose text starts with the
+// prefix come before those matching from a later word, then those with more books, then shorter ones.
+func (r *Suggester) Suggest(prefix string, limit int) []schemas.Suggestion {
+	words := wordPattern.FindAllString(prefix, -1)
+	if len(words) == 0 {
+		return []schemas.Suggestion{}
+	}
+	folded := make([]string, len(words))
+	for i, word := range words {
+		folded[i] = fold(word)
+	}
+	// The last word may be cut anywhere, so drop a trailing i that folding may have made of y or j.
+	last := folded[len(folded)-1]
+	if len(last) > 1 && strings.HasSuffix(last, "i") {
+		folded[len(folded)-1] = last[:len(last)-1]
+	}
+	key := strings.Join(folded, " ")
+	if len([]rune(key)) < minSuggestPrefix {
+		return []schemas.Suggestion{}
+	}
+
+	type candidate struct {
+		id     uuid.UUID
+		start  bool
+		weight int
+		entry  suggestEntry
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	positions := make(map[uuid.UUID]int)
+	var candidates []candidate
+	for _, kind := range suggestionKinds {
+		bucket := r.buckets[bucketOf(kind, key)]
+		first, _ := slices.BinarySearchFunc(bucket, key, func(k suggestKey, key string) int {
+			return strings.Compare(k.key, key)
+		})
+		for i := first; i < len(bucket) && i-first < maxSuggestScan && strings.HasPrefix(bucket[i].key, key); i++ {
+			if position, ok := positions[bucket[i].id]; ok {
+				candidates[position].start = candidates[position].start || bucket[i].start
+				continue
+			}
+			positions[bucket[i].id] = len(candidates)
+			candidates = append(candidates, candidate{id: bucket[i].id, start: bucket[i].start,
+				weight: r.weights[bucket[i].id], entry: r.entries[bucket[i].id]})
+		}
+	}
+
+	slices.SortFunc(candidates, func(a, b candidate) int {
+		if a.start != b.start {
+			if a.start {
+				return -1
+			}
+			return 1
+		}
+		if a.weight != b.weight {
+			return b.weight - a.weight
+		}
+		if len(a.entry.text) != len(b.entry.text) {
+			return len(a.entry.text) - len(b.entry.text)
+		}
+		return strings.Compare(a.entry.text, b.entry.text)
+	})
+
+	suggestions := make([]schemas.Suggestion, 0, min(limit, len(candidates)))
+	for i := 0; i < len(candidates) && i < limit; i++ {
+		suggestions = append(suggestions, schemas.Suggestion{Kind: candidates[i].entry.kind, ID: candidates[i].id,
+			Text: candidates[i].entry.text})
+	}
+
+	return suggestions
+}
+
+// set must be called with mu held.
+func (r *Suggester) set(id uuid.UUID, kind, text string) {
+	if entry, ok := r.entries[id]; ok && entry.text == text {
+		return
+	}
+	r.remove(id)
+
+	words := wordPattern.FindAllString(text, -1)
+	folded := make([]string, len(words))
+	for i, word := range words {
+		folded[i] = fold(word)
+	}
+	entry := suggestEntry{kind: kind, text: text}
+	for i := range folded {
+		key := strings.Join(folded[i:min(i+maxKeyWords, len(folded))], " ")
+		if len([]rune(key)) < minSuggestPrefix {
+			continue
+		}
+		bucket := bucketOf(kind, key)
+		item := suggestKey{key: key, id: id, start: i == 0}
+		if r.built {
+			position, _ := slices.BinarySearchFunc(r.buckets[bucket], item, compareKeys)
+			r.buckets[bucket] = slices.Insert(r.buckets[bucket], position, item)
+		} else {
+			r.buckets[bucket] = append(r.buckets[bucket], item)
+		}
+		entry.keys = append(entry.keys, key)
+	}
+	r.entries[id] = entry
+}
+
+// remove must be called with mu held.
+func (r *Suggester) remove(id uuid.UUID) {
+	entry, ok := r.entries[id]
+	if !ok {
+		return
+	}
+
+	for i, key := range entry.keys {
+		bucket := bucketOf(entry.kind, key)
+		item := suggestKey{key: key, id: id, start: i == 0}
+		position, found := slices.BinarySearchFunc(r.buckets[bucket], item, compareKeys)
+		if !r.built {
+			position = slices.Index(r.buckets[bucket], item)
+			found = position >= 0
+		}
+		if found {
+			r.buckets[bucket] = slices.Delete(r.buckets[bucket], position, position+1)
+		}
+		if len(r.buckets[bucket]) == 0 {
+			delete(r.buckets, bucket)
+		}
+	}
+	delete(r.entries, id)
+}
+
+// unlink stops counting the book for its authors and categories and drops authors left
+// without books. It must be called with mu held.
+func (r *Suggester) unlink(bookId uuid.UUID) {
+	for _, id := range r.books[bookId] {
+		r.weights[id]--
+		if r.weights[id] > 0 {
+			continue
+		}
+		delete(r.weights, id)
+		if r.entries[id].kind == schemas.SuggestionAuthor {
+			r.remove(id)
+		}
+	}
+	r.books[bookId] = nil
+}
+
+func compareKeys(a, b suggestKey) int {
+	if c := strings.Compare(a.key, b.key); c != 0 {
+		return c
+	}
+	if a.start != b.start {
+		if a.start {
+			return -1
+		}
+		return 1
+	}
+	return strings.Compare(a.id.String(), b.id.String())
+}
+
+func bucketOf(kind, key string) string {
+	return kind + ":" + string([]rune(key)[:minSuggestPrefix])
+}
@@ -0,0 +1,110 @@
+package search_service
+
+import (
+	"fmt"
+	"github.com/google/uuid"
+	"main.go/schemas"
+	"math/rand"
+	"slices"
+	"testing"
+)
+
+func suggestionTexts(suggestions []schemas.Suggestion) []string {
+	texts := make([]string, 0, len(suggestions))
+	for _, suggestion := range suggestions {
+		texts = append(texts, suggestion.Text)
+	}
+	return texts
+}
+
+func TestSuggesterRanksStartAndWeight(t *testing.T) {
+	tolstoy := schemas.AuthorRef{ID: uuid.New(), Name: "Leo Tolstoy", Role: schemas.AuthorRoleAuthor}
+	suggester := NewSuggester()
+	suggester.SetBook(&schemas.Book{ID: uuid.New(), Name: "War and Peace", Authors: []schemas.AuthorRef{tolstoy}})
+	suggester.SetBook(&schemas.Book{ID: uuid.New(), Name: "Anna Karenina", Authors: []schemas.AuthorRef{tolstoy}})
+	suggester.SetBook(&schemas.Book{ID: uuid.New(), Name: "Peace Talks"})
+	suggester.Build()
+
+	got := suggestionTexts(suggester.Suggest("pea", 10))
+	want := []string{"Peace Talks", "War and Peace"}
+	if !slices.Equal(got, want) {
+		t.Fatalf("Suggest(pea) = %q, want %q", got, want)
+	}
+
+	got = suggestionTexts(suggester.Suggest("Толс", 10))
+	if !slices.Equal(got, []string{"Leo Tolstoy"}) {
+		t.Fatalf("Suggest(Толс) = %q, want the author", got)
+	}
+
+	if got := suggester.Suggest("w", 10); len(got) != 0 {
+		t.Fatalf("Suggest(w) = %q, want nothing below the minimum prefix", got)
+	}
+}
+
+func TestSuggesterCategories(t *testing.T) {
+	fiction := &schemas.Category{ID: uuid.New(), Name: "Fiction"}
+	fantasy := &schemas.Category{ID: uuid.New(), Name: "Fantasy"}
+	books := []*schemas.Book{
+		{ID: uuid.New(), Name: "Dune", Categories: []schemas.CategoryRef{{ID: fiction.ID}}},
+		{ID: uuid.New(), Name: "Emma", Categories: []schemas.CategoryRef{{ID: fiction.ID}}},
+		{ID: uuid.New(), Name: "Hobbit", Categories: []schemas.CategoryRef{{ID: fantasy.ID}}},
+	}
+	suggester := NewSuggester()
+	suggester.SetCategory(fiction, nil)
+	suggester.SetCategory(fantasy, nil)
+	for _, book := range books {
+		suggester.SetBook(book)
+	}
+	suggester.Build()
+
+	if got := suggestionTexts(suggester.Suggest("f", 10)); len(got) != 0 {
+		t.Fatalf("Suggest(f) = %q, want nothing", got)
+	}
+	got := suggestionTexts(suggester.Suggest("fa", 10))
+	if !slices.Equal(got, []string{"Fantasy"}) {
+		t.Fatalf("Suggest(fa) = %q, want Fantasy", got)
+	}
+
+	// Merging fiction into fantasy relinks its books and removes it.
+	suggester.RemoveCategory(fiction.ID)
+	suggester.SetCategory(fantasy, []uuid.UUID{books[0].ID, books[1].ID, books[2].ID, uuid.New()})
+	if got := suggestionTexts(suggester.Suggest("fi", 10)); len(got) != 0 {
+		t.Fatalf("Suggest(fi) = %q after removing Fiction", got)
+	}
+	if suggester.weights[fantasy.ID] != 3 {
+		t.Fatalf("Fantasy counts %d books, want 3", suggester.weights[fantasy.ID])
+	}
+
+	suggester.SetCategory(&schemas.Category{ID: fantasy.ID, Name: "Fairy Tales"}, []uuid.UUID{books[2].ID})
+	got = suggestionTexts(suggester.Suggest("fa", 10))
+	if !slices.Equal(got, []string{"Fairy Tales"}) || suggester.weights[fantasy.ID] != 1 {
+		t.Fatalf("Suggest(fa) = %q with %d books after the rename, want Fairy Tales with 1",
+			got, suggester.weights[fantasy.ID])
+	}
+}
+
+// BenchmarkSuggest completes prefixes against a catalog of 50,000 books by 5,000 authors.
+func BenchmarkSuggest(b *testing.B) {
+	words := []string{"war", "peace", "night", "garden", "river", "shadow", "silver", "winter", "house",
+		"stone", "crime", "city", "ocean", "empire", "secret", "letter", "mountain", "glass", "fire", "song"}
+	random := rand.New(rand.NewSource(1))
+	authors := make([]schemas.AuthorRef, 5000)
+	for i := range authors {
+		authors[i] = schemas.AuthorRef{ID: uuid.New(), Name: fmt.Sprintf("Author %s %d", words[i%len(words)], i),
+			Role: schemas.AuthorRoleAuthor}
+	}
+	suggester := NewSuggester()
+	for i := 0; i < 50000; i++ {
+		name := fmt.Sprintf("The %s of the %s %s %d", words[random.Intn(len(words))], words[random.Intn(len(words))],
+			words[random.Intn(len(words))], i)
+		suggester.SetBook(&schemas.Book{ID: uuid.New(), Name: name,
+			Authors: []schemas.AuthorRef{authors[random.Intn(len(authors))]}})
+	}
+	suggester.Build()
+
+	prefixes := []string{"th", "the se", "war", "silver ri", "author ga", "mountain 12", "zz"}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		suggester.Suggest(prefixes[i%len(prefixes)], 10)
+	}
+}
@@ -0,0 +1,74 @@
+package search_service
+
+import (
+	"golang.org/x/text/unicode/norm"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+var cyrillicToLatin = map[rune]string{
+	'а': "a", 'б': "b", 'в': "v", 'г': "g", 'д': "d", 'е': "e", 'ж': "zh", 'з': "z", 'и': "i", 'к': "k",
+	'л': "l", 'м': "m", 'н': "n", 'о': "o", 'п': "p", 'р': "r", 'с': "s", 'т': "t", 'у': "u", 'ф': "f",
+	'х': "kh", 'ц': "ts", 'ч': "ch", 'ш': "sh", 'щ': "shch", 'ъ': "", 'ы': "y", 'ь': "", 'э': "e",
+	'ю': "yu", 'я': "ya",
+}
+
+// latinVariants brings the common romanizations of Russian to one spelling, so that
+// "Dostoyevskiy", "Dostoevsky" and the transliterated "Достоевский" meet.
+var latinVariants = strings.NewReplacer(
+	"shch", "sch", "kh", "h", "tz", "ts", "x", "ks",
+	"ja", "ia", "ya", "ia", "ju", "iu", "yu", "iu",
+	"je", "e", "ye", "e", "jo", "e", "yo", "e",
+)
+
+// foldDiacritics strips combining marks, turning é into e, and й and ё into и and е.
+func foldDiacritics(word string) string {
+	ascii := true
+	for i := 0; i < len(word) && ascii; i++ {
+		ascii = word[i] < utf8.RuneSelf
+	}
+	if ascii {
+		return word
+	}
+
+	stripped := strings.Map(func(ch rune) rune {
+		if unicode.Is(unicode.Mn, ch) {
+			return -1
+		}
+		return ch
+	}, norm.NFD.String(word))
+
+	return norm.NFC.String(stripped)
+}
+
+// transliterate romanizes the Cyrillic letters of a folded lower-case word.
+func transliterate(word string) string {
+	var builder strings.Builder
+	for _, ch := range word {
+		if latin, ok := cyrillicToLatin[ch]; ok {
+			builder.WriteString(latin)
+		} else {
+			builder.WriteRune(ch)
+		}
+	}
+
+	return builder.String()
+}
+
+// foldLatin unifies romanization variants, turns a final y or j into i and squeezes repeated letters.
+func foldLatin(word string) string {
+	word = latinVariants.Replace(word)
+	if strings.HasSuffix(word, "y") || strings.HasSuffix(word, "j") {
+		word = word[:len(word)-1] + "i"
+	}
+
+	squeezed := make([]rune, 0, len(word))
+	for _, ch := range word {
+		if len(squeezed) == 0 || squeezed[len(squeezed)-1] != ch || unicode.IsDigit(ch) {
+			squeezed = append(squeezed, ch)
+		}
+	}
+
+	return string(squeezed)
+}
@@ -0,0 +1,76 @@
+package session_service
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+	"gorm.io/gorm"
+	"main.go/repositories/audit_repository"
+	"main.go/repositories/token_repository"
+	"main.go/repositories/user_repository"
+	"main.go/schemas"
+	"time"
+)
+
+type Service struct {
+	tokenRepository *token_repository.Repository
+	userRepository  *user_repository.Repository
+	auditRepository *audit_repository.Repository
+}
+
+func NewService(tokenRepository *token_repository.Repository, userRepository *user_repository.Repository,
+	auditRepository *audit_repository.Repository) *Service {
+	return &Service{tokenRepository: tokenRepository, userRepository: userRepository,
+		auditRepository: auditRepository}
+}
+
+// ListSessions returns the user's active sessions. The one with id currentId is marked as current.
+func (r *Service) ListSessions(ctx context.Context, userId, currentId uuid.UUID, page int, pageSize int) (*[]schemas.Session, error) {
+	_, err := r.userRepository.GetUserById(ctx, userId)
+	if err != nil {
+		return nil, errors.Wrap(err, "list sessions")
+	}
+
+	sessions, err := r.tokenRepository.GetUserSessions(ctx, userId, time.Now().UTC(), page, pageSize)
+	if err != nil {
+		return nil, errors.Wrap(err, "list sessions")
+	}
+	for i := range *sessions {
+		(*sessions)[i].Current = (*sessions)[i].ID == currentId
+	}
+
+	return sessions, nil
+}
+
+// RevokeSession signs the user out of one session. Its access token stops working at once
+// and its refresh token can no longer be rotated. Revoking another user's session is audited.
+func (r *Service) RevokeSession(ctx context.Context, actorId, userId, sessionId uuid.UUID) error {
+	now := time.Now().UTC()
+	revoked, err := r.tokenRepository.RevokeSession(ctx, sessionId, userId, now)
+	if err != nil {
+		return errors.Wrap(err, "revoke session")
+	}
+	if !revoked {
+		return gorm.ErrRecordNotFound
+	}
+
+	err = r.tokenRepository.RevokeRefreshTokenFamily(ctx, sessionId, now)
+	if err != nil {
+		return errors.Wrap(err, "revoke session")
+	}
+
+	if actorId != userId {
+		err = r.auditRepository.Record(ctx, actorId, schemas.AuditSessionRevoked, userId,
+			map[string]interface{}{"sessionId": sessionId})
+		if err != nil {
+			return errors.Wrap(err, "revoke session")
+		}
+	}
+
+	zerolog.Ctx(ctx).Info().Str("userId", userId.String()).
+		Str("actorId", actorId.String()).
+		Str("sessionId", sessionId.String()).
+		Msg("session.revoked")
+	return nil
+}
@@ -0,0 +1,207 @@
+package signing_key_service
+
+import (
+	"context"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+	"main.go/repositories/audit_repository"
+	"main.go/repositories/signing_key_repository"
+	"main.go/schemas"
+	"main.go/utils/settings_utils"
+	"main.go/utils/signing_utils"
+	"time"
+)
+
+// expiredKeyRetention keeps expired keys around for a day before deleting them, which helps when investigating.
+const expiredKeyRetention = 24 * time.Hour
+
+type Service struct {
+	repository      *signing_key_repository.Repository
+	auditRepository *audit_repository.Repository
+	keyring         *signing_utils.Keyring
+}
+
+func NewService(repository *signing_key_repository.Repository, auditRepository *audit_repository.Repository,
+	keyring *signing_utils.Keyring) *Service {
+	return &Service{repository: repository, auditRepository: auditRepository, keyring: keyring}
+}
+
+// Bootstrap loads the keys and creates the first one if there is nothing to sign with.
+func (r *Service) Bootstrap(ctx context.Context) error {
+	err := r.Reload(ctx)
+	if err != nil {
+		return errors.Wrap(err, "bootstrap signing keys")
+	}
+
+	_, ok := r.keyring.Current(time.Now().UTC())
+	if ok {
+		return nil
+	}
+
+	_, err = r.createKey(ctx, time.Now().UTC(), time.Time{})
+	if err != nil {
+		return errors.Wrap(err, "bootstrap signing keys")
+	}
+
+	return nil
+}
+
+// Reload reads the keys from the database into the keyring.
+func (r *Service) Reload(ctx context.Context) error {
+	stored, err := r.repository.GetSigningKeys(ctx, time.Now().UTC())
+	if err != nil {
+		return errors.Wrap(err, "reload signing keys")
+	}
+
+	keys := make([]signing_utils.Key, 0, len(*stored))
+	for _, key := range *stored {
+		private, err := signing_utils.ParseKey(key.Algorithm, key.PrivateKey)
+		if err != nil {
+			zerolog.Ctx(ctx).Error().Err(err).Str("kid", key.Kid).Msg("signing.key.unreadable")
+			continue
+		}
+
+		keys = append(keys, signing_utils.Key{
+			Kid:       key.Kid,
+			Algorithm: key.Algorithm,
+			Private:   private,
+			NotBefore: key.NotBefore,
+			ExpiresAt: key.ExpiresAt,
+		})
+	}
+	r.keyring.Set(keys)
+
+	return nil
+}
+
+// Rotate publishes a new key right away but signs with it only after SIGNING_KEY_PROPAGATION,
+// giving other instances time to load it. Older keys verify until the tokens they signed expire.
+func (r *Service) Rotate(ctx context.Context, actorId uuid.UUID) (*schemas.SigningKey, error) {
+	key, err := r.createKey(ctx, time.Now().UTC().Add(settings_utils.Settings.SigningKeyPropagation), time.Time{})
+	if err != nil {
+		return nil, errors.Wrap(err, "rotate signing key")
+	}
+
+	if actorId != uuid.Nil {
+		err = r.auditRepository.Record(ctx, actorId, schemas.AuditSigningKeyRotated, uuid.Nil,
+			map[string]interface{}{"kid": key.Kid, "algorithm": key.Algorithm})
+		if err != nil {
+			return nil, errors.Wrap(err, "rotate signing key")
+		}
+	}
+
+	return key, nil
+}
+
+func (r *Service) ListKeys(ctx context.Context, page int, pageSize int) (*[]schemas.SigningKey, error) {
+	keys, err := r.repository.PageSigningKeys(ctx, time.Now().UTC(), page, pageSize)
+	if err != nil {
+		return nil, errors.Wrap(err, "list signing keys")
+	}
+
+	return keys, nil
+}
+
+func (r *Service) Jwks() signing_utils.Jwks {
+	return r.keyring.Jwks()
+}
+
+// Keyfunc verifies access tokens, see signing_utils.Keyring.Keyfunc.
+func (r *Service) Keyfunc(token *jwt.Token) (interface{}, error) {
+	return r.keyring.Keyfunc(token)
+}
+
+// Run reloads keys rotated by other instances and rotates on schedule. It blocks until ctx is cancelled.
+func (r *Service) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			err := r.tick(ctx)
+			if err != nil {
+				zerolog.Ctx(ctx).Error().Err(err).Msg("signing.keys.refresh.failed")
+			}
+		}
+	}
+}
+
+func (r *Service) tick(ctx context.Context) error {
+	now := time.Now().UTC()
+	keys, err := r.repository.GetSigningKeys(ctx, now)
+	if err != nil {
+		return err
+	}
+
+	if len(*keys) == 0 || now.Sub((*keys)[0].NotBefore) >= settings_utils.Settings.SigningKeyRotation {
+		// Other instances may be rotating too; only the first one whose key is due stores it.
+		_, err = r.createKey(ctx, now.Add(settings_utils.Settings.SigningKeyPropagation),
+			now.Add(-settings_utils.Settings.SigningKeyRotation))
+		if err != nil {
+			return err
+		}
+	} else {
+		err = r.Reload(ctx)
+		if err != nil {
+			return err
+		}
+	}
+
+	deleted, err := r.repository.DeleteExpiredSigningKeys(ctx, now.Add(-expiredKeyRetention))
+	if err != nil {
+		return err
+	}
+	if deleted != 0 {
+		zerolog.Ctx(ctx).Info().Int64("amount", deleted).Msg("signing.keys.pruned")
+	}
+
+	return nil
+}
+
+// createKey stores a new key signing from notBefore and reloads the keyring. With due set the key is
+// only stored if the newest key started signing at or before due; nil is returned otherwise.
+func (r *Service) createKey(ctx context.Context, notBefore, due time.Time) (*schemas.SigningKey, error) {
+	algorithm := settings_utils.Settings.SigningAlgorithm
+	privatePem, kid, err := signing_utils.GenerateKey(algorithm)
+	if err != nil {
+		return nil, err
+	}
+
+	key := &schemas.SigningKey{
+		Kid:        kid,
+		Algorithm:  algorithm,
+		PrivateKey: privatePem,
+		NotBefore:  notBefore,
+		CreatedAt:  time.Now().UTC(),
+	}
+	saved, err := r.repository.SaveSigningKey(ctx, key, due)
+	if err != nil {
+		return nil, err
+	}
+	if !saved {
+		return nil, r.Reload(ctx)
+	}
+
+	// Older keys sign until this one starts; tokens they signed live at most the longest token TTL.
+	ttl := max(settings_utils.Settings.JwtTtl, settings_utils.Settings.MfaTokenTtl)
+	err = r.repository.ExpireSigningKeys(ctx, key.Kid, notBefore.Add(ttl+settings_utils.Settings.SigningKeyPropagation))
+	if err != nil {
+		return nil, err
+	}
+
+	err = r.Reload(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	zerolog.Ctx(ctx).Info().Str("kid", kid).
+		Str("algorithm", algorithm).
+		Time("notBefore", notBefore).
+		Msg("signing.key.created")
+	return key, nil
+}
@@ -0,0 +1,402 @@
+package user_service
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+	"gorm.io/gorm"
+	"main.go/repositories/audit_repository"
+	"main.go/repositories/role_repository"
+	"main.go/repositories/token_repository"
+	"main.go/repositories/user_repository"
+	"main.go/schemas"
+	"main.go/utils/password_utils"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Anonymizer unlinks a deleted account from data that has to be kept, such as carts and orders.
+type Anonymizer interface {
+	AnonymizeUser(ctx context.Context, userId uuid.UUID) error
+}
+
+type Service struct {
+	repository      *user_repository.Repository
+	roleRepository  *role_repository.Repository
+	tokenRepository *token_repository.Repository
+	auditRepository *audit_repository.Repository
+	hasher          password_utils.Hasher
+	anonymizers     []Anonymizer
+}
+
+func NewService(repository *user_repository.Repository, roleRepository *role_repository.Repository,
+	tokenRepository *token_repository.Repository, auditRepository *audit_repository.Repository,
+	hasher password_utils.Hasher, anonymizers ...Anonymizer) *Service {
+	return &Service{repository: repository, roleRepository: roleRepository,
+		tokenRepository: tokenRepository, auditRepository: auditRepository,
+		hasher: hasher, anonymizers: anonymizers}
+}
+
+func (r *Service) ListUsers(ctx context.Context, page int, pageSize int, query, status string) (*[]schemas.User, int64, error) {
+	users, total, err := r.repository.ListUsers(ctx, page, pageSize, query, status)
+	if err != nil {
+		return nil, 0, errors.Wrap(err, "list users")
+	}
+
+	userIds := make([]uuid.UUID, 0, len(*users))
+	for _, user := range *users {
+		userIds = append(userIds, user.ID)
+	}
+
+	if len(userIds) != 0 {
+		roles, err := r.roleRepository.GetRoleNamesForUsers(ctx, userIds)
+		if err != nil {
+			return nil, 0, errors.Wrap(err, "list users")
+		}
+		for i := range *users {
+			(*users)[i].Roles = roles[(*users)[i].ID]
+		}
+	}
+
+	zerolog.Ctx(ctx).Info().Int("amount", len(*users)).Int64("total", total).Msg("users.listed")
+	return users, total, nil
+}
+
+func (r *Service) GetUser(ctx context.Context, userId uuid.UUID) (*schemas.User, error) {
+	user, err := r.repository.GetUserById(ctx, userId)
+	if err != nil {
+		return nil, errors.Wrap(err, "get user")
+	}
+
+	roles, err := r.roleRepository.GetUserRoles(ctx, userId)
+	if err != nil {
+		return nil, errors.Wrap(err, "get user")
+	}
+	user.SetRoles(*roles)
+
+	zerolog.Ctx(ctx).Info().Str("userId", userId.String()).Msg("user.found")
+	return user, nil
+}
+
+// SetRoles replaces the user's roles. Access tokens are revoked so that a demotion takes
+// effect immediately; refresh tokens are kept and pick up the new roles on the next refresh.
+func (r *Service) SetRoles(ctx context.Context, actorId, userId uuid.UUID, roleNames []string) error {
+	user, err := r.getLiveUser(ctx, userId)
+	if err != nil {
+		return errors.Wrap(err, "set roles")
+	}
+
+	wanted := make([]schemas.Role, 0, len(roleNames))
+	for _, name := range roleNames {
+		role, err := r.roleRepository.GetRoleByName(ctx, name)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errors.Wrap(ErrUnknownRole, name)
+			}
+			return errors.Wrap(err, "set roles")
+		}
+		wanted = append(wanted, *role)
+	}
+
+	adminRoleId, err := r.heldAdminRole(ctx, user.ID)
+	if err != nil {
+		return errors.Wrap(err, "set roles")
+	}
+	roleIds := make([]uuid.UUID, 0, len(wanted))
+	for _, role := range wanted {
+		roleIds = append(roleIds, role.ID)
+		if adminRoleId != nil && role.ID == *adminRoleId {
+			adminRoleId = nil
+		}
+	}
+
+	applied, err := r.roleRepository.SetUserRoles(ctx, user.ID, roleIds, adminRoleId)
+	if err != nil {
+		return errors.Wrap(err, "set roles")
+	}
+	if !applied {
+		return ErrLastAdmin
+	}
+
+	_, err = r.repository.RevokeTokensBefore(ctx, user.ID, time.Now().UTC())
+	if err != nil {
+		return errors.Wrap(err, "set roles")
+	}
+
+	err = r.auditRepository.Record(ctx, actorId, schemas.AuditUserRolesChanged, user.ID,
+		map[string]interface{}{"roles": roleNames})
+	if err != nil {
+		return errors.Wrap(err, "set roles")
+	}
+
+	zerolog.Ctx(ctx).Info().Str("userId", user.ID.String()).Strs("roles", roleNames).Msg("user.roles.changed")
+	return nil
+}
+
+// SetStatus disables, bans or re-activates a user. Leaving the active status revokes every token at once.
+// The last active administrator cannot be disabled or banned.
+func (r *Service) SetStatus(ctx context.Context, actorId, userId uuid.UUID, req *schemas.UserStatusRequest) error {
+	if actorId == userId {
+		return ErrSelfModification
+	}
+
+	user, err := r.getLiveUser(ctx, userId)
+	if err != nil {
+		return errors.Wrap(err, "set status")
+	}
+
+	var adminRoleId *uuid.UUID
+	if req.Status != schemas.UserStatusActive {
+		adminRoleId, err = r.heldAdminRole(ctx, user.ID)
+		if err != nil {
+			return errors.Wrap(err, "set status")
+		}
+	}
+
+	entry := &schemas.AuditLog{
+		ID:        uuid.New(),
+		ActorId:   actorId,
+		Action:    schemas.AuditUserStatusChanged,
+		TargetId:  user.ID,
+		Details:   map[string]interface{}{"from": user.Status, "to": req.Status, "reason": req.Reason},
+		CreatedAt: time.Now().UTC(),
+	}
+	applied, err := r.repository.SetStatus(ctx, user.ID, req.Status, req.Reason, adminRoleId, entry)
+	if err != nil {
+		return errors.Wrap(err, "set status")
+	}
+	if !applied {
+		return ErrLastAdmin
+	}
+
+	zerolog.Ctx(ctx).Info().Str("userId", user.ID.String()).Str("status", req.Status).Msg("user.status.changed")
+	return nil
+}
+
+func (r *Service) DeleteUser(ctx context.Context, actorId, userId uuid.UUID) error {
+	if actorId == userId {
+		return ErrSelfModification
+	}
+
+	user, err := r.getLiveUser(ctx, userId)
+	if err != nil {
+		return errors.Wrap(err, "delete user")
+	}
+
+	adminRoleId, err := r.heldAdminRole(ctx, user.ID)
+	if err != nil {
+		return errors.Wrap(err, "delete user")
+	}
+
+	applied, err := r.repository.SoftDeleteUser(ctx, user.ID, adminRoleId)
+	if err != nil {
+		return errors.Wrap(err, "delete user")
+	}
+	if !applied {
+		return ErrLastAdmin
+	}
+
+	_, err = r.revokeAll(ctx, user.ID)
+	if err != nil {
+		return errors.Wrap(err, "delete user")
+	}
+
+	err = r.auditRepository.Record(ctx, actorId, schemas.AuditUserDeleted, user.ID,
+		map[string]interface{}{"username": user.Username})
+	if err != nil {
+		return errors.Wrap(err, "delete user")
+	}
+
+	zerolog.Ctx(ctx).Info().Str("userId", user.ID.String()).Msg("user.deleted")
+	return nil
+}
+
+func (r *Service) UpdateProfile(ctx context.Context, userId uuid.UUID, req *schemas.ProfileRequest) (*schemas.User, error) {
+	user, err := r.getLiveUser(ctx, userId)
+	if err != nil {
+		return nil, errors.Wrap(err, "update profile")
+	}
+
+	var email *string
+	if req.Email != nil {
+		normalized := strings.ToLower(strings.TrimSpace(*req.Email))
+		email = &normalized
+	}
+	if req.Username != user.Username {
+		taken, err := r.repository.GetUserByUsername(ctx, req.Username)
+		if err != nil {
+			return nil, errors.Wrap(err, "update profile")
+		}
+		if taken.ID != uuid.Nil {
+			return nil, ErrUsernameTaken
+		}
+	}
+	if email != nil && *email != user.Email {
+		taken, err := r.repository.GetUserByEmail(ctx, *email)
+		if err != nil {
+			return nil, errors.Wrap(err, "update profile")
+		}
+		if taken.ID != uuid.Nil {
+			return nil, ErrEmailTaken
+		}
+	}
+
+	err = r.repository.UpdateProfile(ctx, user.ID, req.Username, email)
+	if err != nil {
+		return nil, errors.Wrap(err, "update profile")
+	}
+
+	zerolog.Ctx(ctx).Info().Str("userId", user.ID.String()).Msg("user.profile.updated")
+	return r.GetUser(ctx, user.ID)
+}
+
+// ChangePassword replaces the password after checking the current one and signs the user
+// out of every session. The caller is expected to issue fresh tokens to the current client.
+func (r *Service) ChangePassword(ctx context.Context, userId uuid.UUID, req *schemas.PasswordChangeRequest) (*schemas.User, error) {
+	user, err := r.getLiveUser(ctx, userId)
+	if err != nil {
+		return nil, errors.Wrap(err, "change password")
+	}
+
+	err = r.verifyPassword(user, req.CurrentPassword)
+	if err != nil {
+		return nil, err
+	}
+
+	passwordHash, err := r.hasher.Hash(req.NewPassword)
+	if err != nil {
+		return nil, errors.Wrap(err, "change password")
+	}
+
+	err = r.repository.UpdatePasswordHash(ctx, user.ID, passwordHash)
+	if err != nil {
+		return nil, errors.Wrap(err, "change password")
+	}
+
+	// The caller issues new tokens to the returned user; they are stamped after the cutoff.
+	user.TokensRevokedAt, err = r.revokeAll(ctx, user.ID)
+	if err != nil {
+		return nil, errors.Wrap(err, "change password")
+	}
+
+	zerolog.Ctx(ctx).Info().Str("userId", user.ID.String()).Msg("user.password.changed")
+	return user, nil
+}
+
+// DeleteAccount anonymizes the account instead of removing it, so that carts and orders stay consistent.
+func (r *Service) DeleteAccount(ctx context.Context, userId uuid.UUID, password string) error {
+	user, err := r.getLiveUser(ctx, userId)
+	if err != nil {
+		return errors.Wrap(err, "delete account")
+	}
+
+	err = r.verifyPassword(user, password)
+	if err != nil {
+		return err
+	}
+
+	adminRoleId, err := r.heldAdminRole(ctx, user.ID)
+	if err != nil {
+		return errors.Wrap(err, "delete account")
+	}
+
+	applied, err := r.roleRepository.SetUserRoles(ctx, user.ID, nil, adminRoleId)
+	if err != nil {
+		return errors.Wrap(err, "delete account")
+	}
+	if !applied {
+		return ErrLastAdmin
+	}
+
+	for _, anonymizer := range r.anonymizers {
+		err = anonymizer.AnonymizeUser(ctx, user.ID)
+		if err != nil {
+			return errors.Wrap(err, "delete account")
+		}
+	}
+
+	err = r.repository.AnonymizeUser(ctx, user.ID)
+	if err != nil {
+		return errors.Wrap(err, "delete account")
+	}
+
+	_, err = r.revokeAll(ctx, user.ID)
+	if err != nil {
+		return errors.Wrap(err, "delete account")
+	}
+
+	err = r.auditRepository.Record(ctx, user.ID, schemas.AuditUserDeleted, user.ID,
+		map[string]interface{}{"selfService": true})
+	if err != nil {
+		return errors.Wrap(err, "delete account")
+	}
+
+	zerolog.Ctx(ctx).Info().Str("userId", user.ID.String()).Msg("user.account.deleted")
+	return nil
+}
+
+func (r *Service) verifyPassword(user *schemas.User, password string) error {
+	ok, err := r.hasher.Verify(password, user.EncodedPassword())
+	if err != nil {
+		return errors.Wrap(err, "verify password")
+	}
+	if !ok {
+		return ErrWrongPassword
+	}
+
+	return nil
+}
+
+func (r *Service) getLiveUser(ctx context.Context, userId uuid.UUID) (*schemas.User, error) {
+	user, err := r.repository.GetUserById(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+	if !user.DeletedAt.IsZero() {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	return user, nil
+}
+
+// heldAdminRole returns the id of the admin role if the user holds it, and nil otherwise.
+func (r *Service) heldAdminRole(ctx context.Context, userId uuid.UUID) (*uuid.UUID, error) {
+	adminRole, err := r.roleRepository.GetRoleByName(ctx, schemas.RoleAdmin)
+	if err != nil {
+		return nil, err
+	}
+	roles, err := r.roleRepository.GetUserRoles(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+	if !slices.ContainsFunc(*roles, func(role schemas.Role) bool { return role.ID == adminRole.ID }) {
+		return nil, nil
+	}
+
+	return &adminRole.ID, nil
+}
+
+// revokeAll revokes every token, refresh token and session of the user and returns the token cutoff.
+func (r *Service) revokeAll(ctx context.Context, userId uuid.UUID) (time.Time, error) {
+	now := time.Now().UTC()
+	cutoff, err := r.repository.RevokeTokensBefore(ctx, userId, now)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	err = r.tokenRepository.RevokeUserRefreshTokens(ctx, userId, now)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return cutoff, r.tokenRepository.RevokeUserSessions(ctx, userId, now)
+}
+
+var ErrUnknownRole = errors.New("unknown role")
+var ErrSelfModification = errors.New("administrators cannot change their own account here")
+var ErrUsernameTaken = errors.New("username already taken")
+var ErrEmailTaken = errors.New("email already taken")
+var ErrWrongPassword = errors.New("wrong password")
+var ErrLastAdmin = errors.New("cannot remove the last administrator")
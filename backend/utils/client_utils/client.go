@@ -0,0 +1,22 @@
+package client_utils
+
+import "context"
+
+// Client describes who sent the request being served, for recording sessions.
+type Client struct {
+	Ip        string
+	UserAgent string
+	RequestId string
+}
+
+type clientKey struct{}
+
+func WithClient(ctx context.Context, client Client) context.Context {
+	return context.WithValue(ctx, clientKey{}, client)
+}
+
+// FromContext returns the client stored by WithClient, or an empty one.
+func FromContext(ctx context.Context) Client {
+	client, _ := ctx.Value(clientKey{}).(Client)
+	return client
+}
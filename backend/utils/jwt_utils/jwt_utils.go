@@ -2,17 +2,90 @@ package jwt_utils
 
 import (
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 	"github.com/pkg/errors"
+	"math"
+	"time"
 )
 
-func CheckAdmin(token *jwt.Token) error {
+// HasPermission reports whether the perms claim contains permission. Tokens without the claim have no permissions.
+func HasPermission(token *jwt.Token, permission string) bool {
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return false
+	}
+
+	perms, ok := claims["perms"].([]interface{})
+	if !ok {
+		return false
+	}
+
+	for _, perm := range perms {
+		if perm == permission {
+			return true
+		}
+	}
+
+	return false
+}
+
+// GetType returns the typ claim. Regular access tokens have none.
+func GetType(token *jwt.Token) string {
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return ""
+	}
+
+	typ, _ := claims["typ"].(string)
+	return typ
+}
+
+func GetUserId(token *jwt.Token) (uuid.UUID, error) {
+	return getUUIDClaim(token, "sub")
+}
+
+func GetJti(token *jwt.Token) (uuid.UUID, error) {
+	return getUUIDClaim(token, "jti")
+}
+
+// GetSessionId returns the sid claim, which tokens issued before sessions were recorded lack.
+func GetSessionId(token *jwt.Token) (uuid.UUID, error) {
+	return getUUIDClaim(token, "sid")
+}
+
+// GetIssuedAt reads iat with millisecond precision, which jwt.NumericDate would truncate to seconds.
+func GetIssuedAt(token *jwt.Token) (time.Time, error) {
+	claims := token.Claims.(jwt.MapClaims)
+	iat, ok := claims["iat"].(float64)
+	if !ok {
+		return time.Time{}, errors.Wrap(ErrInvalidClaim, "iat")
+	}
+
+	return time.UnixMilli(int64(math.Round(iat * 1000))).UTC(), nil
+}
+
+func GetExpiresAt(token *jwt.Token) (time.Time, error) {
+	exp, err := token.Claims.GetExpirationTime()
+	if err != nil || exp == nil {
+		return time.Time{}, errors.Wrap(ErrInvalidClaim, "exp")
+	}
+
+	return exp.UTC(), nil
+}
+
+func getUUIDClaim(token *jwt.Token, name string) (uuid.UUID, error) {
 	claims := token.Claims.(jwt.MapClaims)
-	admin := claims["admin"].(bool)
-	if !admin {
-		return ErrNotAdmin
+	value, ok := claims[name].(string)
+	if !ok {
+		return uuid.Nil, errors.Wrap(ErrInvalidClaim, name)
+	}
+
+	id, err := uuid.Parse(value)
+	if err != nil {
+		return uuid.Nil, errors.Wrap(ErrInvalidClaim, name)
 	}
 
-	return nil
+	return id, nil
 }
 
-var ErrNotAdmin = errors.New("user is not admin")
+var ErrInvalidClaim = errors.New("invalid token claim")
@@ -0,0 +1,42 @@
+package mail_utils
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"io"
+	"os"
+	"sync"
+)
+
+// FileMailer appends messages to a writer instead of delivering them. It is meant for
+// development and tests.
+type FileMailer struct {
+	mu   sync.Mutex
+	w    io.Writer
+	from string
+}
+
+func NewFileMailer(path, from string) (*FileMailer, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, errors.Wrap(err, "open mail file")
+	}
+
+	return &FileMailer{w: file, from: from}, nil
+}
+
+func NewStdoutMailer(from string) *FileMailer {
+	return &FileMailer{w: os.Stdout, from: from}
+}
+
+func (r *FileMailer) Send(_ context.Context, message *Message) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	err := writeMessage(r.w, r.from, message)
+	if err != nil {
+		return errors.Wrap(err, "write mail")
+	}
+
+	return nil
+}
@@ -0,0 +1,57 @@
+package mail_utils
+
+import (
+	"context"
+	"fmt"
+	"github.com/pkg/errors"
+	"io"
+	"strings"
+	"time"
+)
+
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+type Mailer interface {
+	Send(ctx context.Context, message *Message) error
+}
+
+// NewMailer builds the mailer selected by transport: "smtp", "file" or "stdout".
+func NewMailer(transport, from, smtpHost string, smtpPort uint16, smtpUser, smtpPass, file string) (Mailer, error) {
+	switch transport {
+	case "smtp":
+		return NewSMTPMailer(smtpHost, smtpPort, smtpUser, smtpPass, from), nil
+	case "file":
+		return NewFileMailer(file, from)
+	case "", "stdout":
+		return NewStdoutMailer(from), nil
+	default:
+		return nil, errors.Wrap(ErrUnknownTransport, transport)
+	}
+}
+
+// format renders a plain-text RFC 5322 message.
+func format(from string, message *Message) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", message.To)
+	fmt.Fprintf(&b, "Subject: %s\r\n", message.Subject)
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().UTC().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(message.Body, "\n", "\r\n"))
+	b.WriteString("\r\n")
+
+	return []byte(b.String())
+}
+
+func writeMessage(w io.Writer, from string, message *Message) error {
+	_, err := w.Write(append(format(from, message), []byte("\r\n")...))
+	return err
+}
+
+var ErrUnknownTransport = errors.New("unknown mail transport")
@@ -0,0 +1,36 @@
+package mail_utils
+
+import (
+	"context"
+	"fmt"
+	"github.com/pkg/errors"
+	"net/smtp"
+)
+
+// SMTPMailer delivers through an SMTP relay. Authentication is skipped when no
+// username is configured, which is what local catchers such as Mailpit expect.
+type SMTPMailer struct {
+	addr string
+	host string
+	user string
+	pass string
+	from string
+}
+
+func NewSMTPMailer(host string, port uint16, user, pass, from string) *SMTPMailer {
+	return &SMTPMailer{addr: fmt.Sprintf("%s:%d", host, port), host: host, user: user, pass: pass, from: from}
+}
+
+func (r *SMTPMailer) Send(_ context.Context, message *Message) error {
+	var auth smtp.Auth
+	if r.user != "" {
+		auth = smtp.PlainAuth("", r.user, r.pass, r.host)
+	}
+
+	err := smtp.SendMail(r.addr, auth, r.from, []string{message.To}, format(r.from, message))
+	if err != nil {
+		return errors.Wrap(err, "send smtp mail")
+	}
+
+	return nil
+}
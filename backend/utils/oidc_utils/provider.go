@@ -0,0 +1,235 @@
+package oidc_utils
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"github.com/MicahParks/keyfunc/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/pkg/errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Claims are the ID token claims used to find or create the local user.
+type Claims struct {
+	Subject           string
+	Email             string
+	EmailVerified     bool
+	PreferredUsername string
+	Name              string
+	Nonce             string
+	Groups            []string
+}
+
+type discovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JwksUri               string `json:"jwks_uri"`
+}
+
+// Provider talks to an OpenID Connect identity provider. The discovery document and
+// signing keys are fetched on first use, so that the store starts while the IdP is down.
+type Provider struct {
+	issuer       string
+	clientId     string
+	clientSecret string
+	redirectUrl  string
+	scopes       []string
+	groupsClaim  string
+	client       *http.Client
+
+	mu        sync.Mutex
+	discovery *discovery
+	jwks      *keyfunc.JWKS
+}
+
+func NewProvider(issuer, clientId, clientSecret, redirectUrl string, scopes []string, groupsClaim string) *Provider {
+	return &Provider{
+		issuer:       strings.TrimSuffix(issuer, "/"),
+		clientId:     clientId,
+		clientSecret: clientSecret,
+		redirectUrl:  redirectUrl,
+		scopes:       scopes,
+		groupsClaim:  groupsClaim,
+		client:       &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (r *Provider) Issuer() string {
+	return r.issuer
+}
+
+// NewVerifier returns a PKCE code verifier: 32 random bytes, base64url encoded.
+func NewVerifier() string {
+	verifier := make([]byte, 32)
+	_, _ = rand.Read(verifier)
+
+	return base64.RawURLEncoding.EncodeToString(verifier)
+}
+
+// AuthCodeUrl builds the authorization request the browser is redirected to.
+func (r *Provider) AuthCodeUrl(ctx context.Context, state, nonce, verifier string) (string, error) {
+	config, err := r.load(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	challenge := sha256.Sum256([]byte(verifier))
+	query := url.Values{}
+	query.Set("response_type", "code")
+	query.Set("client_id", r.clientId)
+	query.Set("redirect_uri", r.redirectUrl)
+	query.Set("scope", strings.Join(r.scopes, " "))
+	query.Set("state", state)
+	query.Set("nonce", nonce)
+	query.Set("code_challenge", base64.RawURLEncoding.EncodeToString(challenge[:]))
+	query.Set("code_challenge_method", "S256")
+
+	separator := "?"
+	if strings.Contains(config.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+
+	return config.AuthorizationEndpoint + separator + query.Encode(), nil
+}
+
+// Exchange trades the authorization code for tokens and returns the verified ID token claims.
+func (r *Provider) Exchange(ctx context.Context, code, verifier, nonce string) (*Claims, error) {
+	config, err := r.load(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", r.redirectUrl)
+	form.Set("client_id", r.clientId)
+	form.Set("code_verifier", verifier)
+	if r.clientSecret != "" {
+		form.Set("client_secret", r.clientSecret)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, config.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, errors.Wrap(err, "exchange code")
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	var response struct {
+		IdToken string `json:"id_token"`
+	}
+	err = r.do(req, &response)
+	if err != nil {
+		return nil, errors.Wrap(err, "exchange code")
+	}
+	if response.IdToken == "" {
+		return nil, errors.Wrap(ErrInvalidIdToken, "no id_token in token response")
+	}
+
+	return r.verify(response.IdToken, nonce)
+}
+
+func (r *Provider) verify(idToken, nonce string) (*Claims, error) {
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(idToken, claims, r.jwks.Keyfunc,
+		jwt.WithIssuer(r.discovery.Issuer),
+		jwt.WithAudience(r.clientId),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(time.Minute),
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "ES256", "ES384", "ES512", "PS256", "EdDSA"}))
+	if err != nil {
+		return nil, errors.Wrap(ErrInvalidIdToken, err.Error())
+	}
+
+	result := &Claims{}
+	result.Subject, _ = claims["sub"].(string)
+	result.Email, _ = claims["email"].(string)
+	result.EmailVerified, _ = claims["email_verified"].(bool)
+	result.PreferredUsername, _ = claims["preferred_username"].(string)
+	result.Name, _ = claims["name"].(string)
+	result.Nonce, _ = claims["nonce"].(string)
+	if result.Subject == "" || result.Nonce != nonce {
+		return nil, errors.Wrap(ErrInvalidIdToken, "sub or nonce mismatch")
+	}
+
+	switch groups := claims[r.groupsClaim].(type) {
+	case []interface{}:
+		for _, group := range groups {
+			if name, ok := group.(string); ok {
+				result.Groups = append(result.Groups, name)
+			}
+		}
+	case string:
+		result.Groups = []string{groups}
+	}
+
+	return result, nil
+}
+
+func (r *Provider) load(ctx context.Context) (*discovery, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.discovery != nil {
+		return r.discovery, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, r.issuer+"/.well-known/openid-configuration", nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "discover provider")
+	}
+
+	var config discovery
+	err = r.do(req, &config)
+	if err != nil {
+		return nil, errors.Wrap(err, "discover provider")
+	}
+	if strings.TrimSuffix(config.Issuer, "/") != r.issuer {
+		return nil, errors.Errorf("discovery issuer %q does not match %q", config.Issuer, r.issuer)
+	}
+
+	jwks, err := keyfunc.Get(config.JwksUri, keyfunc.Options{
+		Client:            r.client,
+		RefreshInterval:   time.Hour,
+		RefreshRateLimit:  time.Minute,
+		RefreshTimeout:    10 * time.Second,
+		RefreshUnknownKID: true,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "load provider keys")
+	}
+
+	r.discovery = &config
+	r.jwks = jwks
+	return r.discovery, nil
+}
+
+func (r *Provider) do(req *http.Request, target interface{}) error {
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return errors.Errorf("%s %s: %d %s", req.Method, req.URL.Path, resp.StatusCode, body)
+	}
+
+	return json.Unmarshal(body, target)
+}
+
+var ErrInvalidIdToken = errors.New("invalid id token")
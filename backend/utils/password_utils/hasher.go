@@ -0,0 +1,79 @@
+package password_utils
+
+import (
+	"github.com/pkg/errors"
+	"strings"
+)
+
+// Hasher produces self-describing encoded hashes, so the algorithm and its
+// parameters can be recovered from the stored value alone.
+type Hasher interface {
+	Hash(password string) (string, error)
+	Verify(password, encoded string) (bool, error)
+	// Supports reports whether encoded was produced by this algorithm.
+	Supports(encoded string) bool
+	// NeedsRehash reports whether encoded uses weaker parameters than the hasher is configured with.
+	NeedsRehash(encoded string) bool
+}
+
+// Manager hashes new passwords with the preferred algorithm and still verifies
+// hashes produced by any of the known ones.
+type Manager struct {
+	preferred Hasher
+	known     []Hasher
+}
+
+func NewManager(preferred Hasher, known ...Hasher) *Manager {
+	return &Manager{preferred: preferred, known: append([]Hasher{preferred}, known...)}
+}
+
+// NewHasher builds a Manager that prefers the named algorithm and accepts all the others.
+func NewHasher(name string) (*Manager, error) {
+	argon := NewArgon2idHasher()
+	bcrypt := NewBcryptHasher()
+	legacy := NewLegacySHA256Hasher()
+
+	switch strings.ToLower(name) {
+	case "", "argon2id":
+		return NewManager(argon, bcrypt, legacy), nil
+	case "bcrypt":
+		return NewManager(bcrypt, argon, legacy), nil
+	default:
+		return nil, errors.Wrap(ErrUnknownAlgorithm, name)
+	}
+}
+
+func (r *Manager) Hash(password string) (string, error) {
+	return r.preferred.Hash(password)
+}
+
+func (r *Manager) Verify(password, encoded string) (bool, error) {
+	for _, hasher := range r.known {
+		if hasher.Supports(encoded) {
+			return hasher.Verify(password, encoded)
+		}
+	}
+
+	return false, ErrUnknownAlgorithm
+}
+
+func (r *Manager) Supports(encoded string) bool {
+	for _, hasher := range r.known {
+		if hasher.Supports(encoded) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (r *Manager) NeedsRehash(encoded string) bool {
+	if !r.preferred.Supports(encoded) {
+		return true
+	}
+
+	return r.preferred.NeedsRehash(encoded)
+}
+
+var ErrUnknownAlgorithm = errors.New("unknown password hashing algorithm")
+var ErrMalformedHash = errors.New("malformed password hash")
@@ -0,0 +1,55 @@
+package password_utils
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"github.com/pkg/errors"
+	"strings"
+)
+
+const legacySHA256Prefix = "$sha256$"
+
+// LegacySHA256Hasher verifies the single salted SHA-256 round used before
+// memory-hard hashing was introduced. It never produces new hashes.
+type LegacySHA256Hasher struct{}
+
+func NewLegacySHA256Hasher() *LegacySHA256Hasher {
+	return &LegacySHA256Hasher{}
+}
+
+// EncodeLegacy wraps the old pwd_salt and binary pwd_hash columns into the encoded format.
+func EncodeLegacy(salt, hashSum string) string {
+	return legacySHA256Prefix + hex.EncodeToString([]byte(salt)) + "$" + hex.EncodeToString([]byte(hashSum))
+}
+
+func (r *LegacySHA256Hasher) Hash(string) (string, error) {
+	return "", errors.Wrap(ErrUnknownAlgorithm, "legacy sha256 hashes are verify-only")
+}
+
+func (r *LegacySHA256Hasher) Verify(password, encoded string) (bool, error) {
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 4 || parts[1] != "sha256" {
+		return false, ErrMalformedHash
+	}
+
+	salt, err := hex.DecodeString(parts[2])
+	if err != nil {
+		return false, ErrMalformedHash
+	}
+	hashSum, err := hex.DecodeString(parts[3])
+	if err != nil {
+		return false, ErrMalformedHash
+	}
+
+	other := sha256.Sum256(append(salt, password...))
+	return subtle.ConstantTimeCompare(hashSum, other[:]) == 1, nil
+}
+
+func (r *LegacySHA256Hasher) Supports(encoded string) bool {
+	return strings.HasPrefix(encoded, legacySHA256Prefix)
+}
+
+func (r *LegacySHA256Hasher) NeedsRehash(string) bool {
+	return true
+}
@@ -0,0 +1,19 @@
+package secret_utils
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// NewSecret returns a random opaque string with 130 bits of entropy.
+func NewSecret() string {
+	return rand.Text()
+}
+
+// HashSecret returns the hex SHA-256 of an opaque secret. High-entropy secrets
+// don't need a slow hash, and a deterministic one allows lookups by hash.
+func HashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
@@ -21,11 +21,86 @@ type Setting struct {
 	TimeoutString string `json:"TIMEOUT"`
 	Timeout       time.Duration
 
+	// SigningKey is the former HS256 secret. Tokens signed with it before the first asymmetric key
+	// stay valid until they expire.
 	SigningKey   string `json:"SIGNING_KEY"`
 	JwtTtlString string `json:"JWT_TTL"`
 	JwtTtl       time.Duration
 
-	AdminKey string `json:"ADMIN_KEY"`
+	// SigningAlgorithm is "RS256" (default) or "EdDSA".
+	SigningAlgorithm            string `json:"SIGNING_ALGORITHM"`
+	SigningKeyRotationString    string `json:"SIGNING_KEY_ROTATION"`
+	SigningKeyRotation          time.Duration
+	SigningKeyPropagationString string `json:"SIGNING_KEY_PROPAGATION"`
+	SigningKeyPropagation       time.Duration
+
+	RefreshTtlString string `json:"REFRESH_TTL"`
+	RefreshTtl       time.Duration
+
+	RevocationPruneIntervalString string `json:"REVOCATION_PRUNE_INTERVAL"`
+	RevocationPruneInterval       time.Duration
+
+	InvitationTtlString string `json:"INVITATION_TTL"`
+	InvitationTtl       time.Duration
+
+	// PasswordHasher is either "argon2id" (default) or "bcrypt".
+	PasswordHasher string `json:"PASSWORD_HASHER"`
+
+	// MailTransport is "smtp", "file" or "stdout" (default).
+	MailTransport string `json:"MAIL_TRANSPORT"`
+	MailFrom      string `json:"MAIL_FROM"`
+	MailFile      string `json:"MAIL_FILE"`
+	SmtpHost      string `json:"SMTP_HOST"`
+	SmtpPort      uint16 `json:"SMTP_PORT"`
+	SmtpUser      string `json:"SMTP_USER"`
+	SmtpPass      string `json:"SMTP_PASS"`
+
+	PasswordResetUrl       string `json:"PASSWORD_RESET_URL"`
+	PasswordResetTtlString string `json:"PASSWORD_RESET_TTL"`
+	PasswordResetTtl       time.Duration
+
+	// MaxPageSize caps the pageSize of every listing.
+	MaxPageSize int `json:"MAX_PAGE_SIZE"`
+
+	// SearchBackend is "mysql" (default, FULLTEXT indexes) or "memory", an in-process index rebuilt on start.
+	SearchBackend string `json:"SEARCH_BACKEND"`
+	// SearchRefreshInterval is how often the spelling vocabulary and the suggestions are rebuilt from the catalog,
+	// picking up writes made through other instances.
+	SearchRefreshIntervalString string `json:"SEARCH_REFRESH_INTERVAL"`
+	SearchRefreshInterval       time.Duration
+
+	// LoginLimiterStore is "memory" (default) or "database", the latter shared between instances.
+	LoginLimiterStore          string `json:"LOGIN_LIMITER_STORE"`
+	LoginLockoutThreshold      int    `json:"LOGIN_LOCKOUT_THRESHOLD"`
+	LoginLockoutDurationString string `json:"LOGIN_LOCKOUT_DURATION"`
+	LoginLockoutDuration       time.Duration
+
+	// ForceAdminMfa strips roles from tokens of users with admin rights until they enroll in 2FA.
+	ForceAdminMfa     bool   `json:"FORCE_ADMIN_MFA"`
+	MfaIssuer         string `json:"MFA_ISSUER"`
+	MfaTokenTtlString string `json:"MFA_TOKEN_TTL"`
+	MfaTokenTtl       time.Duration
+
+	// OidcIssuer enables OpenID Connect login when set.
+	OidcIssuer       string   `json:"OIDC_ISSUER"`
+	OidcClientId     string   `json:"OIDC_CLIENT_ID"`
+	OidcClientSecret string   `json:"OIDC_CLIENT_SECRET"`
+	OidcRedirectUrl  string   `json:"OIDC_REDIRECT_URL"`
+	OidcScopes       []string `json:"OIDC_SCOPES"`
+	OidcGroupsClaim  string   `json:"OIDC_GROUPS_CLAIM"`
+	// OidcGroupRoles maps IdP groups to store roles. Mapped roles are granted and
+	// removed on every OIDC login; roles missing from the map are left alone.
+	OidcGroupRoles map[string][]string `json:"OIDC_GROUP_ROLES"`
+	// OidcAllowSignup creates local users for unknown identities.
+	OidcAllowSignup bool `json:"OIDC_ALLOW_SIGNUP"`
+	// OidcLinkByEmail links unknown identities with a verified email to the user owning that email.
+	OidcLinkByEmail bool `json:"OIDC_LINK_BY_EMAIL"`
+	// OidcFrontendUrl receives a one-time code in the URL fragment after the callback, which it exchanges
+	// for the tokens at /api/auth/oidc/exchange. Without it the callback answers JSON.
+	OidcFrontendUrl string `json:"OIDC_FRONTEND_URL"`
+
+	// ProxyHeader names the header carrying the client address when running behind a proxy, e.g. X-Real-IP.
+	ProxyHeader string `json:"PROXY_HEADER"`
 
 	Cors string `json:"CORS"`
 }
@@ -55,8 +130,85 @@ func NewConfig() *Setting {
 		panic(err)
 	}
 
+	if set.SigningAlgorithm == "" {
+		set.SigningAlgorithm = "RS256"
+	}
+
+	set.SigningKeyRotation, err = parseDurationOrDefault(set.SigningKeyRotationString, 30*24*time.Hour)
+	if err != nil {
+		panic(err)
+	}
+
+	set.SigningKeyPropagation, err = parseDurationOrDefault(set.SigningKeyPropagationString, 5*time.Minute)
+	if err != nil {
+		panic(err)
+	}
+
+	set.RefreshTtl, err = parseDurationOrDefault(set.RefreshTtlString, 30*24*time.Hour)
+	if err != nil {
+		panic(err)
+	}
+
+	set.InvitationTtl, err = parseDurationOrDefault(set.InvitationTtlString, 72*time.Hour)
+	if err != nil {
+		panic(err)
+	}
+
+	set.PasswordResetTtl, err = parseDurationOrDefault(set.PasswordResetTtlString, 30*time.Minute)
+	if err != nil {
+		panic(err)
+	}
+
+	set.RevocationPruneInterval, err = parseDurationOrDefault(set.RevocationPruneIntervalString, 10*time.Minute)
+	if err != nil {
+		panic(err)
+	}
+
+	set.LoginLockoutDuration, err = parseDurationOrDefault(set.LoginLockoutDurationString, 15*time.Minute)
+	if err != nil {
+		panic(err)
+	}
+
+	set.SearchRefreshInterval, err = parseDurationOrDefault(set.SearchRefreshIntervalString, 5*time.Minute)
+	if err != nil {
+		panic(err)
+	}
+
+	if set.MaxPageSize <= 0 {
+		set.MaxPageSize = 100
+	}
+
+	if set.LoginLockoutThreshold <= 0 {
+		set.LoginLockoutThreshold = 10
+	}
+
+	set.MfaTokenTtl, err = parseDurationOrDefault(set.MfaTokenTtlString, 5*time.Minute)
+	if err != nil {
+		panic(err)
+	}
+
+	if len(set.OidcScopes) == 0 {
+		set.OidcScopes = []string{"openid", "profile", "email"}
+	}
+
+	if set.OidcGroupsClaim == "" {
+		set.OidcGroupsClaim = "groups"
+	}
+
+	if set.MfaIssuer == "" {
+		set.MfaIssuer = "Bookstore"
+	}
+
 	zerolog.Ctx(context.Background()).Info().Msg("config.created")
 	return &set
 }
 
+func parseDurationOrDefault(value string, def time.Duration) (time.Duration, error) {
+	if value == "" {
+		return def, nil
+	}
+
+	return time.ParseDuration(value)
+}
+
 var Settings = NewConfig()
@@ -0,0 +1,264 @@
+package signing_utils
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/pkg/errors"
+	"math/big"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	AlgorithmRS256 = "RS256"
+	AlgorithmEdDSA = "EdDSA"
+)
+
+// Key is a parsed signing key. It signs from NotBefore until a newer key takes over
+// and verifies until ExpiresAt, zero meaning no end.
+type Key struct {
+	Kid       string
+	Algorithm string
+	Private   crypto.Signer
+	NotBefore time.Time
+	ExpiresAt time.Time
+}
+
+// Jwk is the public part of a key as published in the JWKS.
+type Jwk struct {
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+type Jwks struct {
+	Keys []Jwk `json:"keys"`
+}
+
+// Keyring holds the key tokens are signed with and every key they may still be verified with.
+// A legacy HMAC secret, if set, keeps tokens issued before asymmetric signing valid until they
+// expire, legacyTtl after the oldest key started signing.
+type Keyring struct {
+	mu           sync.RWMutex
+	keys         []Key
+	legacySecret []byte
+	legacyTtl    time.Duration
+}
+
+func NewKeyring(legacySecret string, legacyTtl time.Duration) *Keyring {
+	keyring := &Keyring{legacyTtl: legacyTtl}
+	if legacySecret != "" {
+		keyring.legacySecret = []byte(legacySecret)
+	}
+
+	return keyring
+}
+
+// Set replaces the keys, e.g. after another instance rotated them.
+func (r *Keyring) Set(keys []Key) {
+	sorted := make([]Key, len(keys))
+	copy(sorted, keys)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].NotBefore.After(sorted[j].NotBefore)
+	})
+
+	r.mu.Lock()
+	r.keys = sorted
+	r.mu.Unlock()
+}
+
+// Current returns the newest key whose NotBefore has passed.
+func (r *Keyring) Current(now time.Time) (Key, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, key := range r.keys {
+		if !key.NotBefore.After(now) && (key.ExpiresAt.IsZero() || now.Before(key.ExpiresAt)) {
+			return key, true
+		}
+	}
+
+	return Key{}, false
+}
+
+// Sign signs claims with the current key and names it in the kid header.
+func (r *Keyring) Sign(claims jwt.Claims) (string, error) {
+	key, ok := r.Current(time.Now().UTC())
+	if !ok {
+		return "", ErrNoSigningKey
+	}
+
+	token := jwt.NewWithClaims(jwt.GetSigningMethod(key.Algorithm), claims)
+	token.Header["kid"] = key.Kid
+
+	signed, err := token.SignedString(key.Private)
+	if err != nil {
+		return "", errors.Wrap(err, "sign token")
+	}
+
+	return signed, nil
+}
+
+// Keyfunc resolves the verification key for jwt.Parse. The algorithm must match
+// the key's, so that a public key can never be used as an HMAC secret.
+func (r *Keyring) Keyfunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	if kid == "" {
+		if r.acceptsLegacy(token) {
+			return r.legacySecret, nil
+		}
+		return nil, ErrUnknownKey
+	}
+
+	now := time.Now().UTC()
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, key := range r.keys {
+		if key.Kid != kid {
+			continue
+		}
+		if token.Method.Alg() != key.Algorithm || (!key.ExpiresAt.IsZero() && now.After(key.ExpiresAt)) {
+			return nil, ErrUnknownKey
+		}
+
+		return key.Private.Public(), nil
+	}
+
+	return nil, ErrUnknownKey
+}
+
+// LegacyUntil returns the moment HS256 tokens stop being accepted, zero if there is no legacy secret
+// or no key yet. The oldest key expires only after that moment, so the window does not move later.
+func (r *Keyring) LegacyUntil() time.Time {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if r.legacySecret == nil || len(r.keys) == 0 {
+		return time.Time{}
+	}
+	return r.keys[len(r.keys)-1].NotBefore.Add(r.legacyTtl)
+}
+
+// acceptsLegacy reports whether an HS256 token may be verified with the legacy secret: it has to be
+// issued before the oldest key started signing, and is refused once such tokens have expired.
+func (r *Keyring) acceptsLegacy(token *jwt.Token) bool {
+	if r.legacySecret == nil || token.Method.Alg() != jwt.SigningMethodHS256.Alg() {
+		return false
+	}
+	iat, err := token.Claims.GetIssuedAt()
+	if err != nil || iat == nil {
+		return false
+	}
+
+	until := r.LegacyUntil()
+	if until.IsZero() {
+		return false
+	}
+	return iat.Before(until.Add(-r.legacyTtl)) && time.Now().UTC().Before(until)
+}
+
+// Jwks returns the public keys that tokens may currently be verified with.
+func (r *Keyring) Jwks() Jwks {
+	now := time.Now().UTC()
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	jwks := Jwks{Keys: make([]Jwk, 0, len(r.keys))}
+	for _, key := range r.keys {
+		if !key.ExpiresAt.IsZero() && now.After(key.ExpiresAt) {
+			continue
+		}
+
+		jwk := Jwk{Use: "sig", Alg: key.Algorithm, Kid: key.Kid}
+		switch public := key.Private.Public().(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(public.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.Kty = "OKP"
+			jwk.Crv = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(public)
+		default:
+			continue
+		}
+		jwks.Keys = append(jwks.Keys, jwk)
+	}
+
+	return jwks
+}
+
+// GenerateKey creates a key for the algorithm and returns it PKCS#8 PEM encoded along with
+// its kid, the start of the SHA-256 of the public key.
+func GenerateKey(algorithm string) (string, string, error) {
+	var private crypto.Signer
+	var err error
+	switch algorithm {
+	case AlgorithmRS256:
+		private, err = rsa.GenerateKey(rand.Reader, 2048)
+	case AlgorithmEdDSA:
+		_, private, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		return "", "", errors.Wrap(ErrUnknownAlgorithm, algorithm)
+	}
+	if err != nil {
+		return "", "", errors.Wrap(err, "generate key")
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		return "", "", errors.Wrap(err, "generate key")
+	}
+	public, err := x509.MarshalPKIXPublicKey(private.Public())
+	if err != nil {
+		return "", "", errors.Wrap(err, "generate key")
+	}
+	sum := sha256.Sum256(public)
+
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})), hex.EncodeToString(sum[:8]), nil
+}
+
+// ParseKey reads a PKCS#8 PEM key and checks that it fits the algorithm.
+func ParseKey(algorithm, privatePem string) (crypto.Signer, error) {
+	block, _ := pem.Decode([]byte(privatePem))
+	if block == nil {
+		return nil, errors.New("no PEM block in signing key")
+	}
+
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, errors.Wrap(err, "parse signing key")
+	}
+
+	switch private := parsed.(type) {
+	case *rsa.PrivateKey:
+		if algorithm == AlgorithmRS256 {
+			return private, nil
+		}
+	case ed25519.PrivateKey:
+		if algorithm == AlgorithmEdDSA {
+			return private, nil
+		}
+	}
+
+	return nil, errors.Wrap(ErrUnknownAlgorithm, algorithm)
+}
+
+var ErrNoSigningKey = errors.New("no active signing key")
+var ErrUnknownKey = errors.New("unknown signing key")
+var ErrUnknownAlgorithm = errors.New("unknown signing algorithm")
@@ -3,12 +3,13 @@ import { getUserInfo, isAuthenticated } from '../utils/auth';
 import { addToCart } from '../utils/api';
 
 function BookCard({ book, onEdit, onDelete, onCartUpdate, onBookClick }) {
-    const authors = Array.isArray(book.authors) ? book.authors.join(', ') : '';
+    const authors = Array.isArray(book.authors) ? book.authors.map(author => author.name).join(', ') : '';
     const price = (book.price / 100).toFixed(2);
     const userInfo = getUserInfo();
     const isAdmin = userInfo && userInfo.admin;
     const isAuth = isAuthenticated();
     const [adding, setAdding] = useState(false);
+    const soldOut = book.availability === 'out_of_stock';
 
     const handleAddToCart = async (e) => {
         e.stopPropagation();
@@ -54,10 +55,12 @@ function BookCard({ book, onEdit, onDelete, onCartUpdate, onBookClick }) {
             <h3 className="book-title">{book.name}</h3>
             {authors && <p className="book-authors">{authors}</p>}
             <p className="book-price">${price}</p>
+            {book.availability === 'low_stock' && <p className="book-availability low">Only {book.stock} left</p>}
+            {soldOut && <p className="book-availability out">Out of stock</p>}
             {isAuth && (
                 <button 
                     onClick={handleAddToCart}
-                    disabled={adding}
+                    disabled={adding || soldOut}
                     className="book-add-cart-btn"
                 >
                     {adding ? 'Adding...' : 'Add to Cart'}
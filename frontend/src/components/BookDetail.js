@@ -3,16 +3,16 @@ import { getUserInfo, isAuthenticated } from '../utils/auth';
 import { addToCart } from '../utils/api';
 
 function BookDetail({ book, categories, onClose, onCartUpdate }) {
-    const authors = Array.isArray(book.authors) ? book.authors.join(', ') : '';
+    const authors = Array.isArray(book.authors) ? book.authors.map(author => author.name).join(', ') : '';
     const price = (book.price / 100).toFixed(2);
     const description = book.desc || 'No description available.';
     const isAuth = isAuthenticated();
     const [adding, setAdding] = useState(false);
 
     // Get category names for this book
-    const bookCategories = categories.filter(cat => 
-        book.categories && book.categories.includes(cat.id)
-    ).map(cat => cat.name);
+    const bookCategories = book.breadcrumbs
+        ? book.breadcrumbs.map(path => path.map(cat => cat.name).join(' › '))
+        : (book.categories || []).map(cat => cat.name);
 
     const handleAddToCart = async (e) => {
         e.stopPropagation();
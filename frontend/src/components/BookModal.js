@@ -12,10 +12,10 @@ function BookModal({ book, categories, onSave, onClose }) {
     useEffect(() => {
         if (book) {
             setName(book.name || '');
-            setAuthors(Array.isArray(book.authors) ? book.authors.join(', ') : '');
+            setAuthors(Array.isArray(book.authors) ? book.authors.map(author => author.name).join(', ') : '');
             setPrice(book.price ? (book.price / 100).toString() : '');
             setDescription(book.desc || '');
-            setSelectedCategories(book.categories || []);
+            setSelectedCategories((book.categories || []).map(cat => cat.id));
         }
     }, [book]);
 
@@ -38,12 +38,23 @@ function BookModal({ book, categories, onSave, onClose }) {
                 throw new Error('Valid price is required');
             }
 
+            // Names still on the book keep their author id and role, so translators and editors survive an edit.
+            const existing = book && Array.isArray(book.authors) ? [...book.authors] : [];
+            const contributors = authorsArray.map(author => {
+                const index = existing.findIndex(ref => ref.name === author);
+                if (index === -1) {
+                    return { name: author };
+                }
+                const [ref] = existing.splice(index, 1);
+                return { authorId: ref.id, role: ref.role };
+            });
+
             const bookData = {
                 name: name.trim(),
-                authors: authorsArray,
+                contributors,
                 price: priceInCents,
                 desc: description.trim(),
-                categories: selectedCategories
+                categoryIds: selectedCategories
             };
 
             await onSave(bookData);
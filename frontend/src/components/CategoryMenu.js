@@ -2,7 +2,7 @@ import React, { useState } from 'react';
 import { getUserInfo } from '../utils/auth';
 import { createCategory, updateCategory, deleteCategory } from '../utils/api';
 
-function CategoryMenu({ categories, currentCategory, onSelectCategory, onCategoryChange }) {
+function CategoryMenu({ categories, counts = {}, currentCategory, onSelectCategory, onCategoryChange }) {
     const [showCreateModal, setShowCreateModal] = useState(false);
     const [editingCategory, setEditingCategory] = useState(null);
     const [categoryName, setCategoryName] = useState('');
@@ -103,7 +103,7 @@ function CategoryMenu({ categories, currentCategory, onSelectCategory, onCategor
                     </a>
                 </li>
                 {categories.map((category) => (
-                    <li key={category.id} className="category-item">
+                    <li key={category.id} className="category-item" style={{ paddingLeft: `${category.depth}em` }}>
                         {editingCategory && editingCategory.id === category.id ? (
                             <div className="category-edit-form">
                                 <input
@@ -141,6 +141,9 @@ function CategoryMenu({ categories, currentCategory, onSelectCategory, onCategor
                                     }}
                                 >
                                     {category.name}
+                                    {counts[category.id] !== undefined && (
+                                        <span className="category-count"> ({counts[category.id]})</span>
+                                    )}
                                 </a>
                                 {isAdmin && (
                                     <div className="category-actions">
@@ -25,7 +25,8 @@ function Header({ onLogout, onSearch, onClearSearch, searchTerm }) {
     const loadCartCount = async () => {
         try {
             const result = await getCart();
-            setCartItemCount(result.books ? result.books.length : 0);
+            const lines = result.cart && Array.isArray(result.cart.lines) ? result.cart.lines : [];
+            setCartItemCount(lines.reduce((sum, line) => sum + line.quantity, 0));
         } catch (error) {
             setCartItemCount(0);
         }
@@ -1,37 +1,112 @@
-import React, { useState } from 'react';
+import React, { useEffect, useState } from 'react';
+import { fetchSuggestions } from '../utils/api';
+
+const SUGGEST_DELAY = 150;
 
 function SearchBar({ onSearch, onClear, searchTerm }) {
     const [inputValue, setInputValue] = useState(searchTerm || '');
+    const [suggestions, setSuggestions] = useState([]);
+    const [activeIndex, setActiveIndex] = useState(-1);
 
-    const handleSubmit = (e) => {
-        e.preventDefault();
-        const term = inputValue.trim();
+    useEffect(() => {
+        const query = inputValue.trim();
+        if (query.length < 2) {
+            setSuggestions([]);
+            return;
+        }
+
+        let cancelled = false;
+        const timer = setTimeout(() => {
+            fetchSuggestions(query)
+                .then(result => {
+                    if (!cancelled) {
+                        setSuggestions(result);
+                        setActiveIndex(-1);
+                    }
+                })
+                .catch(() => {
+                    if (!cancelled) setSuggestions([]);
+                });
+        }, SUGGEST_DELAY);
+
+        return () => {
+            cancelled = true;
+            clearTimeout(timer);
+        };
+    }, [inputValue]);
+
+    const search = (term) => {
+        setSuggestions([]);
         if (term) {
             onSearch(term);
         }
     };
 
+    const handleSubmit = (e) => {
+        e.preventDefault();
+        search(inputValue.trim());
+    };
+
+    const handleSelect = (suggestion) => {
+        setInputValue(suggestion.text);
+        search(suggestion.text);
+    };
+
     const handleClear = () => {
         setInputValue('');
+        setSuggestions([]);
         onClear();
     };
 
-    const handleKeyPress = (e) => {
-        if (e.key === 'Enter') {
-            handleSubmit(e);
+    const handleKeyDown = (e) => {
+        if (e.key === 'ArrowDown' && suggestions.length > 0) {
+            e.preventDefault();
+            setActiveIndex((activeIndex + 1) % suggestions.length);
+        } else if (e.key === 'ArrowUp' && suggestions.length > 0) {
+            e.preventDefault();
+            setActiveIndex((activeIndex - 1 + suggestions.length) % suggestions.length);
+        } else if (e.key === 'Escape') {
+            setSuggestions([]);
+        } else if (e.key === 'Enter') {
+            if (activeIndex >= 0 && activeIndex < suggestions.length) {
+                e.preventDefault();
+                handleSelect(suggestions[activeIndex]);
+            } else {
+                handleSubmit(e);
+            }
         }
     };
 
     return (
         <div className="search-container">
-            <input
-                type="text"
-                className="search-input"
-                placeholder="Search books..."
-                value={inputValue}
-                onChange={(e) => setInputValue(e.target.value)}
-                onKeyPress={handleKeyPress}
-            />
+            <div className="search-field">
+                <input
+                    type="text"
+                    className="search-input"
+                    placeholder="Search books..."
+                    value={inputValue}
+                    onChange={(e) => setInputValue(e.target.value)}
+                    onKeyDown={handleKeyDown}
+                    onBlur={() => setSuggestions([])}
+                />
+                {suggestions.length > 0 && (
+                    <ul className="search-suggestions">
+                        {suggestions.map((suggestion, index) => (
+                            <li
+                                key={`${suggestion.kind}-${suggestion.id}`}
+                                className={`search-suggestion${index === activeIndex ? ' active' : ''}`}
+                                onMouseDown={(e) => {
+                                    e.preventDefault();
+                                    handleSelect(suggestion);
+                                }}
+                            >
+                                <span>{suggestion.text}</span>
+                                <span className="search-suggestion-kind">{suggestion.kind}</span>
+                            </li>
+                        ))}
+                    </ul>
+                )}
+            </div>
             <button onClick={handleSubmit} className="search-btn">
                 Search
             </button>
@@ -43,4 +118,3 @@ function SearchBar({ onSearch, onClear, searchTerm }) {
 }
 
 export default SearchBar;
-
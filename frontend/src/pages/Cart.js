@@ -1,7 +1,7 @@
 import React, { useState, useEffect, useMemo } from 'react';
 import { useNavigate, Link } from 'react-router-dom';
 import { isAuthenticated, getToken, setToken } from '../utils/auth';
-import { getCart, removeFromCart, addToCart, logout } from '../utils/api';
+import { getCart, removeFromCart, setCartQuantity, logout } from '../utils/api';
 import Header from '../components/Header';
 import '../App.css';
 
@@ -37,51 +37,28 @@ function Cart() {
         }
     };
 
-    // Group books by ID and count quantities
+    // Join cart lines with their books
     const groupedBooks = useMemo(() => {
-        if (!cart || !books.length) return [];
-        
-        const bookMap = new Map();
-        books.forEach(book => {
-            if (!bookMap.has(book.id)) {
-                bookMap.set(book.id, { ...book, quantity: 0 });
-            }
-        });
-
-        // Count occurrences in cart.bookIds
-        if (cart.bookIds && Array.isArray(cart.bookIds)) {
-            cart.bookIds.forEach(bookId => {
-                const book = bookMap.get(bookId);
-                if (book) {
-                    book.quantity = (book.quantity || 0) + 1;
-                }
-            });
-        }
+        if (!cart || !Array.isArray(cart.lines)) return [];
 
-        return Array.from(bookMap.values()).filter(book => book.quantity > 0);
+        const bookMap = new Map(books.map(book => [book.id, book]));
+        return cart.lines
+            .filter(line => bookMap.has(line.bookId))
+            .map(line => ({ ...bookMap.get(line.bookId), quantity: line.quantity, unitPrice: line.unitPrice }));
     }, [cart, books]);
 
-    const handleIncrease = async (bookId) => {
+    const updateQuantity = async (bookId, quantity) => {
         setUpdating(prev => ({ ...prev, [bookId]: true }));
         try {
-            await addToCart(bookId);
-            loadCart();
-            window.dispatchEvent(new Event('cartUpdated'));
-        } catch (error) {
-            alert('Failed to add item: ' + error.message);
-        } finally {
-            setUpdating(prev => ({ ...prev, [bookId]: false }));
-        }
-    };
-
-    const handleDecrease = async (bookId) => {
-        setUpdating(prev => ({ ...prev, [bookId]: true }));
-        try {
-            await removeFromCart(bookId);
+            if (quantity > 0) {
+                await setCartQuantity(bookId, quantity);
+            } else {
+                await removeFromCart(bookId);
+            }
             loadCart();
             window.dispatchEvent(new Event('cartUpdated'));
         } catch (error) {
-            alert('Failed to remove item: ' + error.message);
+            alert('Failed to update item: ' + error.message);
         } finally {
             setUpdating(prev => ({ ...prev, [bookId]: false }));
         }
@@ -139,9 +116,9 @@ function Cart() {
                                 <>
                                     <div className="cart-items">
                                         {groupedBooks.map((book) => {
-                                            const authors = Array.isArray(book.authors) ? book.authors.join(', ') : '';
-                                            const price = (book.price / 100).toFixed(2);
-                                            const subtotal = ((book.price * book.quantity) / 100).toFixed(2);
+                                            const authors = Array.isArray(book.authors) ? book.authors.map(author => author.name).join(', ') : '';
+                                            const price = (book.unitPrice / 100).toFixed(2);
+                                            const subtotal = ((book.unitPrice * book.quantity) / 100).toFixed(2);
                                             const isUpdating = updating[book.id];
                                             return (
                                                 <div key={book.id} className="cart-item">
@@ -156,7 +133,7 @@ function Cart() {
                                                     <div className="cart-item-controls">
                                                         <div className="quantity-controls">
                                                             <button
-                                                                onClick={() => handleDecrease(book.id)}
+                                                                onClick={() => updateQuantity(book.id, book.quantity - 1)}
                                                                 disabled={isUpdating || book.quantity <= 1}
                                                                 className="quantity-btn quantity-btn-minus"
                                                             >
@@ -164,7 +141,7 @@ function Cart() {
                                                             </button>
                                                             <span className="quantity-display">{book.quantity}</span>
                                                             <button
-                                                                onClick={() => handleIncrease(book.id)}
+                                                                onClick={() => updateQuantity(book.id, book.quantity + 1)}
                                                                 disabled={isUpdating}
                                                                 className="quantity-btn quantity-btn-plus"
                                                             >
@@ -173,7 +150,7 @@ function Cart() {
                                                         </div>
                                                         {book.quantity === 1 && (
                                                             <button 
-                                                                onClick={() => handleDecrease(book.id)}
+                                                                onClick={() => updateQuantity(book.id, 0)}
                                                                 disabled={isUpdating}
                                                                 className="cart-remove-btn"
                                                             >
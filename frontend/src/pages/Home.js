@@ -1,4 +1,4 @@
-import React, { useState, useEffect } from 'react';
+import React, { useState, useEffect, useRef } from 'react';
 import { useNavigate } from 'react-router-dom';
 import Header from '../components/Header';
 import CategoryMenu from '../components/CategoryMenu';
@@ -24,9 +24,15 @@ function Home() {
     const [sortBy, setSortBy] = useState('name');
     const [orderBy, setOrderBy] = useState('DESC');
     const [hasMore, setHasMore] = useState(false);
+    const [categoryCounts, setCategoryCounts] = useState({});
     const [showBookModal, setShowBookModal] = useState(false);
     const [editingBook, setEditingBook] = useState(null);
     const [selectedBook, setSelectedBook] = useState(null);
+    // facetKey remembers the listing the category counts belong to, so that paging and sorting
+    // do not ask for them again.
+    const facetKey = useRef(null);
+    // cursors[n] is the nextCursor that leads to page n of the current listing.
+    const cursors = useRef([]);
     const navigate = useNavigate();
     const userInfo = getUserInfo();
     const isAdmin = userInfo && userInfo.admin;
@@ -52,9 +58,25 @@ function Home() {
         setLoading(true);
         setError(null);
         try {
-            const result = await fetchBooks(currentPage, PAGE_SIZE, currentCategory, currentSearch, sortBy, orderBy);
+            const category = categories.find(cat => cat.name === currentCategory);
+            const key = `${currentCategory}|${currentSearch}`;
+            if (currentPage === 0) {
+                cursors.current = [];
+            }
+            const result = await fetchBooks(currentPage, PAGE_SIZE, currentCategory, currentSearch, sortBy, orderBy, {
+                cursor: cursors.current[currentPage],
+                categoryId: category && category.id,
+                facets: facetKey.current !== key
+            });
             setBooks(result.books);
-            setHasMore(result.books.length === PAGE_SIZE);
+            setHasMore(!!result.nextCursor);
+            cursors.current[currentPage + 1] = result.nextCursor;
+            if (result.facets) {
+                facetKey.current = key;
+                setCategoryCounts(Object.fromEntries(
+                    result.facets.categories.map(facet => [facet.id, facet.count])
+                ));
+            }
         } catch (error) {
             setError(error.message);
             setBooks([]);
@@ -121,6 +143,7 @@ function Home() {
 
         try {
             await deleteBook(bookId);
+            facetKey.current = null;
             loadBooks();
         } catch (error) {
             alert('Failed to delete book: ' + error.message);
@@ -136,6 +159,7 @@ function Home() {
             }
             setShowBookModal(false);
             setEditingBook(null);
+            facetKey.current = null;
             loadBooks();
         } catch (error) {
             throw error;
@@ -161,6 +185,7 @@ function Home() {
             <div className="main-content">
                 <CategoryMenu 
                     categories={categories}
+                    counts={categoryCounts}
                     currentCategory={currentCategory}
                     onSelectCategory={handleCategorySelect}
                     onCategoryChange={loadCategories}
@@ -187,6 +212,7 @@ function Home() {
                             <option value="name">Name</option>
                             <option value="authors">Authors</option>
                             <option value="price">Price</option>
+                            {currentSearch && <option value="relevance">Relevance</option>}
                         </select>
                         <label htmlFor="orderBy">Order:</label>
                         <select 
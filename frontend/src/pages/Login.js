@@ -1,12 +1,14 @@
 import React, { useState } from 'react';
 import { useNavigate, Link } from 'react-router-dom';
-import { login } from '../utils/api';
+import { login, verifyMfa } from '../utils/api';
 import { setToken } from '../utils/auth';
 import '../App.css';
 
 function Login() {
     const [username, setUsername] = useState('');
     const [password, setPassword] = useState('');
+    const [mfaToken, setMfaToken] = useState('');
+    const [code, setCode] = useState('');
     const [error, setError] = useState('');
     const [loading, setLoading] = useState(false);
     const navigate = useNavigate();
@@ -17,8 +19,12 @@ function Login() {
         setLoading(true);
 
         try {
-            const data = await login(username.trim(), password);
-            if (data.token) {
+            const data = mfaToken
+                ? await verifyMfa(mfaToken, code.trim())
+                : await login(username.trim(), password);
+            if (data.mfaRequired) {
+                setMfaToken(data.mfaToken);
+            } else if (data.token) {
                 setToken(data.token);
                 navigate('/');
                 window.location.reload();
@@ -59,6 +65,20 @@ function Login() {
                             autoComplete="current-password"
                         />
                     </div>
+                    {mfaToken && (
+                        <div className="form-group">
+                            <label htmlFor="code">Authentication code</label>
+                            <input
+                                type="text"
+                                id="code"
+                                value={code}
+                                onChange={(e) => setCode(e.target.value)}
+                                required
+                                autoFocus
+                                autoComplete="one-time-code"
+                            />
+                        </div>
+                    )}
                     {error && <div className="error-message">{error}</div>}
                     <button type="submit" className="auth-btn" disabled={loading}>
                         {loading ? 'Logging in...' : 'Login'}
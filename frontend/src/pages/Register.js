@@ -7,7 +7,7 @@ import '../App.css';
 function Register() {
     const [username, setUsername] = useState('');
     const [password, setPassword] = useState('');
-    const [adminKey, setAdminKey] = useState('');
+    const [inviteCode, setInviteCode] = useState('');
     const [error, setError] = useState('');
     const [success, setSuccess] = useState('');
     const [loading, setLoading] = useState(false);
@@ -20,7 +20,7 @@ function Register() {
         setLoading(true);
 
         try {
-            const data = await register(username.trim(), password, adminKey.trim());
+            const data = await register(username.trim(), password, inviteCode.trim());
             if (data.token) {
                 setSuccess('Registration successful! Redirecting...');
                 setToken(data.token);
@@ -66,15 +66,15 @@ function Register() {
                         />
                     </div>
                     <div className="form-group">
-                        <label htmlFor="adminKey">Admin Key (Optional)</label>
+                        <label htmlFor="inviteCode">Invitation Code (Optional)</label>
                         <input
                             type="text"
-                            id="adminKey"
-                            value={adminKey}
-                            onChange={(e) => setAdminKey(e.target.value)}
+                            id="inviteCode"
+                            value={inviteCode}
+                            onChange={(e) => setInviteCode(e.target.value)}
                             autoComplete="off"
                         />
-                        <small>Leave empty for regular user account</small>
+                        <small>Staff members receive a code from an administrator</small>
                     </div>
                     {error && <div className="error-message">{error}</div>}
                     {success && <div className="success-message">{success}</div>}
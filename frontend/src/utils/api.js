@@ -4,17 +4,39 @@ export async function fetchCategories() {
     const response = await fetch(`${API_BASE}/categories`);
     if (!response.ok) throw new Error('Failed to fetch categories');
     const data = await response.json();
-    return data.categories || [];
+    return flattenCategories(data.categories || []);
 }
 
-export async function fetchBooks(page = 0, pageSize = 10, category = 'all', search = '', sortBy = 'name', orderBy = 'DESC') {
+// flattenCategories turns the category tree into a list in display order, with depth for indentation.
+function flattenCategories(categories, depth = 0) {
+    return categories.flatMap(category => [
+        { ...category, depth },
+        ...flattenCategories(category.children || [], depth + 1)
+    ]);
+}
+
+export async function fetchSuggestions(query, limit = 8) {
+    const response = await fetch(`${API_BASE}/search/suggest?q=${encodeURIComponent(query)}&limit=${limit}`);
+    if (!response.ok) throw new Error('Failed to fetch suggestions');
+    const data = await response.json();
+    return data.suggestions || [];
+}
+
+// fetchBooks lists a page of books. options.cursor, the nextCursor of the previous page, replaces page
+// and keeps pages stable while books are added. options.categoryId lists the selected category through
+// /books, which can add facets; options.facets asks for them, which costs the server a few extra queries.
+export async function fetchBooks(page = 0, pageSize = 10, category = 'all', search = '', sortBy = 'name', orderBy = 'DESC', options = {}) {
+    const { cursor, categoryId, facets = false } = options;
+    const position = cursor ? `cursor=${encodeURIComponent(cursor)}` : `page=${page}`;
+    const params = `${position}&pageSize=${pageSize}&sortBy=${sortBy}&orderBy=${orderBy}${facets ? '&facets=true' : ''}`;
     let url;
-    if (search) {
-        url = `${API_BASE}/books/search/${encodeURIComponent(search)}?page=${page}&pageSize=${pageSize}&sortBy=${sortBy}&orderBy=${orderBy}`;
-    } else if (category === 'all') {
-        url = `${API_BASE}/books?page=${page}&pageSize=${pageSize}&sortBy=${sortBy}&orderBy=${orderBy}`;
+    if (search || category === 'all') {
+        const query = search ? `&q=${encodeURIComponent(search)}` : '';
+        url = `${API_BASE}/books?${params}${query}`;
+    } else if (categoryId) {
+        url = `${API_BASE}/books?${params}&categories=${categoryId}`;
     } else {
-        url = `${API_BASE}/books/${encodeURIComponent(category)}?page=${page}&pageSize=${pageSize}&sortBy=${sortBy}&orderBy=${orderBy}`;
+        url = `${API_BASE}/books/${encodeURIComponent(category)}?${params}`;
     }
 
     const response = await fetch(url);
@@ -26,13 +48,13 @@ export async function fetchBooks(page = 0, pageSize = 10, category = 'all', sear
     }
     
     const data = await response.json();
-    return { books: data.books || [] };
+    return { books: data.books || [], facets: data.facets, nextCursor: data.nextCursor };
 }
 
-export async function register(username, password, adminKey = '') {
+export async function register(username, password, inviteCode = '') {
     const body = { username, password };
-    if (adminKey) {
-        body.key = adminKey;
+    if (inviteCode) {
+        body.inviteCode = inviteCode;
     }
 
     const response = await fetch(`${API_BASE}/auth/register`, {
@@ -84,6 +106,31 @@ export async function login(username, password) {
     return data;
 }
 
+export async function verifyMfa(mfaToken, code) {
+    const response = await fetch(`${API_BASE}/auth/mfa`, {
+        method: 'POST',
+        mode: 'cors',
+        headers: {
+            'Content-Type': 'application/json'
+        },
+        body: JSON.stringify({ mfaToken, code })
+    });
+
+    if (!response.ok) {
+        let errorMessage = 'Verification failed';
+        try {
+            const errorData = await response.json();
+            errorMessage = errorData.message || errorMessage;
+        } catch (e) {
+            errorMessage = `HTTP ${response.status}: ${response.statusText}`;
+        }
+        throw new Error(errorMessage);
+    }
+
+    const data = await response.json();
+    return data;
+}
+
 export async function logout(token) {
     const response = await fetch(`${API_BASE}/restricted/auth/logout`, {
         method: 'POST',
@@ -217,8 +264,8 @@ export async function updateCategory(categoryId, category) {
     return response.ok;
 }
 
-export async function deleteCategory(categoryId) {
-    const response = await fetch(`${API_BASE}/restricted/categories/${categoryId}`, {
+export async function deleteCategory(categoryId, strategy = 'detach') {
+    const response = await fetch(`${API_BASE}/restricted/categories/${categoryId}?strategy=${strategy}`, {
         method: 'DELETE',
         mode: 'cors',
         headers: getAuthHeaders()
@@ -285,6 +332,28 @@ export async function getCart() {
     return { cart: data.cart, books: data.books || [] };
 }
 
+export async function setCartQuantity(bookId, quantity) {
+    const response = await fetch(`${API_BASE}/restricted/cart/${bookId}`, {
+        method: 'PUT',
+        mode: 'cors',
+        headers: getAuthHeaders(),
+        body: JSON.stringify({ quantity })
+    });
+
+    if (!response.ok) {
+        let errorMessage = 'Failed to update cart';
+        try {
+            const errorData = await response.json();
+            errorMessage = errorData.message || errorMessage;
+        } catch (e) {
+            errorMessage = `HTTP ${response.status}: ${response.statusText}`;
+        }
+        throw new Error(errorMessage);
+    }
+
+    return response.ok;
+}
+
 export async function removeFromCart(bookId) {
     const response = await fetch(`${API_BASE}/restricted/cart/${bookId}`, {
         method: 'DELETE',